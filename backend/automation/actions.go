@@ -65,14 +65,19 @@ func RunSendEmail(config map[string]interface{}) StepResult {
 	to, _ := config["to"].(string)
 	subject, _ := config["subject"].(string)
 	content, _ := config["content"].(string)
+	from, _ := config["from"].(string) // 为空时使用 username
+	if from == "" {
+		from = username
+	}
 
 	addr := fmt.Sprintf("%s:%d", host, port)
-	msg := []byte(fmt.Sprintf("To: %s\r\n"+
+	msg := []byte(fmt.Sprintf("From: %s\r\n"+
+		"To: %s\r\n"+
 		"Subject: %s\r\n"+
 		"MIME-Version: 1.0\r\n"+ // [New增]
 		"Content-Type: text/html; charset=UTF-8\r\n"+ // [关键：改为 text/html]
 		"\r\n"+
-		"%s", to, subject, content))
+		"%s", from, to, subject, content))
 
 	// 1. 建立 TCP Connection
 	c, err := smtp.Dial(addr)
@@ -102,7 +107,7 @@ func RunSendEmail(config map[string]interface{}) StepResult {
 	}
 
 	// 4. Send邮件流程
-	if err = c.Mail(username); err != nil {
+	if err = c.Mail(from); err != nil {
 		return StepResult{Status: "failed", Error: err.Error()}
 	}
 	for _, addr := range strings.Split(to, ",") {
@@ -6,11 +6,15 @@ import (
 	"time"
 
 	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/live"
 	"github.com/laenix/vsentry/model"
 	"gorm.io/datatypes"
 )
 
-type Engine struct{}
+type Engine struct {
+	// ActorID 手动触发的用户，0 为Incident自动触发
+	ActorID uint
+}
 
 func NewEngine() *Engine {
 	return &Engine{}
@@ -34,10 +38,11 @@ func (e *Engine) Run(playbookID uint, inputContext map[string]interface{}) (uint
 
 	// 3. CreateExecute记录
 	execution := model.PlaybookExecution{
-		PlaybookID: playbookID,
-		Status:     "running",
-		StartTime:  time.Now(),
-		Logs:       datatypes.JSON([]byte("{}")),
+		PlaybookID:       playbookID,
+		Status:           "running",
+		TriggerContextID: incidentID(inputContext),
+		StartTime:        time.Now(),
+		Logs:             datatypes.JSON([]byte("{}")),
 	}
 	db.Create(&execution)
 
@@ -97,6 +102,13 @@ func (e *Engine) Run(playbookID uint, inputContext map[string]interface{}) (uint
 		// 实时UpdateData库Log，便于ago端轮询Detail
 		logBytes, _ := json.Marshal(executedLogs)
 		db.Model(&execution).Update("logs", logBytes)
+		live.Publish(live.PlaybookStep, execution.TriggerContextID, 0, "", map[string]interface{}{
+			"execution_id": execution.ID,
+			"playbook_id":  playbookID,
+			"node":         currID,
+			"node_type":    currNode.Data.Type,
+			"result":       result,
+		})
 
 		if result.Status == "failed" {
 			e.updateStatus(&execution, "failed", fmt.Sprintf("Node %s failed", currID))
@@ -155,4 +167,38 @@ func (e *Engine) updateStatus(exec *model.PlaybookExecution, status string, errM
 	exec.EndTime = time.Now()
 	exec.Duration = exec.EndTime.Sub(exec.StartTime).Milliseconds()
 	db.Save(exec)
+	live.Publish(live.PlaybookFinished, exec.TriggerContextID, 0, "", exec)
+
+	// 关联了 Incident 的运行记入其Timeline
+	if exec.TriggerContextID != 0 {
+		var playbook model.Playbook
+		db.Select("name").First(&playbook, exec.PlaybookID)
+		detail := fmt.Sprintf("playbook %q", playbook.Name)
+		if errMsg != "" {
+			detail += ": " + errMsg
+		}
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: exec.TriggerContextID,
+			ActorID:    e.ActorID,
+			Type:       model.ActivityPlaybook,
+			To:         status,
+			Detail:     detail,
+			RefID:      exec.ID,
+		})
+	}
+}
+
+// incidentID 从Playbook输入上下文Medium取出关联的 Incident ID (自动触发为 model.Incident，手动触发为 map)
+func incidentID(input map[string]interface{}) uint {
+	switch v := input["incident"].(type) {
+	case model.Incident:
+		return v.ID
+	case *model.Incident:
+		return v.ID
+	case map[string]interface{}:
+		if id, ok := v["ID"].(float64); ok {
+			return uint(id)
+		}
+	}
+	return 0
 }
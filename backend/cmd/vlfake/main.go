@@ -0,0 +1,22 @@
+// vlfake 内存版 VictoriaLogs，用于在 CI 中运行Rule测试用例：
+//
+//	go run ./cmd/vlfake -addr :9428
+//
+// 然后把 victorialogs.url 指向该地址并调用 POST /api/rules/tests/run。
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/laenix/vsentry/pkg/vlfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":9428", "listen address")
+	flag.Parse()
+
+	log.Printf("vlfake listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, vlfake.New()))
+}
@@ -2,38 +2,98 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/laenix/vsentry/database"
 	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/filter"
+	"github.com/laenix/vsentry/scheduler"
 )
 
-// ListAlerts GetAlertList
+// ListAlerts GetAlertList，?suppressed= 按抑制Status过滤，?status= / ?assignee= / ?incident_id= 按研判Status过滤，
+// 其余过滤、排序和翻页参数见 queryList (如 ?q=severity:>=high observable:10.0.0.5&limit=200)
 func ListAlerts(ctx *gin.Context) {
 	var alerts []model.Alert
-	database.GetDB().Order("id desc").Find(&alerts)
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": alerts, "msg": "success"})
+	db := database.GetDB().Model(&model.Alert{})
+	// ?suppressed=true 只看维护窗口内被抑制的Alert，false 排除
+	if v := ctx.Query("suppressed"); v != "" {
+		db = db.Where("suppressed = ?", v == "true")
+	}
+	if v := ctx.Query("status"); v != "" {
+		db = db.Where("status IN ?", strings.Split(v, ","))
+	}
+	if v := ctx.Query("assignee"); v != "" {
+		db = db.Where("assignee = ?", v)
+	}
+	if v := ctx.Query("incident_id"); v != "" {
+		db = db.Where("incident_id = ?", v)
+	}
+	page, err := queryList(ctx, db, alertListSpec, &alerts)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, pageResponse(alerts, page))
 }
 
-// Acknowledge 认领Alert
-func Acknowledge(ctx *gin.Context) {
-	id := ctx.Query("id")
-	userID, _ := ctx.Get("userid") // 从 AuthMiddleware Get
+// alertSeverity Alert的有效级别：后续Query提升的级别，否则为Rule级别，IOC Alert取 Incident 级别
+const alertSeverity = `COALESCE(NULLIF(alerts.severity, ''),
+	(SELECT NULLIF(severity, '') FROM rules WHERE rules.id = alerts.rule_id),
+	(SELECT severity FROM incidents WHERE incidents.id = alerts.incident_id))`
 
-	db := database.GetDB()
-	err := db.Model(&model.Alert{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":   "acknowledged",
-		"assignee": userID,
-	}).Error
+// alertListSpec ListAlerts 支持的过滤和排序字段
+var alertListSpec = listSpec{
+	table: "alerts",
+	fields: map[string]listField{
+		"id":         {column: "alerts.id", kind: fieldNumber},
+		"status":     {column: "alerts.status", kind: fieldString},
+		"verdict":    {column: "alerts.verdict", kind: fieldString},
+		"severity":   {column: alertSeverity, kind: fieldSeverity},
+		"rule":       {custom: ruleCondition("alerts.rule_id")},
+		"incident":   {column: "alerts.incident_id", kind: fieldNumber},
+		"indicator":  {column: "alerts.indicator_id", kind: fieldNumber},
+		"assignee":   {column: "alerts.assignee", kind: fieldUser},
+		"suppressed": {column: "alerts.suppressed", kind: fieldBool},
+		"created":    {column: "alerts.created_at", kind: fieldTime},
+		"content":    {column: "alerts.content", kind: fieldText},
+		"observable": {custom: alertObservableCondition},
+	},
+	sorts: map[string]listField{
+		"id":      {column: "alerts.id", kind: fieldNumber},
+		"created": {column: "alerts.created_at", kind: fieldTime},
+	},
+	defaultSort: "-id",
+	text:        []string{"alerts.content"},
+}
 
+// alertObservableCondition 所属 Incident 提取到该 Observable 且Alert内容包含该值
+func alertObservableCondition(ctx *gin.Context, t filter.Term) (string, []interface{}, error) {
+	sql, args, err := observableCondition("alerts.incident_id")(ctx, t)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "认领失败"})
+		return "", nil, err
+	}
+	parts := make([]string, 0, len(t.Values))
+	for _, v := range t.Values {
+		parts = append(parts, `alerts.content LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(v))
+	}
+	return "(" + sql + " AND (" + strings.Join(parts, " OR ") + "))", args, nil
+}
+
+// Acknowledge 认领Alert：进入 triaged 并指派给当ago用户
+func Acknowledge(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已成功认领"})
+	actor := currentUserID(ctx)
+	triageAlerts(ctx, []uint{uint(id)}, scheduler.AlertTriage{Status: model.AlertTriaged, Assignee: &actor}, "已成功认领")
 }
 
-// Resolve 解决并关闭Alert
+// Resolve 解决并关闭Alert，classification 为 TruePositive_* / FalsePositive_* 时记录为结论
 func Resolve(ctx *gin.Context) {
 	var req struct {
 		ID             uint   `json:"id"`
@@ -44,22 +104,14 @@ func Resolve(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
 		return
 	}
-
-	db := database.GetDB()
-	err := db.Model(&model.Alert{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
-		"status":                 "resolved",
-		"closing_classification": req.Classification,
-		"closing_comment":        req.Comment,
-	}).Error
-
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "关闭失败"})
-		return
-	}
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "告警已解决"})
+	triageAlerts(ctx, []uint{req.ID}, scheduler.AlertTriage{
+		Status:  model.AlertClosed,
+		Verdict: scheduler.VerdictFromClassification(req.Classification),
+		Comment: req.Comment,
+	}, "告警已解决")
 }
 
-// Assign Transfer或指派Alert
+// Assign Transfer或指派Alert，New Alert自动转为 triaged
 func Assign(ctx *gin.Context) {
 	var req struct {
 		ID     uint `json:"id"`
@@ -69,17 +121,62 @@ func Assign(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
 		return
 	}
+	if !userExists(req.UserID) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "指派的用户不存在"})
+		return
+	}
+	triageAlerts(ctx, []uint{req.ID}, scheduler.AlertTriage{Assignee: &req.UserID}, "指派成功")
+}
 
-	db := database.GetDB()
-	// Update逻辑：Settings受理人，如果原Status是 new 则自动转为 acknowledged
-	err := db.Model(&model.Alert{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
-		"assignee": req.UserID,
-		"status":   "acknowledged",
-	}).Error
+// BulkTriageAlerts 批量研判：对多条Alert设置Status、Assignee、结论或备注
+// POST /alerts/bulk {"ids":[1,2,3],"status":"false_positive","assignee":2,"comment":"..."}
+func BulkTriageAlerts(ctx *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+		scheduler.AlertTriage
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > 1000 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "ids 数量需在 1-1000 之间"})
+		return
+	}
+	if req.Assignee != nil && *req.Assignee != 0 && !userExists(*req.Assignee) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "指派的用户不存在"})
+		return
+	}
+	triageAlerts(ctx, req.IDs, req.AlertTriage, "批量操作完成")
+}
 
+// triageAlerts 单条操作时任何拒绝都Return 422，批量操作Return逐条结果
+func triageAlerts(ctx *gin.Context, ids []uint, t scheduler.AlertTriage, msg string) {
+	t.ActorID = currentUserID(ctx)
+	result, err := scheduler.TriageAlerts(ids, t)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "指派失败"})
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if len(ids) == 1 && len(result.Rejected) > 0 {
+		code := http.StatusUnprocessableEntity
+		if result.Rejected[ids[0]] == "alert not found" {
+			code = http.StatusNotFound
+		}
+		ctx.JSON(code, gin.H{"code": code, "msg": result.Rejected[ids[0]]})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "指派成功"})
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": msg, "data": result})
+}
+
+func currentUserID(ctx *gin.Context) uint {
+	userID, _ := ctx.Get("userid")
+	id, _ := userID.(uint)
+	return id
+}
+
+func userExists(id uint) bool {
+	var count int64
+	database.GetDB().Model(&model.User{}).Where("id = ?", id).Count(&count)
+	return count > 0
 }
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// GetAnomalyBaseline Get异常Rule的基线与学习期Status
+// GET /rules/baseline?id=1&entity=host-01
+func GetAnomalyBaseline(ctx *gin.Context) {
+	rule, ok := loadAnomalyRule(ctx)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB().Where("rule_id = ?", rule.ID)
+	if entity := ctx.Query("entity"); entity != "" {
+		db = db.Where("entity = ?", entity)
+	}
+	var baselines []model.AnomalyBaseline
+	db.Order("entity asc, bucket asc").Limit(5000).Find(&baselines)
+
+	var learningUntil *time.Time
+	if until, ok := scheduler.AnomalyLearningUntil(rule); ok {
+		learningUntil = &until
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"settings":       rule.Anomaly.WithDefaults(),
+		"learning":       scheduler.AnomalyLearning(rule, time.Now()),
+		"learning_until": learningUntil,
+		"current_bucket": scheduler.HourOfWeek(time.Now()),
+		"baselines":      baselines,
+	}})
+}
+
+// ResetAnomalyBaseline 清空基线，Rule重New进入学习期
+func ResetAnomalyBaseline(ctx *gin.Context) {
+	rule, ok := loadAnomalyRule(ctx)
+	if !ok {
+		return
+	}
+	if err := scheduler.ResetAnomalyBaseline(rule.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "基线已重置"})
+}
+
+func loadAnomalyRule(ctx *gin.Context) (model.Rule, bool) {
+	var rule model.Rule
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 ID 参数"})
+		return rule, false
+	}
+	if err := database.GetDB().First(&rule, id).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "规则不存在"})
+		return rule, false
+	}
+	if rule.Type != scheduler.RuleTypeAnomaly {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "not an anomaly rule"})
+		return rule, false
+	}
+	return rule, true
+}
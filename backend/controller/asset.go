@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/inventory"
+	"github.com/laenix/vsentry/model"
+)
+
+var assetListSpec = listSpec{
+	table: "assets",
+	fields: map[string]listField{
+		"id":          {column: "assets.id", kind: fieldNumber},
+		"kind":        {column: "assets.kind", kind: fieldString},
+		"name":        {column: "assets.name", kind: fieldText},
+		"scope":       {column: "assets.scope", kind: fieldString},
+		"ip":          {column: "assets.ips", kind: fieldText},
+		"os":          {column: "assets.os", kind: fieldText},
+		"host":        {column: "assets.hosts", kind: fieldText},
+		"sid":         {column: "assets.sid", kind: fieldString},
+		"source":      {column: "assets.sources", kind: fieldText},
+		"ingest":      {column: "assets.ingest_id", kind: fieldNumber},
+		"owner":       {column: "assets.owner", kind: fieldText},
+		"criticality": {column: "assets.criticality", kind: fieldString},
+		"environment": {column: "assets.environment", kind: fieldString},
+		"manual":      {column: "assets.manual", kind: fieldBool},
+		"events":      {column: "assets.events", kind: fieldNumber},
+		"first_seen":  {column: "assets.first_seen", kind: fieldTime},
+		"last_seen":   {column: "assets.last_seen", kind: fieldTime},
+	},
+	sorts: map[string]listField{
+		"id":         {column: "assets.id", kind: fieldNumber},
+		"name":       {column: "assets.name", kind: fieldString},
+		"events":     {column: "assets.events", kind: fieldNumber},
+		"first_seen": {column: "assets.first_seen", kind: fieldTime},
+		"last_seen":  {column: "assets.last_seen", kind: fieldTime},
+	},
+	defaultSort: "-last_seen",
+	text:        []string{"assets.name", "assets.scope", "assets.ips", "assets.owner", "assets.notes"},
+}
+
+// ListAssets Get资产清单，支持通用过滤语言，如 kind:host criticality:critical last_seen:>=7d
+func ListAssets(ctx *gin.Context) {
+	var assets []model.Asset
+	page, err := queryList(ctx, database.GetDB().Model(&model.Asset{}), assetListSpec, &assets)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, pageResponse(assets, page))
+}
+
+// GetAsset Get资产Detail及关联的 Incident
+func GetAsset(ctx *gin.Context) {
+	db := database.GetDB()
+	var asset model.Asset
+	if err := db.First(&asset, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "资产不存在"})
+		return
+	}
+	// Alert.Assets 为 AssetRef 数组，序列化后每个元素以 {"id":N,"kind" 开头
+	var incidentIDs []uint
+	db.Model(&model.Alert{}).Where("assets LIKE ? AND incident_id != 0", fmt.Sprintf(`%%{"id":%d,"kind"%%`, asset.ID)).
+		Distinct().Order("incident_id desc").Limit(50).Pluck("incident_id", &incidentIDs)
+	incidents := []model.Incident{}
+	if len(incidentIDs) > 0 {
+		db.Where("id IN ?", incidentIDs).Order("last_seen desc").Find(&incidents)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"asset": asset, "incidents": incidents}})
+}
+
+// assetRequest 人工添加/维护资产的字段
+type assetRequest struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Scope       string `json:"scope"`
+	IPs         string `json:"ips"`
+	OS          string `json:"os"`
+	Owner       string `json:"owner"`
+	Criticality string `json:"criticality"`
+	Environment string `json:"environment"`
+	Notes       string `json:"notes"`
+}
+
+// AddAsset 人工添加资产 (如尚未接入日志的重要服务器)，之后被发现时自动合并
+func AddAsset(ctx *gin.Context) {
+	var req assetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	asset := model.Asset{
+		Kind:        req.Kind,
+		IPs:         normalizeCSV(req.IPs),
+		OS:          req.OS,
+		Manual:      true,
+		Owner:       req.Owner,
+		Criticality: req.Criticality,
+		Environment: req.Environment,
+		Notes:       req.Notes,
+	}
+	switch req.Kind {
+	case model.AssetUser:
+		asset.Name, asset.Scope = inventory.UserName(req.Name, req.Scope)
+	case model.AssetHost, model.AssetService:
+		asset.Name, asset.Scope = strings.ToLower(strings.TrimSpace(req.Name)), strings.ToLower(strings.TrimSpace(req.Scope))
+	default:
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "kind 只能为 host、user 或 service"})
+		return
+	}
+	if asset.Name == "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "name 不能为空"})
+		return
+	}
+	if !inventory.ValidCriticality(asset.Criticality) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "criticality 只能为 low、medium、high 或 critical"})
+		return
+	}
+
+	db := database.GetDB()
+	var count int64
+	db.Model(&model.Asset{}).Where("kind = ? AND name = ? AND scope = ?", asset.Kind, asset.Name, asset.Scope).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "资产已存在"})
+		return
+	}
+	if err := db.Create(&asset).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "资产添加成功", "data": asset})
+}
+
+// UpdateAsset Update人工维护的属性 (负责人、重要性、环境、备注)，发现的字段只读
+func UpdateAsset(ctx *gin.Context) {
+	var req assetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if !inventory.ValidCriticality(req.Criticality) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "criticality 只能为 low、medium、high 或 critical"})
+		return
+	}
+	db := database.GetDB()
+	var asset model.Asset
+	if err := db.First(&asset, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "资产不存在"})
+		return
+	}
+	err := db.Model(&asset).Select("Owner", "Criticality", "Environment", "Notes").Updates(model.Asset{
+		Owner:       req.Owner,
+		Criticality: req.Criticality,
+		Environment: req.Environment,
+		Notes:       req.Notes,
+	}).Error
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "资产更新成功", "data": asset})
+}
+
+// DeleteAsset Delete资产，仍在Log中出现的资产会在下次同步时重New发现
+func DeleteAsset(ctx *gin.Context) {
+	result := database.GetDB().Unscoped().Delete(&model.Asset{}, ctx.Param("id"))
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "资产不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "资产删除成功"})
+}
+
+// SyncAssets 立即从 VictoriaLogs 同步资产清单
+func SyncAssets(ctx *gin.Context) {
+	result, err := inventory.Sync(time.Now().UTC())
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "同步失败: " + err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "资产清单已同步", "data": result})
+}
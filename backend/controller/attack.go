@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/attack"
+)
+
+type coverageRule struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	AlertCount int64  `json:"alert_count"` // 最近 N days产生的Alert数
+}
+
+type techniqueCoverage struct {
+	ID            string              `json:"id"`
+	Name          string              `json:"name"`
+	Rules         []coverageRule      `json:"rules"`
+	Covered       bool                `json:"covered"` // 自身或任一子技术有Enable的Rule
+	Fired         bool                `json:"fired"`   // 最近 N days内有Rule命Medium
+	AlertCount    int64               `json:"alert_count"`
+	SubTechniques []techniqueCoverage `json:"sub_techniques,omitempty"`
+}
+
+type tacticCoverage struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	ShortName  string              `json:"shortname"`
+	Rules      []coverageRule      `json:"rules"` // 只标注了 tactic 的Rule
+	Techniques []techniqueCoverage `json:"techniques"`
+}
+
+type coverageSummary struct {
+	Techniques int     `json:"techniques"`
+	Covered    int     `json:"covered"`
+	Fired      int     `json:"fired"`
+	Uncovered  int     `json:"uncovered"`
+	Percent    float64 `json:"coverage_percent"`
+}
+
+type attackCoverage struct {
+	Days      int              `json:"days"`
+	Version   string           `json:"attack_version"`
+	Summary   coverageSummary  `json:"summary"`
+	Tactics   []tacticCoverage `json:"tactics"`
+	Uncovered []string         `json:"uncovered"`
+}
+
+// ListAttackTactics Get ATT&CK tactic List
+func ListAttackTactics(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": attack.Default().Tactics})
+}
+
+// ListAttackTechniques Get ATT&CK technique List，支持 tactic / q 过滤（ago端选择器用）
+func ListAttackTechniques(ctx *gin.Context) {
+	ds := attack.Default()
+	tactic := ctx.Query("tactic")
+	q := strings.ToLower(ctx.Query("q"))
+
+	result := make([]attack.Technique, 0, len(ds.Techniques))
+	for _, t := range ds.Techniques {
+		if tactic != "" {
+			tac, ok := ds.Tactic(tactic)
+			if !ok || !containsString(t.Tactics, tac.ShortName) {
+				continue
+			}
+		}
+		if q != "" && !strings.Contains(strings.ToLower(t.ID+" "+t.Name), q) {
+			continue
+		}
+		result = append(result, t)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": result})
+}
+
+// GetAttackCoverage Get检测覆盖矩阵
+// GET /attack/coverage?days=30
+func GetAttackCoverage(ctx *gin.Context) {
+	coverage, err := buildAttackCoverage(coverageDays(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": coverage})
+}
+
+// ExportAttackNavigator Export ATT&CK Navigator layer JSON
+// GET /attack/navigator?days=30
+// score: 1 = 有Enable的检测Rule，2 = 最近 N days内有Rule命Medium
+func ExportAttackNavigator(ctx *gin.Context) {
+	days := coverageDays(ctx)
+	coverage, err := buildAttackCoverage(days)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	techniques := make([]gin.H, 0)
+	seen := make(map[string]bool)
+	var add func(tc techniqueCoverage, tactic string)
+	add = func(tc techniqueCoverage, tactic string) {
+		if len(tc.Rules) > 0 && !seen[tc.ID+"/"+tactic] {
+			seen[tc.ID+"/"+tactic] = true
+			score := 1
+			if tc.Fired {
+				score = 2
+			}
+			names := make([]string, len(tc.Rules))
+			for i, r := range tc.Rules {
+				names[i] = r.Name
+			}
+			techniques = append(techniques, gin.H{
+				"techniqueID":       tc.ID,
+				"tactic":            tactic,
+				"score":             score,
+				"comment":           fmt.Sprintf("%d rule(s), %d alert(s) in last %d days", len(tc.Rules), tc.AlertCount, days),
+				"enabled":           true,
+				"showSubtechniques": len(tc.SubTechniques) > 0,
+				"metadata":          []gin.H{{"name": "rules", "value": strings.Join(names, ", ")}},
+			})
+		}
+		for _, sub := range tc.SubTechniques {
+			add(sub, tactic)
+		}
+	}
+	for _, tactic := range coverage.Tactics {
+		for _, tc := range tactic.Techniques {
+			add(tc, tactic.ShortName)
+		}
+	}
+
+	layer := gin.H{
+		"name":        "VSentry Detection Coverage",
+		"description": fmt.Sprintf("Enabled detection rules mapped to ATT&CK, alerts from the last %d days", days),
+		"domain":      "enterprise-attack",
+		"versions": gin.H{
+			"attack":    coverage.Version,
+			"navigator": "4.9.1",
+			"layer":     "4.5",
+		},
+		"sorting":                       0,
+		"hideDisabled":                  false,
+		"techniques":                    techniques,
+		"selectTechniquesAcrossTactics": true,
+		"gradient": gin.H{
+			"colors":   []string{"#ffffff", "#8ec843", "#ff6666"},
+			"minValue": 0,
+			"maxValue": 2,
+		},
+		"legendItems": []gin.H{
+			{"label": "Enabled rule", "color": "#8ec843"},
+			{"label": "Fired in period", "color": "#ff6666"},
+		},
+	}
+
+	ctx.Header("Content-Disposition", "attachment; filename=vsentry-attack-layer.json")
+	ctx.JSON(http.StatusOK, layer)
+}
+
+func coverageDays(ctx *gin.Context) int {
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return days
+}
+
+// buildAttackCoverage 汇总Enable的Rule、Rule标签和最近 N days的Alert，生成覆盖矩阵
+func buildAttackCoverage(days int) (*attackCoverage, error) {
+	db := database.GetDB()
+	ds := attack.Default()
+
+	// 1. Enable的检测Rule（InvestigationRule不算检测覆盖）
+	var rules []model.Rule
+	if err := db.Where("enabled = ? AND type != ?", true, "investigation").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	database.FillRuleTags(db, rules)
+
+	// 2. 最近 N days every条Rule的Alert数
+	var fired []struct {
+		RuleID uint
+		Count  int64
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	db.Model(&model.Alert{}).Select("rule_id, COUNT(*) AS count").
+		Where("created_at >= ?", since).Group("rule_id").Scan(&fired)
+	alertsByRule := make(map[uint]int64)
+	for _, f := range fired {
+		alertsByRule[f.RuleID] = f.Count
+	}
+
+	// 3. technique / tactic -> Rule
+	byTechnique := make(map[string][]coverageRule)
+	byTactic := make(map[string][]coverageRule)
+	for _, r := range rules {
+		cr := coverageRule{ID: r.ID, Name: r.Name, Type: r.Type, AlertCount: alertsByRule[r.ID]}
+		mapping := ds.Resolve(r.Tags)
+		for _, id := range mapping.Techniques {
+			byTechnique[id] = append(byTechnique[id], cr)
+		}
+		if len(mapping.Techniques) == 0 {
+			for _, id := range mapping.Tactics {
+				byTactic[id] = append(byTactic[id], cr)
+			}
+		}
+	}
+
+	// 4. 组装矩阵：父技术下挂子技术
+	subs := make(map[string][]attack.Technique)
+	for _, t := range ds.Techniques {
+		if t.IsSubTechnique() {
+			subs[t.Parent] = append(subs[t.Parent], t)
+		}
+	}
+
+	coverage := &attackCoverage{Days: days, Version: ds.Version, Uncovered: make([]string, 0)}
+	counted := make(map[string]bool)
+	build := func(t attack.Technique) techniqueCoverage {
+		tc := techniqueCoverage{ID: t.ID, Name: t.Name, Rules: byTechnique[t.ID]}
+		if tc.Rules == nil {
+			tc.Rules = make([]coverageRule, 0)
+		}
+		for _, r := range tc.Rules {
+			tc.AlertCount += r.AlertCount
+		}
+		tc.Covered = len(tc.Rules) > 0
+		tc.Fired = tc.AlertCount > 0
+		return tc
+	}
+
+	for _, tactic := range ds.Tactics {
+		tcov := tacticCoverage{ID: tactic.ID, Name: tactic.Name, ShortName: tactic.ShortName, Rules: byTactic[tactic.ID]}
+		if tcov.Rules == nil {
+			tcov.Rules = make([]coverageRule, 0)
+		}
+		for _, t := range ds.Techniques {
+			if t.IsSubTechnique() || !containsString(t.Tactics, tactic.ShortName) {
+				continue
+			}
+			parent := build(t)
+			for _, st := range subs[t.ID] {
+				child := build(st)
+				parent.Covered = parent.Covered || child.Covered
+				parent.Fired = parent.Fired || child.Fired
+				parent.SubTechniques = append(parent.SubTechniques, child)
+				countTechnique(coverage, counted, child)
+			}
+			countTechnique(coverage, counted, parent)
+			tcov.Techniques = append(tcov.Techniques, parent)
+		}
+		coverage.Tactics = append(coverage.Tactics, tcov)
+	}
+
+	sort.Strings(coverage.Uncovered)
+	if coverage.Summary.Techniques > 0 {
+		coverage.Summary.Percent = float64(coverage.Summary.Covered) * 100 / float64(coverage.Summary.Techniques)
+	}
+	return coverage, nil
+}
+
+// countTechnique 统计汇总（同一 technique 可能出现在多个 tactic 下，只计一次）
+func countTechnique(c *attackCoverage, counted map[string]bool, tc techniqueCoverage) {
+	if counted[tc.ID] {
+		return
+	}
+	counted[tc.ID] = true
+	c.Summary.Techniques++
+	if tc.Covered {
+		c.Summary.Covered++
+	} else {
+		c.Summary.Uncovered++
+		c.Uncovered = append(c.Uncovered, tc.ID)
+	}
+	if tc.Fired {
+		c.Summary.Fired++
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -53,6 +53,9 @@ func RunPlaybook(ctx *gin.Context) {
 
 	// 4. StartEngine
 	engine := automation.NewEngine()
+	if userID, ok := ctx.Get("userid"); ok {
+		engine.ActorID = userID.(uint)
+	}
 	executionID, err := engine.Run(uint(playbookID), inputContext)
 
 	if err != nil {
@@ -157,9 +160,34 @@ func GetExecutionDetail(ctx *gin.Context) {
 	})
 }
 
-// ListAllExecutions
+// executionListSpec ListAllExecutions 支持的过滤和排序字段
+var executionListSpec = listSpec{
+	table: "playbook_executions",
+	fields: map[string]listField{
+		"id":       {column: "playbook_executions.id", kind: fieldNumber},
+		"status":   {column: "playbook_executions.status", kind: fieldString},
+		"playbook": {column: "playbook_executions.playbook_id", kind: fieldNumber},
+		"incident": {column: "playbook_executions.trigger_context_id", kind: fieldNumber},
+		"started":  {column: "playbook_executions.start_time", kind: fieldTime},
+		"duration": {column: "playbook_executions.duration", kind: fieldNumber},
+	},
+	sorts: map[string]listField{
+		"id":       {column: "playbook_executions.id", kind: fieldNumber},
+		"started":  {column: "playbook_executions.start_time", kind: fieldTime},
+		"duration": {column: "playbook_executions.duration", kind: fieldNumber},
+	},
+	defaultSort: "-id",
+	text:        []string{"playbook_executions.logs"},
+}
+
+// ListAllExecutions Get所有Playbook的Execute记录，过滤、排序和翻页参数见 queryList
+// GET /playbooks/executions?q=status:failed playbook:3 started:>=7d&limit=50
 func ListAllExecutions(ctx *gin.Context) {
 	var executions []model.PlaybookExecution
-	database.GetDB().Order("id desc").Limit(100).Find(&executions)
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": executions})
+	page, err := queryList(ctx, database.GetDB().Model(&model.PlaybookExecution{}), executionListSpec, &executions)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, pageResponse(executions, page))
 }
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// ListBacktraceJobs Get回溯TaskList，可按 rule_id / status 过滤
+func ListBacktraceJobs(ctx *gin.Context) {
+	db := database.GetDB().Model(&model.BacktraceJob{})
+	if ruleID := ctx.Query("rule_id"); ruleID != "" {
+		db = db.Where("rule_id = ?", ruleID)
+	}
+	if status := ctx.Query("status"); status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var jobs []model.BacktraceJob
+	db.Order("id desc").Limit(100).Find(&jobs)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": jobs})
+}
+
+// GetBacktraceJob Get单个回溯Task的进度 (ago端轮询用)
+func GetBacktraceJob(ctx *gin.Context) {
+	var job model.BacktraceJob
+	if err := database.GetDB().First(&job, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "Backtrace job not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": job})
+}
+
+// CreateBacktraceJob 手动为Rule发起一次回溯
+func CreateBacktraceJob(ctx *gin.Context) {
+	var req struct {
+		RuleID uint `json:"rule_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 rule_id 参数"})
+		return
+	}
+
+	job, err := scheduler.TriggerBacktrace(req.RuleID)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "回溯任务已排队", "data": job})
+}
+
+// CancelBacktraceJob CancelTask
+func CancelBacktraceJob(ctx *gin.Context) {
+	controlBacktraceJob(ctx, scheduler.GlobalBacktrace.Cancel, "回溯任务已取消")
+}
+
+// PauseBacktraceJob 暂停Task，已完成的days数会Save为检查点
+func PauseBacktraceJob(ctx *gin.Context) {
+	controlBacktraceJob(ctx, scheduler.GlobalBacktrace.Pause, "回溯任务已暂停")
+}
+
+// ResumeBacktraceJob 从检查点恢复Task
+func ResumeBacktraceJob(ctx *gin.Context) {
+	controlBacktraceJob(ctx, scheduler.GlobalBacktrace.Resume, "回溯任务已恢复")
+}
+
+func controlBacktraceJob(ctx *gin.Context, action func(uint) error, okMsg string) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid job ID"})
+		return
+	}
+	if err := action(uint(id)); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": okMsg})
+}
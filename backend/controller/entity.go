@@ -0,0 +1,395 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/inventory"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/observable"
+	"github.com/laenix/vsentry/pkg/filter"
+	"github.com/laenix/vsentry/pkg/logsql"
+	"github.com/spf13/viper"
+)
+
+// 实体Type，与 Observable Type同名
+var entityTypes = []string{model.ObservableHost, model.ObservableUser, model.ObservableIP}
+
+// defaultEntityFields 各实体Type对应的 OCSF 字段，可用 entity.fields.<type> 覆盖
+var defaultEntityFields = map[string][]string{
+	model.ObservableHost: {"observer.hostname", "device.hostname", "src_endpoint.hostname", "dst_endpoint.hostname"},
+	model.ObservableUser: {"actor.user.name", "target.user.name", "target_user.name", "user.name"},
+	model.ObservableIP:   {"src_endpoint.ip", "dst_endpoint.ip", "observer.ip", "device.ip"},
+}
+
+// histogramSteps 活动直方图可选的桶宽
+var histogramSteps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+	3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+var entityClient = &http.Client{Timeout: 30 * time.Second}
+
+func entityFields(typ string) []string {
+	if fields := viper.GetStringSlice("entity.fields." + typ); len(fields) > 0 {
+		return fields
+	}
+	return defaultEntityFields[typ]
+}
+
+func entityInt(key string, def int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return def
+}
+
+// entityRequest 解析实体Type、值和Time范围 (start/end 支持绝对Time或 24h、7d 这类相对Time)
+type entityRequest struct {
+	Type   string
+	Value  string
+	Fields []string
+	Start  time.Time
+	End    time.Time
+	Filter string
+}
+
+func parseEntityRequest(ctx *gin.Context) (*entityRequest, bool) {
+	req := &entityRequest{Type: ctx.Param("type"), Value: strings.TrimSpace(ctx.Query("value"))}
+	req.Fields = entityFields(req.Type)
+	if len(req.Fields) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "不支持的实体类型，可选 " + strings.Join(entityTypes, " / ")})
+		return nil, false
+	}
+	if req.Value == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 value"})
+		return nil, false
+	}
+
+	now := time.Now().UTC()
+	req.End = now
+	if v := ctx.Query("end"); v != "" {
+		t, err := filter.ParseTime(v, now)
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "end: " + err.Error()})
+			return nil, false
+		}
+		req.End = t
+	}
+	rangeDur := viper.GetDuration("entity.default_range")
+	if rangeDur <= 0 {
+		rangeDur = 24 * time.Hour
+	}
+	req.Start = req.End.Add(-rangeDur)
+	if v := ctx.Query("start"); v != "" {
+		t, err := filter.ParseTime(v, now)
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "start: " + err.Error()})
+			return nil, false
+		}
+		req.Start = t
+	}
+	if !req.End.After(req.Start) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "end 必须晚于 start"})
+		return nil, false
+	}
+	req.Filter = logsql.AnyFieldEquals(req.Fields, req.Value)
+	return req, true
+}
+
+// queryLogs 在 [start, end] 范围内Execute LogSQL，Return每行Log
+func queryLogs(query string, start, end time.Time, limit int) ([]map[string]interface{}, error) {
+	vlURL := viper.GetString("victorialogs.url")
+	if vlURL == "" {
+		vlURL = "http://localhost:9428"
+	}
+	resp, err := entityClient.PostForm(vlURL+"/select/logsql/query", url.Values{
+		"query": {query},
+		"start": {start.UTC().Format(time.RFC3339Nano)},
+		"end":   {end.UTC().Format(time.RFC3339Nano)},
+		"limit": {strconv.Itoa(limit)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("VictoriaLogs unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("query error (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	rows := []map[string]interface{}{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var row map[string]interface{}
+		if json.Unmarshal([]byte(line), &row) == nil {
+			rows = append(rows, row)
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// entityPeer 与实体同时出现的其他实体
+type entityPeer struct {
+	Value  string   `json:"value"`
+	Fields []string `json:"fields"`
+	Count  int64    `json:"count"`
+}
+
+// histogramBucket 活动直方图的一个桶
+type histogramBucket struct {
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+}
+
+// GetEntity 实体概览：资产清单条目、相关 Incident 和 Observable、交互最多的主机/用户/IP 以及活动直方图
+// GET /entities/:type?value=10.0.0.5&start=7d&end=now
+func GetEntity(ctx *gin.Context) {
+	req, ok := parseEntityRequest(ctx)
+	if !ok {
+		return
+	}
+	db := database.GetDB()
+
+	// Observable 中的值已规范化 (主机名小写、IP 标准格式)
+	_, normalized := observable.Normalize(req.Type, req.Value)
+	if normalized == "" {
+		normalized = req.Value
+	}
+	observables := []model.Observable{}
+	db.Where("type = ? AND value = ?", req.Type, normalized).Order("last_seen desc").Limit(100).Find(&observables)
+	incidents := []model.Incident{}
+	if len(observables) > 0 {
+		ids := make([]uint, 0, len(observables))
+		for _, o := range observables {
+			ids = append(ids, o.IncidentID)
+		}
+		db.Where("id IN ? AND merged_into = ?", ids, 0).Order("last_seen desc").Limit(50).Find(&incidents)
+	}
+	assets := inventory.Match(db, entityContent(req))
+
+	peerLimit := entityInt("entity.peers", 10)
+	peers := make(map[string][]entityPeer, len(entityTypes))
+	histogram := []histogramBucket{}
+	step := histogramStep(req.End.Sub(req.Start), entityInt("entity.buckets", 60))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+	for _, typ := range entityTypes {
+		for _, field := range entityFields(typ) {
+			wg.Add(1)
+			go func(typ, field string) {
+				defer wg.Done()
+				query := fmt.Sprintf("%s | stats by (%s) count() hits | sort by (hits desc) limit %d", req.Filter, field, peerLimit+1)
+				rows, err := queryLogs(query, req.Start, req.End, peerLimit+1)
+				if err != nil {
+					fail(err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, row := range rows {
+					value := fmt.Sprint(row[field])
+					if row[field] == nil || value == "" || (typ == req.Type && strings.EqualFold(value, req.Value)) {
+						continue
+					}
+					count, _ := strconv.ParseInt(fmt.Sprint(row["hits"]), 10, 64)
+					peers[typ] = addPeer(peers[typ], value, field, count)
+				}
+			}(typ, field)
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		query := fmt.Sprintf("%s | stats by (_time:%s) count() hits", req.Filter, formatStep(step))
+		rows, err := queryLogs(query, req.Start, req.End, int(req.End.Sub(req.Start)/step)+2)
+		if err != nil {
+			fail(err)
+			return
+		}
+		buckets := make([]histogramBucket, 0, len(rows))
+		for _, row := range rows {
+			t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(row["_time"]))
+			if err != nil {
+				continue
+			}
+			count, _ := strconv.ParseInt(fmt.Sprint(row["hits"]), 10, 64)
+			buckets = append(buckets, histogramBucket{Time: t.UTC(), Count: count})
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Time.Before(buckets[j].Time) })
+		histogram = buckets
+	}()
+	wg.Wait()
+	if firstErr != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": firstErr.Error()})
+		return
+	}
+
+	for typ, list := range peers {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Value < list[j].Value
+		})
+		if len(list) > peerLimit {
+			list = list[:peerLimit]
+		}
+		for _, p := range list {
+			sort.Strings(p.Fields)
+		}
+		peers[typ] = list
+	}
+	for _, typ := range entityTypes {
+		if peers[typ] == nil {
+			peers[typ] = []entityPeer{}
+		}
+	}
+	var total int64
+	for _, b := range histogram {
+		total += b.Count
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"entity":      gin.H{"type": req.Type, "value": req.Value, "fields": req.Fields},
+		"start":       req.Start,
+		"end":         req.End,
+		"events":      total,
+		"assets":      assets,
+		"incidents":   incidents,
+		"observables": observables,
+		"peers":       peers,
+		"histogram":   gin.H{"step": formatStep(step), "buckets": histogram},
+	}})
+}
+
+// entityContent 构造只含该实体的Event，复用 inventory.Match 的 Observable 提取
+func entityContent(req *entityRequest) string {
+	field := map[string]string{
+		model.ObservableHost: "observer.hostname",
+		model.ObservableUser: "actor.user.name",
+		model.ObservableIP:   "src_endpoint.ip",
+	}[req.Type]
+	raw, _ := json.Marshal(map[string]string{field: req.Value})
+	return string(raw)
+}
+
+func addPeer(list []entityPeer, value, field string, count int64) []entityPeer {
+	for i := range list {
+		if list[i].Value == value {
+			list[i].Count += count
+			list[i].Fields = append(list[i].Fields, field)
+			return list
+		}
+	}
+	return append(list, entityPeer{Value: value, Fields: []string{field}, Count: count})
+}
+
+// histogramStep 选择使桶数不超过 buckets 的最小桶宽
+func histogramStep(span time.Duration, buckets int) time.Duration {
+	for _, step := range histogramSteps {
+		if span/step <= time.Duration(buckets) {
+			return step
+		}
+	}
+	return histogramSteps[len(histogramSteps)-1]
+}
+
+// formatStep 桶宽的 LogSQL 写法，如 5m、1h、1d
+func formatStep(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// timelineCursor 上一页最后一条Event的Time，以及该时刻已Return的条数
+type timelineCursor struct {
+	Time   time.Time `json:"t"`
+	Offset int       `json:"o"`
+}
+
+// GetEntityTimeline 实体在全部日志流中的Event，按Time倒序分页；next_cursor 为空表示已到 start
+// GET /entities/:type/timeline?value=alice&start=24h&limit=200&cursor=<next_cursor>
+func GetEntityTimeline(ctx *gin.Context) {
+	req, ok := parseEntityRequest(ctx)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "200"))
+	if limit <= 0 || limit > maxPageSize {
+		limit = 200
+	}
+
+	var cur timelineCursor
+	if c := ctx.Query("cursor"); c != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(c)
+		if err != nil || json.Unmarshal(raw, &cur) != nil || cur.Time.IsZero() {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "cursor 无效"})
+			return
+		}
+		// 同一时刻的Event可能跨页，从该时刻开始并跳过已Return的条数
+		req.End = cur.Time
+	}
+
+	query := fmt.Sprintf("%s | sort by (_time desc) offset %d limit %d", req.Filter, cur.Offset, limit+1)
+	rows, err := queryLogs(query, req.Start, req.End, limit+1)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": err.Error()})
+		return
+	}
+
+	next := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := fmt.Sprint(rows[limit-1]["_time"])
+		t, err := time.Parse(time.RFC3339Nano, last)
+		if err == nil {
+			n := cur.Offset
+			if !t.Equal(cur.Time) {
+				n = 0
+			}
+			for _, row := range rows {
+				if fmt.Sprint(row["_time"]) == last {
+					n++
+				}
+			}
+			raw, _ := json.Marshal(timelineCursor{Time: t, Offset: n})
+			next = base64.RawURLEncoding.EncodeToString(raw)
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": rows, "next_cursor": next,
+		"entity": gin.H{"type": req.Type, "value": req.Value, "fields": req.Fields}})
+}
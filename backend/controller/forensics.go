@@ -15,7 +15,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/live"
 	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/laenix/vsentry/observable"
+	"github.com/laenix/vsentry/scheduler"
 	"github.com/spf13/viper"
 	"github.com/laenix/vsentry/forensic"
 )
@@ -76,6 +80,7 @@ func UploadForensicFile(ctx *gin.Context) {
 		ParseStatus:  "pending",
 	}
 	db.Create(&forensicFile)
+	live.Publish(live.ForensicProgress, 0, 0, "", forensicFile)
 
 	// 🔥 核心：StartAsyncParse协程，不阻塞ago端Response
 	go processForensicFile(forensicFile)
@@ -89,13 +94,12 @@ func UploadForensicFile(ctx *gin.Context) {
 
 // processForensicFile AsyncFileParse分发器
 func processForensicFile(f model.ForensicFile) {
-	db := database.GetDB()
-	db.Model(&f).Update("parse_status", "parsing")
+	updateForensicFile(&f, map[string]interface{}{"parse_status": "parsing"})
 
 	// 1. 调用同级包的工厂Method
 	p, err := forensic.GetParser(f.FileType)
 	if err != nil {
-		db.Model(&f).Updates(map[string]interface{}{
+		updateForensicFile(&f, map[string]interface{}{
 			"parse_status":  "failed",
 			"parse_message": err.Error(),
 		})
@@ -105,7 +109,7 @@ func processForensicFile(f model.ForensicFile) {
 	// 2. Execute真正的硬核Parse
 	parsedEvents, err := p.Parse(f.FilePath)
 	if err != nil {
-		db.Model(&f).Updates(map[string]interface{}{
+		updateForensicFile(&f, map[string]interface{}{
 			"parse_status":  "failed",
 			"parse_message": err.Error(),
 		})
@@ -136,14 +140,14 @@ func processForensicFile(f model.ForensicFile) {
 
 	resp, postErr := http.Post(ingestURL, "application/x-ndjson", &jsonlBuffer)
 	if postErr != nil || resp.StatusCode >= 400 {
-		db.Model(&f).Updates(map[string]interface{}{
+		updateForensicFile(&f, map[string]interface{}{
 			"parse_status":  "failed",
 			"parse_message": "Failed to inject into VictoriaLogs",
 		})
 		return
 	}
 
-	db.Model(&f).Updates(map[string]interface{}{
+	updateForensicFile(&f, map[string]interface{}{
 		"parse_status":  "completed",
 		"event_count":   len(parsedEvents),
 		"parse_message": "Successfully parsed and injected.",
@@ -153,6 +157,12 @@ func processForensicFile(f model.ForensicFile) {
 	go triggerForensicRules(f.TaskID, f.ID)
 }
 
+// updateForensicFile UpdateParse进度并推送 forensic.progress
+func updateForensicFile(f *model.ForensicFile, updates map[string]interface{}) {
+	database.GetDB().Model(f).Updates(updates)
+	live.Publish(live.ForensicProgress, 0, 0, "", f)
+}
+
 // triggerForensicRules 触发ForensicsRule
 func triggerForensicRules(caseID, fileID uint) {
 	db := database.GetDB()
@@ -226,11 +236,19 @@ func saveForensicAlert(rule model.Rule, caseID, fileID uint, matchedData []map[s
 		LastSeen:   now,
 		AlertCount: len(matchedData),
 	}
+	scheduler.InheritAttack(&incident)
+	database.ApplySLA(db, &incident)
 
 	if err := db.Create(&incident).Error; err != nil {
 		log.Printf("[Forensic] Failed to create incident: %v", err)
 		return
 	}
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: incident.ID,
+		Type:       model.ActivityCreated,
+		To:         incident.Status,
+		Detail:     fmt.Sprintf("forensic rule %q matched case %d", rule.Name, caseID),
+	})
 
 	// Create Alert
 	for _, data := range matchedData {
@@ -241,10 +259,13 @@ func saveForensicAlert(rule model.Rule, caseID, fileID uint, matchedData []map[s
 			Content:     string(content),
 			Fingerprint: fmt.Sprintf("%d-%d-%s", rule.ID, fileID, string(content[:min(len(content), 100)])),
 		}
-		db.Create(&alert)
+		if db.Create(&alert).Error == nil {
+			observable.Record(db, alert)
+		}
 	}
 
 	log.Printf("[Forensic] Created incident %d with %d alerts for rule %d", incident.ID, len(matchedData), rule.ID)
+	go notify.Incident(model.NotifyIncidentCreated, incident, fmt.Sprintf("forensic rule %q matched case %d", rule.Name, caseID))
 }
 
 // ListForensicTasks Get所有ForensicsCaseList
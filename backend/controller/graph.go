@@ -0,0 +1,463 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/filter"
+	"github.com/laenix/vsentry/pkg/logsql"
+	"github.com/laenix/vsentry/scheduler"
+	"github.com/spf13/viper"
+)
+
+// graphExpansion 一种扩展关系：在 Match 字段等于节点值的Event中，按 Field 聚合出目标节点。
+// Scope 不为空时目标节点同时记录该字段的值 (如进程所在主机)，扩展该节点时作为附加过滤条件
+type graphExpansion struct {
+	Name   string   `json:"name" mapstructure:"name"`
+	From   string   `json:"from" mapstructure:"from"`
+	To     string   `json:"to" mapstructure:"to"`
+	Match  []string `json:"match" mapstructure:"match"`
+	Field  string   `json:"field" mapstructure:"field"`
+	Scope  string   `json:"scope,omitempty" mapstructure:"scope"`
+	Filter string   `json:"filter,omitempty" mapstructure:"filter"`
+}
+
+// defaultGraphExpansions 未配置 graph.expansions 时使用
+var defaultGraphExpansions = []graphExpansion{
+	{Name: "logged_into", From: "user", To: "host", Match: []string{"target.user.name", "actor.user.name", "target_user.name", "user.name"}, Field: "observer.hostname", Filter: "class_uid:3002"},
+	{Name: "ran", From: "host", To: "process", Match: []string{"observer.hostname", "device.hostname"}, Field: "process.name", Scope: "observer.hostname"},
+	{Name: "connected_to", From: "process", To: "ip", Match: []string{"process.name"}, Field: "dst_endpoint.ip"},
+	{Name: "contacted_by", From: "ip", To: "host", Match: []string{"dst_endpoint.ip"}, Field: "observer.hostname"},
+}
+
+func graphExpansions() []graphExpansion {
+	var list []graphExpansion
+	if err := viper.UnmarshalKey("graph.expansions", &list); err == nil && len(list) > 0 {
+		return list
+	}
+	return defaultGraphExpansions
+}
+
+// graphNode 图中的实体；Scope 为进一步限定该节点的字段值 (如 process 所在主机)
+type graphNode struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Value     string            `json:"value"`
+	Scope     map[string]string `json:"scope,omitempty"`
+	Depth     int               `json:"depth"`
+	Seed      bool              `json:"seed"`
+	Expanded  bool              `json:"expanded"` // 已按全部适用的扩展关系展开，未展开的节点可再次请求
+	Count     int64             `json:"count"`
+	FirstSeen *time.Time        `json:"first_seen,omitempty"`
+	LastSeen  *time.Time        `json:"last_seen,omitempty"`
+}
+
+// graphEdge 两个节点间的关系，Count 为支撑该关系的Event数
+type graphEdge struct {
+	ID        string     `json:"id"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Relation  string     `json:"relation"`
+	Count     int64      `json:"count"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+}
+
+func nodeID(typ, value string, scope map[string]string) string {
+	id := typ + ":" + value
+	keys := make([]string, 0, len(scope))
+	for k := range scope {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		id += "|" + k + "=" + scope[k]
+	}
+	return id
+}
+
+// graphNodeRef 请求中引用的节点
+type graphNodeRef struct {
+	Type  string            `json:"type"`
+	Value string            `json:"value"`
+	Scope map[string]string `json:"scope"`
+}
+
+// BuildInvestigationGraph 从 Incident、Observable 或已有节点出发，按扩展关系在 VictoriaLogs 中逐层展开，
+// 受 depth 和 max_nodes 限制；UI 可把未展开的节点放入 nodes 再次请求以增量展开
+// POST /investigation/graph {"incident_id":12,"depth":2} 或 {"observable":{"type":"ip","value":"10.0.0.5"}}
+func BuildInvestigationGraph(ctx *gin.Context) {
+	var req struct {
+		IncidentID uint           `json:"incident_id"`
+		Observable *graphNodeRef  `json:"observable"`
+		Nodes      []graphNodeRef `json:"nodes"`
+		Depth      int            `json:"depth"`
+		MaxNodes   int            `json:"max_nodes"`
+		Expansions []string       `json:"expansions"` // 只使用这些扩展关系，空表示全部
+		Start      string         `json:"start"`
+		End        string         `json:"end"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+
+	expansions := graphExpansions()
+	if len(req.Expansions) > 0 {
+		wanted := make(map[string]bool, len(req.Expansions))
+		for _, name := range req.Expansions {
+			wanted[name] = true
+		}
+		selected := expansions[:0:0]
+		for _, e := range expansions {
+			if wanted[e.Name] {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) != len(wanted) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "未知的扩展关系", "data": expansions})
+			return
+		}
+		expansions = selected
+	}
+
+	maxDepth := entityInt("graph.max_depth", 4)
+	if req.Depth <= 0 {
+		req.Depth = 2
+		if len(req.Nodes) > 0 {
+			req.Depth = 1
+		}
+	}
+	if req.Depth > maxDepth {
+		req.Depth = maxDepth
+	}
+	maxNodes := entityInt("graph.max_nodes", 100)
+	if req.MaxNodes <= 0 || req.MaxNodes > maxNodes {
+		req.MaxNodes = maxNodes
+	}
+
+	// Time范围：Incident 默认前后各 2 小时，其他默认 entity.default_range
+	db := database.GetDB()
+	now := time.Now().UTC()
+	end := now
+	start := now.Add(-24 * time.Hour)
+	if d := viper.GetDuration("entity.default_range"); d > 0 {
+		start = now.Add(-d)
+	}
+	var seeds []graphNodeRef
+	var incident model.Incident
+	switch {
+	case len(req.Nodes) > 0:
+		seeds = req.Nodes
+	case req.Observable != nil:
+		seeds = []graphNodeRef{*req.Observable}
+	case req.IncidentID != 0:
+		if err := db.First(&incident, req.IncidentID).Error; err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "事件不存在"})
+			return
+		}
+		start, end = incident.FirstSeen.Add(-2*time.Hour), incident.LastSeen.Add(2*time.Hour)
+		seeds = incidentSeeds(incident.ID, expansions)
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "需要 incident_id、observable 或 nodes"})
+		return
+	}
+	if req.Start != "" || req.End != "" {
+		var err error
+		if req.End != "" {
+			if end, err = filter.ParseTime(req.End, now); err != nil {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "end: " + err.Error()})
+				return
+			}
+		}
+		if req.Start != "" {
+			if start, err = filter.ParseTime(req.Start, now); err != nil {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "start: " + err.Error()})
+				return
+			}
+		}
+	}
+	if !end.After(start) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "end 必须晚于 start"})
+		return
+	}
+
+	g := &investigationGraph{
+		nodes:      make(map[string]*graphNode),
+		edges:      make(map[string]*graphEdge),
+		maxNodes:   req.MaxNodes,
+		expansions: expansions,
+		start:      start,
+		end:        end,
+	}
+	var frontier []*graphNode
+	var seedIDs []string
+	for _, s := range seeds {
+		s.Value = strings.TrimSpace(s.Value)
+		if s.Type == "" || s.Value == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "节点需要 type 和 value"})
+			return
+		}
+		if n := g.add(s.Type, s.Value, s.Scope, 0); n != nil {
+			n.Seed = true
+			seedIDs = append(seedIDs, n.ID)
+			frontier = append(frontier, n)
+		}
+	}
+	if err := g.expand(frontier, req.Depth); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": err.Error()})
+		return
+	}
+
+	nodes, edges := g.result()
+	if incident.ID != 0 {
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incident.ID,
+			ActorID:    currentUserID(ctx),
+			Type:       model.ActivityQuery,
+			To:         "investigation graph",
+			Detail:     fmt.Sprintf("%s => %d nodes, %d edges (depth %d)", strings.Join(seedIDs, ", "), len(nodes), len(edges), req.Depth),
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"nodes":      nodes,
+		"edges":      edges,
+		"truncated":  g.truncated,
+		"start":      start,
+		"end":        end,
+		"expansions": expansions,
+	}})
+}
+
+// incidentSeeds 从 Incident 的Alert内容中取出扩展关系适用的起点 (保留日志中的原始大小写)
+func incidentSeeds(incidentID uint, expansions []graphExpansion) []graphNodeRef {
+	maxSeeds := entityInt("graph.max_seeds", 10)
+	var alerts []model.Alert
+	database.GetDB().Select("content").Where("incident_id = ? AND suppressed = ?", incidentID, false).
+		Order("id asc").Limit(50).Find(&alerts)
+
+	seen := make(map[string]bool)
+	var seeds []graphNodeRef
+	for _, a := range alerts {
+		var content map[string]interface{}
+		if json.Unmarshal([]byte(a.Content), &content) != nil {
+			continue
+		}
+		flat := make(map[string]string)
+		scheduler.FlattenEvent(content, "", flat)
+		for _, e := range expansions {
+			for _, field := range e.Match {
+				v := strings.TrimSpace(flat[field])
+				if v == "" || v == "-" || seen[e.From+":"+v] {
+					continue
+				}
+				if len(seeds) >= maxSeeds {
+					return seeds
+				}
+				seen[e.From+":"+v] = true
+				seeds = append(seeds, graphNodeRef{Type: e.From, Value: v})
+			}
+		}
+	}
+	return seeds
+}
+
+// investigationGraph 一次展开过程中的节点和边
+type investigationGraph struct {
+	nodes      map[string]*graphNode
+	order      []string
+	edges      map[string]*graphEdge
+	edgeOrder  []string
+	maxNodes   int
+	truncated  bool
+	expansions []graphExpansion
+	start, end time.Time
+}
+
+// add 添加节点，已存在时Return原节点，超出预算时Return nil
+func (g *investigationGraph) add(typ, value string, scope map[string]string, depth int) *graphNode {
+	id := nodeID(typ, value, scope)
+	if n, ok := g.nodes[id]; ok {
+		return n
+	}
+	if len(g.nodes) >= g.maxNodes {
+		g.truncated = true
+		return nil
+	}
+	n := &graphNode{ID: id, Type: typ, Value: value, Scope: scope, Depth: depth}
+	g.nodes[id] = n
+	g.order = append(g.order, id)
+	return n
+}
+
+// expansionRow 一次扩展Query的一行聚合结果
+type expansionRow struct {
+	value, scope string
+	count        int64
+	first, last  *time.Time
+}
+
+// expand 逐层展开，每层内的Query并发Execute
+func (g *investigationGraph) expand(frontier []*graphNode, depth int) error {
+	concurrency := entityInt("graph.concurrency", 4)
+	fanout := entityInt("graph.fanout", 10)
+	for level := 1; level <= depth && len(frontier) > 0 && !g.truncated; level++ {
+		type task struct {
+			node *graphNode
+			exp  graphExpansion
+			rows []expansionRow
+			err  error
+		}
+		var tasks []*task
+		for _, n := range frontier {
+			for _, e := range g.expansions {
+				if e.From == n.Type {
+					tasks = append(tasks, &task{node: n, exp: e})
+				}
+			}
+			n.Expanded = true
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for _, t := range tasks {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(t *task) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				t.rows, t.err = g.query(t.node, t.exp, fanout)
+			}(t)
+		}
+		wg.Wait()
+
+		// 按任务顺序合并，保证同样的输入得到同样的图
+		var next []*graphNode
+		for _, t := range tasks {
+			if t.err != nil {
+				return fmt.Errorf("%s: %v", t.exp.Name, t.err)
+			}
+			for _, row := range t.rows {
+				var scope map[string]string
+				if t.exp.Scope != "" && row.scope != "" {
+					scope = map[string]string{t.exp.Scope: row.scope}
+				}
+				target, existed := g.nodes[nodeID(t.exp.To, row.value, scope)]
+				if !existed {
+					if target = g.add(t.exp.To, row.value, scope, level); target == nil {
+						t.node.Expanded = false // 预算用尽，部分结果被丢弃
+						break
+					}
+					next = append(next, target)
+				}
+				if target == t.node {
+					continue
+				}
+				g.link(t.node, target, t.exp.Name, row)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// query 聚合与节点相关、按扩展字段分组的Event数和Time范围
+func (g *investigationGraph) query(n *graphNode, e graphExpansion, fanout int) ([]expansionRow, error) {
+	parts := []string{logsql.AnyFieldEquals(e.Match, n.Value)}
+	keys := make([]string, 0, len(n.Scope))
+	for k := range n.Scope {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, logsql.AnyFieldEquals([]string{k}, n.Scope[k]))
+	}
+	if e.Filter != "" {
+		parts = append(parts, "("+e.Filter+")")
+	}
+	by := e.Field
+	if e.Scope != "" {
+		by += ", " + e.Scope
+	}
+	query := fmt.Sprintf("%s | stats by (%s) count() hits, min(_time) first_seen, max(_time) last_seen | sort by (hits desc) limit %d",
+		strings.Join(parts, " "), by, fanout+1)
+	raw, err := queryLogs(query, g.start, g.end, fanout+1)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]expansionRow, 0, len(raw))
+	for _, r := range raw {
+		value := strings.TrimSpace(fmt.Sprint(r[e.Field]))
+		if r[e.Field] == nil || value == "" || value == "-" {
+			continue
+		}
+		row := expansionRow{value: value, first: rowTime(r["first_seen"]), last: rowTime(r["last_seen"])}
+		if e.Scope != "" && r[e.Scope] != nil {
+			row.scope = fmt.Sprint(r[e.Scope])
+		}
+		row.count, _ = strconv.ParseInt(fmt.Sprint(r["hits"]), 10, 64)
+		rows = append(rows, row)
+		if len(rows) >= fanout {
+			break
+		}
+	}
+	return rows, nil
+}
+
+func rowTime(v interface{}) *time.Time {
+	t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v))
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// link 添加或累加一条边，并更新两端节点的Event数和Time范围
+func (g *investigationGraph) link(from, to *graphNode, relation string, row expansionRow) {
+	id := from.ID + "->" + to.ID + ":" + relation
+	edge, ok := g.edges[id]
+	if !ok {
+		edge = &graphEdge{ID: id, From: from.ID, To: to.ID, Relation: relation}
+		g.edges[id] = edge
+		g.edgeOrder = append(g.edgeOrder, id)
+	}
+	edge.Count += row.count
+	edge.FirstSeen, edge.LastSeen = widen(edge.FirstSeen, edge.LastSeen, row.first, row.last)
+	for _, n := range []*graphNode{from, to} {
+		n.Count += row.count
+		n.FirstSeen, n.LastSeen = widen(n.FirstSeen, n.LastSeen, row.first, row.last)
+	}
+}
+
+func widen(first, last, f, l *time.Time) (*time.Time, *time.Time) {
+	if f != nil && (first == nil || f.Before(*first)) {
+		first = f
+	}
+	if l != nil && (last == nil || l.After(*last)) {
+		last = l
+	}
+	return first, last
+}
+
+func (g *investigationGraph) result() ([]*graphNode, []*graphEdge) {
+	nodes := make([]*graphNode, 0, len(g.order))
+	for _, id := range g.order {
+		nodes = append(nodes, g.nodes[id])
+	}
+	edges := make([]*graphEdge, 0, len(g.edgeOrder))
+	for _, id := range g.edgeOrder {
+		edges = append(edges, g.edges[id])
+	}
+	return nodes, edges
+}
@@ -1,18 +1,66 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/laenix/vsentry/database"
 	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/laenix/vsentry/scheduler"
+	"gorm.io/gorm"
 )
 
-// ListIncidents GetEventList（不带Detail，用于大屏展示）
+// incidentListSpec ListIncidents 支持的过滤和排序字段
+var incidentListSpec = listSpec{
+	table: "incidents",
+	fields: map[string]listField{
+		"id":             {column: "incidents.id", kind: fieldNumber},
+		"status":         {column: "incidents.status", kind: fieldString},
+		"severity":       {column: "incidents.severity", kind: fieldSeverity},
+		"rule":           {custom: ruleCondition("incidents.rule_id")},
+		"assignee":       {column: "incidents.assignee", kind: fieldUser},
+		"source":         {column: "incidents.source", kind: fieldString},
+		"name":           {column: "incidents.name", kind: fieldText},
+		"tactic":         {column: "incidents.tactics", kind: fieldText},
+		"technique":      {column: "incidents.techniques", kind: fieldText},
+		"classification": {column: "incidents.closing_classification", kind: fieldString},
+		"alerts":         {column: "incidents.alert_count", kind: fieldNumber},
+		"created":        {column: "incidents.created_at", kind: fieldTime},
+		"first_seen":     {column: "incidents.first_seen", kind: fieldTime},
+		"last_seen":      {column: "incidents.last_seen", kind: fieldTime},
+		"resolved":       {column: "incidents.resolved_at", kind: fieldTime},
+		"breached":       {column: "(incidents.ack_breached OR incidents.resolve_breached)", kind: fieldBool},
+		"observable":     {custom: observableCondition("incidents.id")},
+	},
+	sorts: map[string]listField{
+		"id":          {column: "incidents.id", kind: fieldNumber},
+		"created":     {column: "incidents.created_at", kind: fieldTime},
+		"first_seen":  {column: "incidents.first_seen", kind: fieldTime},
+		"last_seen":   {column: "incidents.last_seen", kind: fieldTime},
+		"alert_count": {column: "incidents.alert_count", kind: fieldNumber},
+	},
+	defaultSort: "-last_seen",
+	text:        []string{"incidents.name", "incidents.closing_comment"},
+}
+
+// ListIncidents GetEventList（不带Detail，用于大屏展示），合并后的墓碑记录需 include_merged=true 才Return
+// GET /incidents/list?q=status:new,acknowledged severity:>=high&sort=-last_seen&limit=100&cursor=&count=true
 func ListIncidents(ctx *gin.Context) {
 	var incidents []model.Incident
-	database.GetDB().Order("last_seen desc").Find(&incidents)
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": incidents})
+	query := database.GetDB().Model(&model.Incident{})
+	if ctx.Query("include_merged") != "true" {
+		query = query.Where("merged_into = ?", 0)
+	}
+	page, err := queryList(ctx, query, incidentListSpec, &incidents)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, pageResponse(incidents, page))
 }
 
 // GetIncidentDetail GetEvent及其关联的所有Evidence (Alerts)
@@ -28,12 +76,18 @@ func GetIncidentDetail(ctx *gin.Context) {
 	// 使用 Preload("Alerts") 自动Execute关联Query，Get该Event下的所有Evidence
 	// 使用 Preload("Rule") Get关联的RuleInfo（包括RuleType）
 	db := database.GetDB()
-	err := db.Preload("Alerts").Preload("Rule").First(&incident, id).Error
+	// Timeline 和评论按Time正序
+	err := db.Preload("Alerts").Preload("Rule").
+		Preload("Timeline", func(tx *gorm.DB) *gorm.DB { return tx.Order("id asc") }).
+		Preload("Comments", func(tx *gorm.DB) *gorm.DB { return tx.Order("id asc") }).
+		Preload("Observables", func(tx *gorm.DB) *gorm.DB { return tx.Order("count desc, id asc") }).
+		First(&incident, id).Error
 
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
 		return
 	}
+	incident.Links = database.IncidentLinks(db, incident.ID)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 200,
@@ -47,10 +101,19 @@ func AcknowledgeIncident(ctx *gin.Context) {
 	id := ctx.Query("id")
 	userID, _ := ctx.Get("userid") // 从 AuthMiddleware Get
 
-	database.GetDB().Model(&model.Incident{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":   "acknowledged",
-		"assignee": userID,
-	})
+	db := database.GetDB()
+	var incident model.Incident
+	if err := db.First(&incident, id).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	before := incident
+	updates := database.IncidentStatusUpdates(incident, "acknowledged", time.Now().UTC())
+	updates["assignee"] = userID
+	db.Model(&incident).Updates(updates)
+	actor := currentUserID(ctx)
+	logStatusChange(db, before, actor, "acknowledged", "")
+	logAssigneeChange(db, before, actor, actor)
 	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已受理该事件"})
 }
 
@@ -62,11 +125,161 @@ func ResolveIncident(ctx *gin.Context) {
 		Comment        string `json:"comment"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err == nil {
-		database.GetDB().Model(&model.Incident{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
-			"status":                 "resolved",
-			"closing_classification": req.Classification,
-			"closing_comment":        req.Comment,
-		})
+		db := database.GetDB()
+		var incident model.Incident
+		if err := db.First(&incident, req.ID).Error; err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+			return
+		}
+		before := incident
+		updates := database.IncidentStatusUpdates(incident, "resolved", time.Now().UTC())
+		updates["closing_classification"] = req.Classification
+		updates["closing_comment"] = req.Comment
+		db.Model(&incident).Updates(updates)
+		actor := currentUserID(ctx)
+		scheduler.CloseIncidentAlerts(incident.ID, req.Classification)
+		detail := req.Classification
+		if req.Comment != "" {
+			detail = strings.TrimSpace(detail + ": " + req.Comment)
+		}
+		logStatusChange(db, before, actor, "resolved", detail)
+		if before.Status != "resolved" {
+			go notify.Incident(model.NotifyIncidentResolved, incident, detail)
+		}
 		ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "事件已关闭"})
 	}
 }
+
+// UpdateIncident 指派、调整级别或Status，every项变更记入Timeline
+// POST /incidents/update {"id":1,"assignee":2,"severity":"high","status":"acknowledged"}
+func UpdateIncident(ctx *gin.Context) {
+	var req struct {
+		ID       uint    `json:"id"`
+		Assignee *uint   `json:"assignee"`
+		Severity *string `json:"severity"`
+		Status   *string `json:"status"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+
+	db := database.GetDB()
+	var incident model.Incident
+	if err := db.First(&incident, req.ID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Status != nil {
+		switch *req.Status {
+		case "new", "acknowledged", "resolved":
+		default:
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "status 只能为 new / acknowledged / resolved"})
+			return
+		}
+		if *req.Status != incident.Status {
+			for k, v := range database.IncidentStatusUpdates(incident, *req.Status, time.Now().UTC()) {
+				updates[k] = v
+			}
+		}
+	}
+	if req.Severity != nil {
+		if _, ok := severityLevels[*req.Severity]; !ok {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "severity 只能为 info / low / medium / high / critical"})
+			return
+		}
+		if *req.Severity != incident.Severity {
+			updates["severity"] = *req.Severity
+		}
+	}
+	if req.Assignee != nil {
+		if *req.Assignee != 0 && !userExists(*req.Assignee) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "指派的用户不存在"})
+			return
+		}
+		if *req.Assignee != incident.Assignee {
+			updates["assignee"] = *req.Assignee
+		}
+	}
+	if len(updates) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "没有变更", "data": incident})
+		return
+	}
+	before := incident
+	if err := db.Model(&incident).Updates(updates).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新事件失败"})
+		return
+	}
+
+	actor := currentUserID(ctx)
+	if v, ok := updates["status"].(string); ok {
+		if v == "resolved" {
+			scheduler.CloseIncidentAlerts(incident.ID, before.ClosingClassification)
+		}
+		logStatusChange(db, before, actor, v, "")
+	}
+	if v, ok := updates["severity"].(string); ok {
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incident.ID,
+			ActorID:    actor,
+			Type:       model.ActivitySeverity,
+			From:       before.Severity,
+			To:         v,
+		})
+		database.RefreshSLA(db, &incident)
+	}
+	if v, ok := updates["assignee"].(uint); ok {
+		logAssigneeChange(db, before, actor, v)
+	}
+
+	db.First(&incident, incident.ID)
+	if updates["status"] == "resolved" {
+		go notify.Incident(model.NotifyIncidentResolved, incident, "")
+	} else if v, ok := updates["severity"].(string); ok && scheduler.HigherSeverity(before.Severity, v) != before.Severity {
+		go notify.Incident(model.NotifyIncidentEscalated, incident, fmt.Sprintf("severity raised from %s to %s", before.Severity, v))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "事件已更新", "data": incident})
+}
+
+var severityLevels = map[string]bool{"info": true, "low": true, "medium": true, "high": true, "critical": true}
+
+func logStatusChange(db *gorm.DB, incident model.Incident, actor uint, status, detail string) {
+	if incident.Status == status && detail == "" {
+		return
+	}
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: incident.ID,
+		ActorID:    actor,
+		Type:       model.ActivityStatus,
+		From:       incident.Status,
+		To:         status,
+		Detail:     detail,
+	})
+}
+
+// logAssigneeChange Assignee记录为用户名，未指派为空
+func logAssigneeChange(db *gorm.DB, incident model.Incident, actor, assignee uint) {
+	if incident.Assignee == assignee {
+		return
+	}
+	names := userNames(db, incident.Assignee, assignee)
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: incident.ID,
+		ActorID:    actor,
+		Type:       model.ActivityAssignee,
+		From:       names[incident.Assignee],
+		To:         names[assignee],
+	})
+}
+
+func userNames(db *gorm.DB, ids ...uint) map[uint]string {
+	result := make(map[uint]string)
+	var users []model.User
+	db.Select("id", "user_name").Where("id IN ?", ids).Find(&users)
+	for _, u := range users {
+		result[u.ID] = u.UserName
+	}
+	return result
+}
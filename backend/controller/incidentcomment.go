@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+)
+
+// @username 提及
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.\-]+)`)
+
+// ListIncidentComments GetIncident的评论
+// GET /incidents/comments?id=1
+func ListIncidentComments(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少事件ID"})
+		return
+	}
+	var comments []model.IncidentComment
+	database.GetDB().Where("incident_id = ?", id).Order("id asc").Find(&comments)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": comments})
+}
+
+// AddIncidentComment 添加评论 (Markdown)，并记入Timeline
+// POST /incidents/comments {"incident_id":1,"body":"@alice 请确认该主机"}
+func AddIncidentComment(ctx *gin.Context) {
+	var req struct {
+		IncidentID uint   `json:"incident_id"`
+		Body       string `json:"body"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "评论内容不能为空"})
+		return
+	}
+	if len(req.Body) > 20000 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "评论内容过长"})
+		return
+	}
+
+	db := database.GetDB()
+	var incident model.Incident
+	if err := db.Select("id").First(&incident, req.IncidentID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+
+	comment := model.IncidentComment{
+		IncidentID: incident.ID,
+		Body:       req.Body,
+		Mentions:   []uint{},
+	}
+	if userID, ok := ctx.Get("userid"); ok {
+		comment.AuthorID = userID.(uint)
+		comment.Author = userNames(db, comment.AuthorID)[comment.AuthorID]
+	}
+
+	// 只记录存在的用户
+	var mentioned []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(req.Body, -1) {
+		mentioned = append(mentioned, m[1])
+	}
+	var mentionedNames []string
+	if len(mentioned) > 0 {
+		var users []model.User
+		db.Select("id", "user_name").Where("user_name IN ?", mentioned).Order("id asc").Find(&users)
+		for _, u := range users {
+			comment.Mentions = append(comment.Mentions, u.ID)
+			mentionedNames = append(mentionedNames, "@"+u.UserName)
+		}
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加评论失败"})
+		return
+	}
+	detail := ""
+	if len(mentionedNames) > 0 {
+		detail = fmt.Sprintf("mentioned %s", strings.Join(mentionedNames, ", "))
+	}
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: incident.ID,
+		ActorID:    comment.AuthorID,
+		Actor:      comment.Author,
+		Type:       model.ActivityComment,
+		Detail:     detail,
+		RefID:      comment.ID,
+	})
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "评论已添加", "data": comment})
+}
+
+// GetIncidentTimeline GetIncident的活动记录
+// GET /incidents/timeline?id=1
+func GetIncidentTimeline(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少事件ID"})
+		return
+	}
+	var activities []model.IncidentActivity
+	database.GetDB().Where("incident_id = ?", id).Order("id asc").Find(&activities)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": activities})
+}
@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/observable"
+	"github.com/laenix/vsentry/scheduler"
+	"gorm.io/gorm"
+)
+
+var linkTypes = map[string]bool{model.LinkDuplicateOf: true, model.LinkRelatedTo: true, model.LinkCausedBy: true}
+
+// MergeIncidents 把 source 的Alert并入 target，source 保留为指向 target 的墓碑 (resolved + merged_into)
+// POST /incidents/merge {"target_id":1,"source_ids":[2,3]}
+func MergeIncidents(ctx *gin.Context) {
+	var req struct {
+		TargetID  uint   `json:"target_id"`
+		SourceIDs []uint `json:"source_ids"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.TargetID == 0 || len(req.SourceIDs) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+
+	db := database.GetDB()
+	var target model.Incident
+	if err := db.First(&target, req.TargetID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	if target.MergedInto != 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": fmt.Sprintf("事件 #%d 已合并到 #%d", target.ID, target.MergedInto)})
+		return
+	}
+	var sources []model.Incident
+	db.Where("id IN ?", req.SourceIDs).Find(&sources)
+	if len(sources) != len(uniqueIDs(req.SourceIDs)) {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	for _, s := range sources {
+		switch {
+		case s.ID == target.ID:
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "不能将事件合并到自身"})
+			return
+		case s.MergedInto != 0:
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": fmt.Sprintf("事件 #%d 已合并到 #%d", s.ID, s.MergedInto)})
+			return
+		}
+	}
+
+	actor := currentUserID(ctx)
+	now := time.Now().UTC()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+		count, first, last, severity := target.AlertCount, target.FirstSeen, target.LastSeen, target.Severity
+		tactics, techniques := target.Tactics, target.Techniques
+		moved := 0
+		for _, s := range sources {
+			res := tx.Model(&model.Alert{}).Where("incident_id = ?", s.ID).Update("incident_id", target.ID)
+			if res.Error != nil {
+				return res.Error
+			}
+			moved += int(res.RowsAffected)
+			count += s.AlertCount
+			if !s.FirstSeen.IsZero() && (first.IsZero() || s.FirstSeen.Before(first)) {
+				first = s.FirstSeen
+			}
+			if s.LastSeen.After(last) {
+				last = s.LastSeen
+			}
+			severity = scheduler.HigherSeverity(severity, s.Severity)
+			tactics, techniques = unionCSV(tactics, s.Tactics), unionCSV(techniques, s.Techniques)
+
+			tomb := database.IncidentStatusUpdates(s, "resolved", now)
+			tomb["merged_into"] = target.ID
+			tomb["alert_count"] = 0
+			tomb["closing_comment"] = fmt.Sprintf("merged into #%d", target.ID)
+			if s.ClosingClassification == "" {
+				tomb["closing_classification"] = "Undetermined"
+			}
+			if err := tx.Model(&model.Incident{}).Where("id = ?", s.ID).Updates(tomb).Error; err != nil {
+				return err
+			}
+			database.LogIncidentActivity(tx, model.IncidentActivity{
+				IncidentID: s.ID,
+				ActorID:    actor,
+				Type:       model.ActivityMerged,
+				From:       s.Status,
+				To:         strconv.FormatUint(uint64(target.ID), 10),
+				Detail:     fmt.Sprintf("merged into #%d (%d alert(s) moved)", target.ID, res.RowsAffected),
+				RefID:      target.ID,
+			})
+			database.LogIncidentActivity(tx, model.IncidentActivity{
+				IncidentID: target.ID,
+				ActorID:    actor,
+				Type:       model.ActivityMerged,
+				From:       strconv.FormatUint(uint64(s.ID), 10),
+				Detail:     fmt.Sprintf("#%d %q merged in (%d alert(s))", s.ID, s.Name, res.RowsAffected),
+				RefID:      s.ID,
+			})
+		}
+
+		updates["alert_count"] = count
+		updates["first_seen"] = first
+		updates["last_seen"] = last
+		updates["tactics"] = tactics
+		updates["techniques"] = techniques
+		if severity != target.Severity {
+			updates["severity"] = severity
+			database.LogIncidentActivity(tx, model.IncidentActivity{
+				IncidentID: target.ID,
+				ActorID:    actor,
+				Type:       model.ActivitySeverity,
+				From:       target.Severity,
+				To:         severity,
+				Detail:     "raised by merge",
+			})
+		}
+		// 并入了未处置的Alert时重新打开已Resolve的 target
+		if target.Status == "resolved" && moved > 0 {
+			var open int64
+			tx.Model(&model.Alert{}).Where("incident_id = ? AND suppressed = ? AND status IN ?", target.ID, false,
+				[]string{"", model.AlertNew, model.AlertTriaged}).Count(&open)
+			if open > 0 {
+				for k, v := range database.IncidentStatusUpdates(target, "acknowledged", now) {
+					updates[k] = v
+				}
+				logStatusChange(tx, target, actor, "acknowledged", "reopened by merge")
+			}
+		}
+		if err := tx.Model(&model.Incident{}).Where("id = ?", target.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return observable.Rebuild(tx, append([]uint{target.ID}, req.SourceIDs...)...)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "合并事件失败: " + err.Error()})
+		return
+	}
+	if sev := target.Severity; db.First(&target, target.ID).Error == nil && target.Severity != sev {
+		database.RefreshSLA(db, &target)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "事件已合并", "data": target})
+}
+
+// SplitIncident 把选Medium的Alert拆分到一个New Incident，并自动关联 related_to 原 Incident
+// POST /incidents/split {"incident_id":1,"alert_ids":[10,11],"name":"..."}
+func SplitIncident(ctx *gin.Context) {
+	var req struct {
+		IncidentID uint   `json:"incident_id"`
+		AlertIDs   []uint `json:"alert_ids"`
+		Name       string `json:"name"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.IncidentID == 0 || len(req.AlertIDs) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+
+	db := database.GetDB()
+	var origin model.Incident
+	if err := db.First(&origin, req.IncidentID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	if origin.MergedInto != 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": fmt.Sprintf("事件 #%d 已合并到 #%d", origin.ID, origin.MergedInto)})
+		return
+	}
+	ids := uniqueIDs(req.AlertIDs)
+	var selected int64
+	db.Model(&model.Alert{}).Where("id IN ? AND incident_id = ?", ids, origin.ID).Count(&selected)
+	if int(selected) != len(ids) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "所选告警不属于该事件"})
+		return
+	}
+	var total int64
+	db.Model(&model.Alert{}).Where("incident_id = ?", origin.ID).Count(&total)
+	if selected == total {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "不能拆分事件的全部告警"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = origin.Name + " (split)"
+	}
+	actor := currentUserID(ctx)
+	split := model.Incident{
+		RuleID:     origin.RuleID,
+		Name:       name,
+		Severity:   origin.Severity,
+		Status:     "new",
+		Source:     origin.Source,
+		Tactics:    origin.Tactics,
+		Techniques: origin.Techniques,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		database.ApplySLA(tx, &split)
+		if err := tx.Create(&split).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Alert{}).Where("id IN ?", ids).Update("incident_id", split.ID).Error; err != nil {
+			return err
+		}
+		for _, inc := range []*model.Incident{&split, &origin} {
+			if err := recountIncident(tx, inc); err != nil {
+				return err
+			}
+		}
+		if err := observable.Rebuild(tx, split.ID, origin.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(&model.IncidentLink{SourceID: split.ID, TargetID: origin.ID, Type: model.LinkRelatedTo, AuthorID: actor, Comment: "split"}).Error; err != nil {
+			return err
+		}
+		database.LogIncidentActivity(tx, model.IncidentActivity{
+			IncidentID: split.ID,
+			ActorID:    actor,
+			Type:       model.ActivityCreated,
+			To:         split.Status,
+			Detail:     fmt.Sprintf("split from #%d (%d alert(s))", origin.ID, len(ids)),
+			RefID:      origin.ID,
+		})
+		database.LogIncidentActivity(tx, model.IncidentActivity{
+			IncidentID: origin.ID,
+			ActorID:    actor,
+			Type:       model.ActivitySplit,
+			To:         strconv.FormatUint(uint64(split.ID), 10),
+			Detail:     fmt.Sprintf("%d alert(s) split into #%d", len(ids), split.ID),
+			RefID:      split.ID,
+		})
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "拆分事件失败: " + err.Error()})
+		return
+	}
+	// 拆出的Alert研判Status可能已使New Incident进入处置
+	scheduler.RollupIncident(split.ID, actor)
+	scheduler.RollupIncident(origin.ID, actor)
+	db.First(&split, split.ID)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "事件已拆分", "data": split})
+}
+
+// recountIncident 按当ago Alert 重新计算计数和首末Time
+func recountIncident(tx *gorm.DB, incident *model.Incident) error {
+	var row struct {
+		Count int
+		First string
+		Last  string
+	}
+	tx.Model(&model.Alert{}).Select("COUNT(*) AS count, MIN(created_at) AS first, MAX(created_at) AS last").
+		Where("incident_id = ? AND suppressed = ?", incident.ID, false).Scan(&row)
+	updates := map[string]interface{}{"alert_count": row.Count}
+	if t, ok := parseDBTime(row.First); ok {
+		updates["first_seen"] = t
+	}
+	if t, ok := parseDBTime(row.Last); ok {
+		updates["last_seen"] = t
+	}
+	return tx.Model(incident).Updates(updates).Error
+}
+
+// parseDBTime sqlite 聚合函数Return的Time为文本
+func parseDBTime(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// CreateIncidentLink 建立 Incident 之间的关联
+// POST /incidents/links {"source_id":1,"target_id":2,"type":"duplicate_of","comment":""}
+func CreateIncidentLink(ctx *gin.Context) {
+	var link model.IncidentLink
+	if err := ctx.ShouldBindJSON(&link); err != nil || link.SourceID == 0 || link.TargetID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	if !linkTypes[link.Type] {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "type 只能为 duplicate_of / related_to / caused_by"})
+		return
+	}
+	if link.SourceID == link.TargetID {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "不能关联事件自身"})
+		return
+	}
+	db := database.GetDB()
+	var incidents []model.Incident
+	db.Select("id", "name").Where("id IN ?", []uint{link.SourceID, link.TargetID}).Find(&incidents)
+	if len(incidents) != 2 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	var exists int64
+	db.Model(&model.IncidentLink{}).Where("source_id = ? AND target_id = ? AND type = ?", link.SourceID, link.TargetID, link.Type).Count(&exists)
+	if exists > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "关联已存在"})
+		return
+	}
+
+	link.ID = 0
+	link.AuthorID = currentUserID(ctx)
+	if err := db.Create(&link).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建关联失败"})
+		return
+	}
+	logLinkActivity(db, link, model.ActivityLinked)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "关联已创建", "data": link})
+}
+
+// DeleteIncidentLink 删除关联
+func DeleteIncidentLink(ctx *gin.Context) {
+	db := database.GetDB()
+	var link model.IncidentLink
+	if err := db.First(&link, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "关联不存在"})
+		return
+	}
+	if err := db.Delete(&link).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除关联失败"})
+		return
+	}
+	link.AuthorID = currentUserID(ctx)
+	logLinkActivity(db, link, model.ActivityUnlinked)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "关联已删除"})
+}
+
+// logLinkActivity 关联两端都记入Timeline
+func logLinkActivity(db *gorm.DB, link model.IncidentLink, activity string) {
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: link.SourceID,
+		ActorID:    link.AuthorID,
+		Type:       activity,
+		To:         link.Type,
+		Detail:     fmt.Sprintf("%s #%d", link.Type, link.TargetID),
+		RefID:      link.TargetID,
+	})
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: link.TargetID,
+		ActorID:    link.AuthorID,
+		Type:       activity,
+		To:         link.Type,
+		Detail:     fmt.Sprintf("#%d %s this incident", link.SourceID, link.Type),
+		RefID:      link.SourceID,
+	})
+}
+
+// unionCSV 合并逗号分隔的 ATT&CK 编号，保持原有顺序去重
+func unionCSV(a, b string) string {
+	seen := make(map[string]bool)
+	var items []string
+	for _, item := range strings.Split(a+","+b, ",") {
+		if item = strings.TrimSpace(item); item != "" && !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
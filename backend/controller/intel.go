@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/intel"
+	"github.com/laenix/vsentry/model"
+)
+
+// ListIndicators Get IOC List
+// GET /intel/indicators?type=ip&source=abuse.ch&feed_id=1&q=evil&expired=true&page=1&size=50
+func ListIndicators(ctx *gin.Context) {
+	db := database.GetDB().Model(&model.Indicator{})
+	if t := ctx.Query("type"); t != "" {
+		db = db.Where("type = ?", t)
+	}
+	if source := ctx.Query("source"); source != "" {
+		db = db.Where("source = ?", source)
+	}
+	if feedID := ctx.Query("feed_id"); feedID != "" {
+		db = db.Where("feed_id = ?", feedID)
+	}
+	if q := ctx.Query("q"); q != "" {
+		db = db.Where("value LIKE ? OR description LIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+	if ctx.Query("expired") != "true" {
+		db = db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 500 {
+		size = 50
+	}
+
+	var total int64
+	db.Count(&total)
+	var indicators []model.Indicator
+	db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&indicators)
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"indicators": indicators, "total": total}})
+}
+
+// AddIndicator 手动Add IOC，Type为空时自动识别
+func AddIndicator(ctx *gin.Context) {
+	var req model.Indicator
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	typ, value, err := intel.Normalize(req.Type, req.Value)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	req.Type, req.Value = typ, value
+	if req.Source == "" {
+		req.Source = "manual"
+	}
+
+	if _, err := intel.Import([]model.Indicator{req}, intel.ImportOptions{}); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "IOC 添加成功"})
+}
+
+// DeleteIndicator Delete IOC
+func DeleteIndicator(ctx *gin.Context) {
+	if err := database.GetDB().Delete(&model.Indicator{}, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	intel.Reload()
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "IOC 删除成功"})
+}
+
+// ImportIndicators 一次性导入情报：multipart Upload file，或 JSON 指定 location (本地Path/URL) / content
+func ImportIndicators(ctx *gin.Context) {
+	var req struct {
+		Format      string `json:"format" form:"format"`
+		Location    string `json:"location" form:"location"`
+		Content     string `json:"content" form:"content"`
+		DefaultType string `json:"default_type" form:"default_type"`
+		Source      string `json:"source" form:"source"`
+		Confidence  int    `json:"confidence" form:"confidence"`
+		Severity    string `json:"severity" form:"severity"`
+		TTLDays     int    `json:"ttl_days" form:"ttl_days"`
+	}
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+
+	var data []byte
+	if file, err := ctx.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		defer f.Close()
+		data, _ = io.ReadAll(f)
+		if req.Source == "" {
+			req.Source = file.Filename
+		}
+	} else if req.Location != "" {
+		if data, err = intel.Fetch(req.Location); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+			return
+		}
+		if req.Source == "" {
+			req.Source = req.Location
+		}
+	} else {
+		data = []byte(req.Content)
+	}
+	if len(data) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "没有可导入的数据"})
+		return
+	}
+
+	indicators, err := intel.Parse(req.Format, data, req.DefaultType)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	count, err := intel.Import(indicators, intel.ImportOptions{
+		Source:     req.Source,
+		Confidence: req.Confidence,
+		Severity:   req.Severity,
+		TTLDays:    req.TTLDays,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "导入完成", "data": gin.H{"imported": count}})
+}
+
+// LookupIndicator 查询某个Value是否命Medium当ago索引（含 CIDR / 域名后缀）
+func LookupIndicator(ctx *gin.Context) {
+	value := ctx.Query("value")
+	idx := intel.Current()
+	if value == "" || idx == nil {
+		ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": nil})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": idx.MatchValue(value)})
+}
+
+// GetIntelStats 按Type统计 IOC 数量
+func GetIntelStats(ctx *gin.Context) {
+	var byType []struct {
+		Type  string `json:"type"`
+		Count int64  `json:"count"`
+	}
+	database.GetDB().Model(&model.Indicator{}).Select("type, COUNT(*) AS count").
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).Group("type").Scan(&byType)
+
+	loaded := 0
+	if idx := intel.Current(); idx != nil {
+		loaded = idx.Size()
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"by_type": byType, "loaded": loaded}})
+}
+
+// ListIndicatorFeeds Get情报源List
+func ListIndicatorFeeds(ctx *gin.Context) {
+	var feeds []model.IndicatorFeed
+	database.GetDB().Order("id asc").Find(&feeds)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": feeds})
+}
+
+// AddIndicatorFeed Add情报源
+func AddIndicatorFeed(ctx *gin.Context) {
+	var feed model.IndicatorFeed
+	if err := ctx.ShouldBindJSON(&feed); err != nil || feed.Name == "" || feed.Location == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "名称和地址不能为空"})
+		return
+	}
+	if !intel.ValidFormat(feed.Format) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "unsupported feed format: " + feed.Format})
+		return
+	}
+	if err := intel.CheckLocation(feed.Location); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	feed.ID = 0
+	if err := database.GetDB().Create(&feed).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "情报源添加成功", "data": feed})
+}
+
+// UpdateIndicatorFeed Update情报源
+func UpdateIndicatorFeed(ctx *gin.Context) {
+	var req model.IndicatorFeed
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	db := database.GetDB()
+	var feed model.IndicatorFeed
+	if err := db.First(&feed, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "情报源不存在"})
+		return
+	}
+	if err := intel.CheckLocation(req.Location); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := db.Model(&feed).Select("Name", "Location", "Format", "DefaultType", "Source", "Confidence", "TTLDays", "RefreshMinutes", "Enabled").Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "情报源更新成功"})
+}
+
+// DeleteIndicatorFeed Delete情报源及其导入的 IOC
+func DeleteIndicatorFeed(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "Invalid feed ID"})
+		return
+	}
+	db := database.GetDB()
+	db.Delete(&model.IndicatorFeed{}, id)
+	db.Where("feed_id = ?", id).Delete(&model.Indicator{})
+	intel.Reload()
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "情报源删除成功"})
+}
+
+// SyncIndicatorFeed 立即同步情报源
+func SyncIndicatorFeed(ctx *gin.Context) {
+	var feed model.IndicatorFeed
+	if err := database.GetDB().First(&feed, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "情报源不存在"})
+		return
+	}
+	count, err := intel.SyncFeed(&feed)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "同步完成", "data": gin.H{"imported": count}})
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/laenix/vsentry/database"
 	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
 	"github.com/spf13/viper"
 )
 
@@ -57,9 +58,11 @@ func ExecuteInvestigation(ctx *gin.Context) {
 	vars["end_time"] = oneYearLater
 
 	// 加载 Incident 及其关联的 Alerts
+	incidentFound := false
 	if req.IncidentID > 0 {
 		var incident model.Incident
 		if err := db.Preload("Alerts").First(&incident, req.IncidentID).Error; err == nil {
+			incidentFound = true
 			vars["incident_id"] = fmt.Sprintf("%d", incident.ID)
 			vars["incident_name"] = incident.Name
 
@@ -71,7 +74,7 @@ func ExecuteInvestigation(ctx *gin.Context) {
 				if firstAlert.Content != "" {
 					var alertContent map[string]interface{}
 					if err := json.Unmarshal([]byte(firstAlert.Content), &alertContent); err == nil {
-						flattenMap(alertContent, "", vars)
+						scheduler.FlattenEvent(alertContent, "", vars)
 
 						if val, ok := vars["observer.hostname"]; ok {
 							vars["hostname"] = val
@@ -185,6 +188,18 @@ func ExecuteInvestigation(ctx *gin.Context) {
 		fmt.Printf("[Investigation] Scanner error: %v\n", err)
 	}
 
+	// 针对 Incident 的调查记入Timeline，供处置报告引用
+	if incidentFound {
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: req.IncidentID,
+			ActorID:    currentUserID(ctx),
+			Type:       model.ActivityQuery,
+			To:         ruleName,
+			Detail:     finalLogSQL,
+			RefID:      rule.ID,
+		})
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": map[string]interface{}{
@@ -196,21 +211,3 @@ func ExecuteInvestigation(ctx *gin.Context) {
 		},
 	})
 }
-
-func flattenMap(data map[string]interface{}, prefix string, result map[string]string) {
-	for k, v := range data {
-		key := k
-		if prefix != "" {
-			key = prefix + "." + k
-		}
-
-		switch child := v.(type) {
-		case map[string]interface{}:
-			flattenMap(child, key, result)
-		case string:
-			result[key] = child
-		case float64, int, bool:
-			result[key] = fmt.Sprintf("%v", child)
-		}
-	}
-}
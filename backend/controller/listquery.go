@@ -0,0 +1,453 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/observable"
+	"github.com/laenix/vsentry/pkg/filter"
+	"gorm.io/gorm"
+)
+
+// List接口字段Type
+const (
+	fieldString   = iota
+	fieldText     // LIKE 包含匹配
+	fieldNumber   // 整数
+	fieldTime     // 只支持比较和区间
+	fieldBool     // true / false
+	fieldSeverity // 支持 >=high 这类按级别比较
+	fieldUser     // 用户 ID、用户名、me 或 none
+)
+
+// listField 过滤语言Medium的一个字段；custom 不为空时自行生成条件
+type listField struct {
+	column string
+	kind   int
+	custom func(ctx *gin.Context, t filter.Term) (string, []interface{}, error)
+}
+
+// listSpec 一个List接口可过滤、排序的字段
+type listSpec struct {
+	table       string
+	fields      map[string]listField
+	sorts       map[string]listField // 排序字段需非空，翻页时与 id 组成游标
+	defaultSort string               // 如 -last_seen
+	text        []string             // 全文检索的列
+}
+
+// listPage 分页结果
+type listPage struct {
+	NextCursor string
+	Total      *int64
+}
+
+// listCursor 上一页最后一行的排序值和 ID
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// queryList 按通用参数查询List：
+// q=过滤Expression (见 pkg/filter)、sort=字段 或 -字段 (降序)、limit=每页条数、cursor=上一页Return的 next_cursor、count=true 时Return总数
+func queryList(ctx *gin.Context, db *gorm.DB, spec listSpec, dest interface{}) (listPage, error) {
+	var page listPage
+	db, err := applyFilter(ctx, db, spec, ctx.Query("q"))
+	if err != nil {
+		return page, err
+	}
+
+	sortName := ctx.DefaultQuery("sort", spec.defaultSort)
+	desc := strings.HasPrefix(sortName, "-")
+	sortField, ok := spec.sorts[strings.TrimPrefix(sortName, "-")]
+	if !ok {
+		names := make([]string, 0, len(spec.sorts))
+		for name := range spec.sorts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return page, fmt.Errorf("sort 只能为 %s (降序加 - 前缀)", strings.Join(names, " / "))
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+
+	if ctx.Query("count") == "true" {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return page, err
+		}
+		page.Total = &total
+	}
+
+	idColumn := spec.table + ".id"
+	// Time字段与过滤条件一样换算为 UTC 后排序和比较，否则时区偏移不同的行会在翻页时跳过或重复
+	sortColumn := sortField.column
+	if sortField.kind == fieldTime {
+		sortColumn = utcColumn(sortColumn)
+	}
+	if c := ctx.Query("cursor"); c != "" {
+		cur, err := decodeCursor(c)
+		if err != nil || cur.Sort != sortName {
+			return page, fmt.Errorf("cursor 无效或与 sort 不匹配")
+		}
+		value, err := cursorValue(sortField, cur.Value)
+		if err != nil {
+			return page, fmt.Errorf("cursor 无效")
+		}
+		cmp := ">"
+		if desc {
+			cmp = "<"
+		}
+		db = db.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", sortColumn, cmp, sortColumn, idColumn, cmp),
+			value, value, cur.ID)
+	}
+
+	dir := "asc"
+	if desc {
+		dir = "desc"
+	}
+	result := db.Order(fmt.Sprintf("%s %s, %s %s", sortColumn, dir, idColumn, dir)).Limit(limit + 1).Find(dest)
+	if result.Error != nil {
+		return page, result.Error
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > limit {
+		rows.Set(rows.Slice(0, limit))
+		last := rows.Index(limit - 1)
+		idField := result.Statement.Schema.LookUpField("id")
+		valueField := result.Statement.Schema.LookUpField(strings.TrimPrefix(sortField.column, spec.table+"."))
+		if idField != nil && valueField != nil {
+			id, _ := idField.ValueOf(ctx.Request.Context(), last)
+			value, _ := valueField.ValueOf(ctx.Request.Context(), last)
+			page.NextCursor = encodeCursor(listCursor{Sort: sortName, Value: formatCursorValue(value), ID: id.(uint)})
+		}
+	}
+	return page, nil
+}
+
+// pageResponse 在 data 之外附加 next_cursor 和 (可选) total
+func pageResponse(data interface{}, page listPage) gin.H {
+	resp := gin.H{"code": 200, "data": data, "msg": "success", "next_cursor": page.NextCursor}
+	if page.Total != nil {
+		resp["total"] = *page.Total
+	}
+	return resp
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func formatCursorValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func cursorValue(f listField, v string) (interface{}, error) {
+	switch f.kind {
+	case fieldTime:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t.UTC().Format(utcTimeLayout), err
+	case fieldNumber:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return v, nil
+}
+
+// applyFilter 把过滤Expression转换为 WHERE 条件，多个条件为 AND
+func applyFilter(ctx *gin.Context, db *gorm.DB, spec listSpec, q string) (*gorm.DB, error) {
+	terms, err := filter.Parse(q)
+	if err != nil {
+		return nil, fmt.Errorf("过滤Expression错误: %v", err)
+	}
+	for _, t := range terms {
+		var sql string
+		var args []interface{}
+		if t.Field == "" {
+			if len(spec.text) == 0 {
+				return nil, fmt.Errorf("该List不支持全文检索")
+			}
+			parts := make([]string, 0, len(spec.text))
+			for _, column := range spec.text {
+				parts = append(parts, column+` LIKE ? ESCAPE '\'`)
+				args = append(args, likePattern(t.Values[0]))
+			}
+			sql = "(" + strings.Join(parts, " OR ") + ")"
+		} else {
+			f, ok := spec.fields[t.Field]
+			if !ok {
+				return nil, fmt.Errorf("未知字段 %s", t.Field)
+			}
+			if f.custom != nil {
+				sql, args, err = f.custom(ctx, t)
+			} else {
+				sql, args, err = fieldCondition(ctx, f, t)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", t.Field, err)
+			}
+		}
+		if t.Negate {
+			sql = "NOT " + sql
+		}
+		db = db.Where(sql, args...)
+	}
+	return db, nil
+}
+
+func fieldCondition(ctx *gin.Context, f listField, t filter.Term) (string, []interface{}, error) {
+	switch f.kind {
+	case fieldString:
+		if t.Op != filter.OpEq {
+			return "", nil, fmt.Errorf("只支持等于")
+		}
+		return "(" + f.column + " IN ?)", []interface{}{t.Values}, nil
+	case fieldText:
+		if t.Op != filter.OpEq {
+			return "", nil, fmt.Errorf("只支持包含匹配")
+		}
+		parts := make([]string, 0, len(t.Values))
+		args := make([]interface{}, 0, len(t.Values))
+		for _, v := range t.Values {
+			parts = append(parts, f.column+` LIKE ? ESCAPE '\'`)
+			args = append(args, likePattern(v))
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", args, nil
+	case fieldBool:
+		if t.Op != filter.OpEq || len(t.Values) != 1 {
+			return "", nil, fmt.Errorf("只能为 true / false")
+		}
+		v, err := strconv.ParseBool(t.Values[0])
+		if err != nil {
+			return "", nil, fmt.Errorf("只能为 true / false")
+		}
+		return "(" + f.column + " = ?)", []interface{}{v}, nil
+	case fieldSeverity:
+		levels, err := severityMatch(t)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + f.column + " IN ?)", []interface{}{levels}, nil
+	case fieldUser:
+		if t.Op != filter.OpEq {
+			return "", nil, fmt.Errorf("只支持等于")
+		}
+		ids, err := userIDs(ctx, t.Values)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + f.column + " IN ?)", []interface{}{ids}, nil
+	case fieldNumber:
+		return compare(f.column, t, func(v string) (interface{}, error) { return strconv.ParseInt(v, 10, 64) })
+	case fieldTime:
+		if t.Op == filter.OpEq {
+			return "", nil, fmt.Errorf("Time字段请使用 > >= < <= 或 from..to")
+		}
+		// 库Medium的Time是写入时所在时区的文本 (gorm 自动填充的 created_at 为本地时区)，直接比较字符串会差一个时区偏移，
+		// 两侧都换算为 UTC 后再比较
+		now := time.Now().UTC()
+		return compare(utcColumn(f.column), t, func(v string) (interface{}, error) {
+			ts, err := filter.ParseTime(v, now)
+			return ts.UTC().Format(utcTimeLayout), err
+		})
+	}
+	return "", nil, fmt.Errorf("不支持的字段")
+}
+
+// utcTimeLayout 与 utcColumn 输出一致的 UTC Time格式
+const utcTimeLayout = "2006-01-02 15:04:05.000"
+
+// utcColumn 把 SQLite 中带时区偏移的Time文本换算为 UTC (精确到毫秒)
+func utcColumn(column string) string {
+	return "strftime('%Y-%m-%d %H:%M:%f', " + column + ")"
+}
+
+// compare 数值/Time字段的等于、比较和区间条件
+func compare(column string, t filter.Term, parse func(string) (interface{}, error)) (string, []interface{}, error) {
+	var args []interface{}
+	for _, v := range t.Values {
+		if v == "" {
+			args = append(args, nil)
+			continue
+		}
+		p, err := parse(v)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, p)
+	}
+	switch t.Op {
+	case filter.OpEq:
+		return "(" + column + " IN ?)", []interface{}{args}, nil
+	case filter.OpRange:
+		switch {
+		case args[0] == nil:
+			return "(" + column + " <= ?)", args[1:], nil
+		case args[1] == nil:
+			return "(" + column + " >= ?)", args[:1], nil
+		}
+		return "(" + column + " BETWEEN ? AND ?)", args, nil
+	}
+	return fmt.Sprintf("(%s %s ?)", column, t.Op), args, nil
+}
+
+var severityOrder = []string{"info", "low", "medium", "high", "critical"}
+
+// severityMatch 把级别条件展开为满足条件的级别List
+func severityMatch(t filter.Term) ([]string, error) {
+	rank := func(v string) (int, error) {
+		for i, s := range severityOrder {
+			if strings.EqualFold(s, v) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("只能为 info / low / medium / high / critical")
+	}
+	if t.Op == filter.OpEq {
+		for _, v := range t.Values {
+			if _, err := rank(v); err != nil {
+				return nil, err
+			}
+		}
+		return t.Values, nil
+	}
+	lo, hi := 0, len(severityOrder)-1
+	var err error
+	switch t.Op {
+	case filter.OpGte:
+		lo, err = rank(t.Values[0])
+	case filter.OpGt:
+		lo, err = rank(t.Values[0])
+		lo++
+	case filter.OpLte:
+		hi, err = rank(t.Values[0])
+	case filter.OpLt:
+		hi, err = rank(t.Values[0])
+		hi--
+	case filter.OpRange:
+		if t.Values[0] != "" {
+			if lo, err = rank(t.Values[0]); err != nil {
+				return nil, err
+			}
+		}
+		if t.Values[1] != "" {
+			hi, err = rank(t.Values[1])
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	levels := []string{}
+	for i := lo; i <= hi && i < len(severityOrder); i++ {
+		levels = append(levels, severityOrder[i])
+	}
+	return levels, nil
+}
+
+// userIDs 解析用户：ID、用户名、me (当ago用户) 或 none (未指派)
+func userIDs(ctx *gin.Context, values []string) ([]uint, error) {
+	var ids []uint
+	var names []string
+	for _, v := range values {
+		switch strings.ToLower(v) {
+		case "me":
+			ids = append(ids, currentUserID(ctx))
+		case "none":
+			ids = append(ids, 0)
+		default:
+			if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+				ids = append(ids, uint(id))
+			} else {
+				names = append(names, v)
+			}
+		}
+	}
+	if len(names) > 0 {
+		var users []model.User
+		database.GetDB().Select("id").Where("user_name IN ?", names).Find(&users)
+		if len(users) != len(names) {
+			return nil, fmt.Errorf("用户不存在")
+		}
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+	}
+	return ids, nil
+}
+
+// ruleCondition rule:12 或 rule:"Brute force" (名称包含)
+func ruleCondition(column string) func(*gin.Context, filter.Term) (string, []interface{}, error) {
+	return func(ctx *gin.Context, t filter.Term) (string, []interface{}, error) {
+		if t.Op != filter.OpEq {
+			return "", nil, fmt.Errorf("只支持等于")
+		}
+		var ids []uint64
+		var parts []string
+		var args []interface{}
+		for _, v := range t.Values {
+			if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+				ids = append(ids, id)
+				continue
+			}
+			parts = append(parts, column+` IN (SELECT id FROM rules WHERE name LIKE ? ESCAPE '\')`)
+			args = append(args, likePattern(v))
+		}
+		if len(ids) > 0 {
+			parts = append(parts, column+" IN ?")
+			args = append(args, ids)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", args, nil
+	}
+}
+
+// observableCondition 涉及某个 Observable 值的 Incident (incidentColumn 为 Incident ID 所在列)
+func observableCondition(incidentColumn string) func(*gin.Context, filter.Term) (string, []interface{}, error) {
+	return func(ctx *gin.Context, t filter.Term) (string, []interface{}, error) {
+		if t.Op != filter.OpEq {
+			return "", nil, fmt.Errorf("只支持等于")
+		}
+		values := make([]string, 0, len(t.Values))
+		for _, v := range t.Values {
+			_, value := observable.Normalize("", v)
+			values = append(values, value)
+		}
+		return "(" + incidentColumn + " IN (SELECT incident_id FROM observables WHERE value IN ?))", []interface{}{values}, nil
+	}
+}
+
+// likePattern 包含匹配，转义 LIKE 通配符
+func likePattern(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+	return "%" + v + "%"
+}
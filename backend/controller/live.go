@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/live"
+	"github.com/spf13/viper"
+	"golang.org/x/net/websocket"
+)
+
+func liveHeartbeat() time.Duration {
+	if d := viper.GetDuration("live.heartbeat"); d > 0 {
+		return d
+	}
+	return 15 * time.Second
+}
+
+// liveSubscribe 按查询参数订阅：types / severity / rule_id / incident_id 为逗号分隔List，
+// 续传位置取 Last-Event-ID 请求头或 last_event_id 参数
+func liveSubscribe(ctx *gin.Context) *live.Subscription {
+	filter := live.ParseFilter(ctx.Query("types"), ctx.Query("severity"), ctx.Query("rule_id"), ctx.Query("incident_id"))
+	lastID := ctx.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = ctx.Query("last_event_id")
+	}
+	id, _ := strconv.ParseUint(lastID, 10, 64)
+	return live.Subscribe(filter, id)
+}
+
+// StreamEvents 以 Server-Sent Events 推送实时Event
+// GET /stream/events?types=incident,alert.created&severity=high,critical&token=<jwt>
+func StreamEvents(ctx *gin.Context) {
+	sub := liveSubscribe(ctx)
+	defer sub.Close()
+
+	w := ctx.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	w.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			w.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// 客户端跟不上被断开，EventSource 会带 Last-Event-ID 自动重连
+				return
+			}
+			data, _ := json.Marshal(e)
+			if e.ID != 0 {
+				fmt.Fprintf(w, "id: %d\n", e.ID)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			w.Flush()
+		}
+	}
+}
+
+// StreamWebSocket 以 WebSocket 推送实时Event，every条消息为一个 JSON Event，参数同 StreamEvents
+// GET /stream/ws?types=playbook&incident_id=12&last_event_id=<id>&token=<jwt>
+func StreamWebSocket(ctx *gin.Context) {
+	sub := liveSubscribe(ctx)
+	defer sub.Close()
+
+	server := websocket.Server{
+		// 连接已由 JWT 认证，不再校验 Origin
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			closed := make(chan struct{})
+			go func() {
+				// 客户端不发送消息，读Failed表示连接已关闭
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+				close(closed)
+			}()
+
+			heartbeat := time.NewTicker(liveHeartbeat())
+			defer heartbeat.Stop()
+			for {
+				select {
+				case <-closed:
+					return
+				case <-heartbeat.C:
+					if websocket.JSON.Send(ws, gin.H{"type": "ping", "time": time.Now().UTC()}) != nil {
+						return
+					}
+				case e, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					if websocket.JSON.Send(ws, e) != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// ListMaintenanceWindows Get维护窗口List
+func ListMaintenanceWindows(ctx *gin.Context) {
+	var windows []model.MaintenanceWindow
+	database.GetDB().Order("id desc").Find(&windows)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": windows})
+}
+
+// AddMaintenanceWindow Add维护窗口
+func AddMaintenanceWindow(ctx *gin.Context) {
+	var w model.MaintenanceWindow
+	if err := ctx.ShouldBindJSON(&w); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if err := scheduler.ValidateMaintenanceWindow(&w); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	w.ID = 0
+	if userID, ok := ctx.Get("userid"); ok {
+		w.AuthorID = userID.(uint)
+	}
+	if err := database.GetDB().Create(&w).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "维护窗口添加成功", "data": w})
+}
+
+// UpdateMaintenanceWindow Update维护窗口
+func UpdateMaintenanceWindow(ctx *gin.Context) {
+	var req model.MaintenanceWindow
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if err := scheduler.ValidateMaintenanceWindow(&req); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	db := database.GetDB()
+	var w model.MaintenanceWindow
+	if err := db.First(&w, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "维护窗口不存在"})
+		return
+	}
+	err := db.Model(&w).Select("Name", "Description", "Enabled", "Action", "TimeZone", "Schedule", "DurationMinutes",
+		"StartsAt", "EndsAt", "RuleIDs", "Tags", "Condition").Updates(req).Error
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "维护窗口更新成功"})
+}
+
+// DeleteMaintenanceWindow Delete维护窗口，已抑制的Alert保留
+func DeleteMaintenanceWindow(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.MaintenanceWindow{}, ctx.Param("id"))
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "维护窗口不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "维护窗口删除成功"})
+}
+
+// ListMutedRules 当ago生效的维护窗口及被静默的Rule
+func ListMutedRules(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": scheduler.ActiveMaintenance(time.Now())})
+}
@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+)
+
+// ListNotifyChannels Get通知渠道List，不Return密码
+func ListNotifyChannels(ctx *gin.Context) {
+	var channels []model.NotifyChannel
+	database.GetDB().Order("id asc").Find(&channels)
+	for i := range channels {
+		maskChannel(&channels[i])
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": channels})
+}
+
+// AddNotifyChannel Add通知渠道
+func AddNotifyChannel(ctx *gin.Context) {
+	var c model.NotifyChannel
+	if err := ctx.ShouldBindJSON(&c); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateNotifyChannel(&c); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var count int64
+	db.Model(&model.NotifyChannel{}).Where("name = ?", c.Name).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "渠道名称已存在"})
+		return
+	}
+	c.ID = 0
+	if err := db.Create(&c).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	maskChannel(&c)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知渠道添加成功", "data": c})
+}
+
+// UpdateNotifyChannel Update通知渠道，password 为空时保留原密码
+func UpdateNotifyChannel(ctx *gin.Context) {
+	var req model.NotifyChannel
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateNotifyChannel(&req); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var c model.NotifyChannel
+	if err := db.First(&c, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "通知渠道不存在"})
+		return
+	}
+	var count int64
+	db.Model(&model.NotifyChannel{}).Where("name = ? AND id != ?", req.Name, c.ID).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "渠道名称已存在"})
+		return
+	}
+	fields := []string{"Name", "Type", "Enabled", "URL", "Method", "Headers", "Template",
+		"Host", "Port", "Username", "From", "To", "Network"}
+	if req.Password != "" {
+		fields = append(fields, "Password")
+	}
+	if err := db.Model(&c).Select(fields).Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知渠道更新成功"})
+}
+
+// DeleteNotifyChannel Delete通知渠道，引用它的策略不再向其投递
+func DeleteNotifyChannel(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.NotifyChannel{}, ctx.Param("id"))
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "通知渠道不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知渠道已删除"})
+}
+
+// TestNotifyChannel 向渠道发送一条测试消息
+func TestNotifyChannel(ctx *gin.Context) {
+	var c model.NotifyChannel
+	if err := database.GetDB().First(&c, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "通知渠道不存在"})
+		return
+	}
+	delivery, err := notify.Test(c)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "测试消息发送失败: " + err.Error(), "data": delivery})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "测试消息已发送", "data": delivery})
+}
+
+func maskChannel(c *model.NotifyChannel) {
+	c.HasPassword = c.Password != ""
+	c.Password = ""
+}
+
+func validateNotifyChannel(c *model.NotifyChannel) string {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return "渠道名称不能为空"
+	}
+	switch c.Type {
+	case model.ChannelWebhook, model.ChannelSlack, model.ChannelTeams, model.ChannelMattermost:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "url 必须是有效的 http(s) 地址"
+		}
+		if c.Type == model.ChannelWebhook {
+			if _, err := notify.RenderWebhook(c.Template, notify.Message{}); err != nil {
+				return "模板错误: " + err.Error()
+			}
+		}
+	case model.ChannelEmail:
+		if c.Host == "" || strings.TrimSpace(c.To) == "" {
+			return "email 渠道需要 host 和 to"
+		}
+	case model.ChannelSyslog:
+		if c.Host == "" {
+			return "syslog 渠道需要 host"
+		}
+		if c.Network != "" && c.Network != "udp" && c.Network != "tcp" {
+			return "network 只能为 udp / tcp"
+		}
+	default:
+		return "type 只能为 email / webhook / slack / teams / mattermost / syslog"
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return "port 超出范围"
+	}
+	return ""
+}
+
+// ListNotifyPolicies Get通知策略List
+func ListNotifyPolicies(ctx *gin.Context) {
+	var policies []model.NotifyPolicy
+	database.GetDB().Order("id asc").Find(&policies)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": policies})
+}
+
+// AddNotifyPolicy Add通知策略
+func AddNotifyPolicy(ctx *gin.Context) {
+	var p model.NotifyPolicy
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateNotifyPolicy(&p); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	p.ID = 0
+	if err := database.GetDB().Create(&p).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知策略添加成功", "data": p})
+}
+
+// UpdateNotifyPolicy Update通知策略
+func UpdateNotifyPolicy(ctx *gin.Context) {
+	var req model.NotifyPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateNotifyPolicy(&req); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var p model.NotifyPolicy
+	if err := db.First(&p, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "通知策略不存在"})
+		return
+	}
+	if err := db.Model(&p).Select("Name", "Enabled", "Events", "Severities", "RuleIDs", "Tags",
+		"ChannelIDs", "DedupMinutes", "DigestMinutes").Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知策略更新成功"})
+}
+
+// DeleteNotifyPolicy Delete通知策略
+func DeleteNotifyPolicy(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.NotifyPolicy{}, ctx.Param("id"))
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "通知策略不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "通知策略已删除"})
+}
+
+func validateNotifyPolicy(p *model.NotifyPolicy) string {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return "策略名称不能为空"
+	}
+	p.Events = normalizeCSV(p.Events)
+	if p.Events == "" {
+		p.Events = model.NotifyIncidentCreated
+	}
+	for _, e := range strings.Split(p.Events, ",") {
+		if !notify.Events[e] {
+			return "events 只能为 incident_created / incident_escalated / incident_resolved / sla_breached"
+		}
+	}
+	p.Severities = normalizeCSV(p.Severities)
+	if p.Severities != "" {
+		for _, s := range strings.Split(p.Severities, ",") {
+			if !severityLevels[s] {
+				return "severities 只能为 info / low / medium / high / critical"
+			}
+		}
+	}
+	p.Tags = normalizeCSV(p.Tags)
+	if len(p.ChannelIDs) == 0 {
+		return "至少选择一个通知渠道"
+	}
+	var count int64
+	database.GetDB().Model(&model.NotifyChannel{}).Where("id IN ?", []uint(p.ChannelIDs)).Count(&count)
+	if int(count) != len(uniqueIDs(p.ChannelIDs)) {
+		return "通知渠道不存在"
+	}
+	if p.DedupMinutes < 0 || p.DigestMinutes < 0 {
+		return "dedup_minutes / digest_minutes 不能为负数"
+	}
+	return ""
+}
+
+// normalizeCSV 去掉逗号分隔List中的空白和空项
+func normalizeCSV(s string) string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// ListNotifyDeliveries 通知投递记录
+// GET /notifications/deliveries?status=failed&channel_id=1&incident_id=2&event=sla_breached&page=1&size=50
+func ListNotifyDeliveries(ctx *gin.Context) {
+	db := database.GetDB().Model(&model.NotifyDelivery{})
+	for _, key := range []string{"status", "channel_id", "policy_id", "incident_id", "event"} {
+		if v := ctx.Query(key); v != "" {
+			db = db.Where(key+" = ?", v)
+		}
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 500 {
+		size = 50
+	}
+
+	var total int64
+	db.Count(&total)
+	var deliveries []model.NotifyDelivery
+	db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&deliveries)
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"deliveries": deliveries, "total": total}})
+}
+
+// RetryNotifyDelivery 立即重新发送一条未成功的投递
+func RetryNotifyDelivery(ctx *gin.Context) {
+	var d model.NotifyDelivery
+	if err := database.GetDB().First(&d, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "投递记录不存在"})
+		return
+	}
+	if d.Status != model.DeliveryFailed && d.Status != model.DeliveryPending {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "只能重试 failed / pending 的投递"})
+		return
+	}
+	if !notify.Retry(&d) {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "投递正在发送或状态已变化，请刷新后重试"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已重新发送", "data": d})
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/observable"
+)
+
+// ListObservables 分页查询 Observable
+// GET /observables?incident_id=1&type=ip&value=10.1.2.3&q=10.1&tag=malicious&page=1&size=50
+func ListObservables(ctx *gin.Context) {
+	db := database.GetDB().Model(&model.Observable{})
+	if id := ctx.Query("incident_id"); id != "" {
+		db = db.Where("incident_id = ?", id)
+	}
+	if v := ctx.Query("value"); v != "" {
+		typ, value := observable.Normalize(ctx.Query("type"), v)
+		db = db.Where("value = ?", value)
+		if typ != "" {
+			db = db.Where("type = ?", typ)
+		}
+	} else if t := ctx.Query("type"); t != "" {
+		db = db.Where("type = ?", t)
+	}
+	if q := ctx.Query("q"); q != "" {
+		db = db.Where("value LIKE ?", "%"+q+"%")
+	}
+	if tag, ok := ctx.GetQuery("tag"); ok {
+		db = db.Where("tag = ?", tag)
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 500 {
+		size = 50
+	}
+
+	var total int64
+	db.Count(&total)
+	var observables []model.Observable
+	db.Order("last_seen desc").Offset((page - 1) * size).Limit(size).Find(&observables)
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"observables": observables, "total": total}})
+}
+
+// observableIncident 涉及某个 Observable 的 Incident
+type observableIncident struct {
+	IncidentID uint      `json:"incident_id"`
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Severity   string    `json:"severity"`
+	Type       string    `json:"type"`
+	Value      string    `json:"value"`
+	Fields     string    `json:"fields"`
+	Count      int       `json:"count"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Tag        string    `json:"tag"`
+}
+
+// SearchObservableIncidents 全局查询哪些 Incident 涉及某个Value，合并后的墓碑记录不Return
+// GET /observables/incidents?value=10.1.2.3&type=ip
+func SearchObservableIncidents(ctx *gin.Context) {
+	if ctx.Query("value") == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 value"})
+		return
+	}
+	typ, value := observable.Normalize(ctx.Query("type"), ctx.Query("value"))
+	db := database.GetDB().Table("observables").
+		Select("observables.incident_id, incidents.name, incidents.status, incidents.severity, observables.type, observables.value, "+
+			"observables.fields, observables.count, observables.first_seen, observables.last_seen, observables.tag").
+		Joins("JOIN incidents ON incidents.id = observables.incident_id AND incidents.deleted_at IS NULL").
+		Where("observables.value = ? AND incidents.merged_into = ?", value, 0)
+	if typ != "" {
+		db = db.Where("observables.type = ?", typ)
+	}
+	results := []observableIncident{}
+	db.Order("observables.last_seen desc").Limit(500).Scan(&results)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": results})
+}
+
+// TagObservable 把 Observable 标记为 malicious / benign，tag 为空表示清除；对所有 Incident 生效
+// PUT /observables/tag {"type":"ip","value":"10.1.2.3","tag":"malicious","note":"C2"}
+func TagObservable(ctx *gin.Context) {
+	var req struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+		Tag   string `json:"tag"`
+		Note  string `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Value == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	switch req.Tag {
+	case "", model.ObservableMalicious, model.ObservableBenign:
+	default:
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "tag 只能为 malicious / benign 或空"})
+		return
+	}
+	typ, value := observable.Normalize(req.Type, req.Value)
+	if typ == "" {
+		typ = req.Type
+	}
+	n, err := observable.SetTag(database.GetDB(), typ, value, req.Tag, req.Note)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "标记失败"})
+		return
+	}
+	if n == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到该 Observable"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "标记成功", "data": gin.H{"type": typ, "value": value, "tag": req.Tag, "updated": n}})
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/report"
+	"gorm.io/gorm"
+)
+
+// GetIncidentReport 生成 Incident 处置报告
+// GET /incidents/:id/report?format=md|html|pdf&template=<模板名>&download=true
+func GetIncidentReport(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", model.ReportMarkdown)
+	if _, ok := report.ContentTypes[format]; !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "format 只能为 md / html / pdf"})
+		return
+	}
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+
+	db := database.GetDB()
+	body, msg := reportTemplate(db, report.TemplateFormat(format), ctx.Query("template"))
+	if msg != "" {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": msg})
+		return
+	}
+	actor := currentUserID(ctx)
+	data, err := report.Build(db, uint(id), userNames(db, actor)[actor])
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到相关事件记录"})
+		return
+	}
+	out, err := report.Render(format, body, data)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "报告模板渲染失败: " + err.Error()})
+		return
+	}
+
+	disposition := "inline"
+	if ctx.Query("download") == "true" || format == model.ReportPDF {
+		disposition = "attachment"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`%s; filename="incident-%d.%s"`, disposition, id, format))
+	ctx.Data(http.StatusOK, report.ContentTypes[format], out)
+}
+
+// reportTemplate 按名称选择模板；未指定时使用该格式的Default模板，都没有时使用内置模板
+func reportTemplate(db *gorm.DB, format, name string) (string, string) {
+	var templates []model.ReportTemplate
+	if name != "" {
+		db.Where("name = ? AND format = ?", name, format).Limit(1).Find(&templates)
+		if len(templates) == 0 {
+			return "", fmt.Sprintf("报告模板 %s (%s) 不存在", name, format)
+		}
+		return templates[0].Body, ""
+	}
+	db.Where("format = ? AND is_default = ?", format, true).Limit(1).Find(&templates)
+	if len(templates) > 0 {
+		return templates[0].Body, ""
+	}
+	return report.Builtin(format), ""
+}
+
+// ListReportTemplates Get报告模板List
+func ListReportTemplates(ctx *gin.Context) {
+	var templates []model.ReportTemplate
+	database.GetDB().Order("id asc").Find(&templates)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": templates})
+}
+
+// GetBuiltinReportTemplate Get内置模板，作为自定义模板的起点
+// GET /reports/templates/builtin?format=md
+func GetBuiltinReportTemplate(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", model.ReportMarkdown)
+	if format != model.ReportMarkdown && format != model.ReportHTML {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "format 只能为 md / html"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"format": format, "body": report.Builtin(format)}})
+}
+
+// AddReportTemplate Add报告模板
+func AddReportTemplate(ctx *gin.Context) {
+	var t model.ReportTemplate
+	if err := ctx.ShouldBindJSON(&t); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateReportTemplate(&t); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var count int64
+	db.Model(&model.ReportTemplate{}).Where("name = ?", t.Name).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "模板名称已存在"})
+		return
+	}
+	t.ID = 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultTemplate(tx, t); err != nil {
+			return err
+		}
+		return tx.Create(&t).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "报告模板添加成功", "data": t})
+}
+
+// UpdateReportTemplate Update报告模板
+func UpdateReportTemplate(ctx *gin.Context) {
+	var req model.ReportTemplate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateReportTemplate(&req); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var t model.ReportTemplate
+	if err := db.First(&t, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "报告模板不存在"})
+		return
+	}
+	var count int64
+	db.Model(&model.ReportTemplate{}).Where("name = ? AND id != ?", req.Name, t.ID).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "模板名称已存在"})
+		return
+	}
+	req.ID = t.ID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultTemplate(tx, req); err != nil {
+			return err
+		}
+		return tx.Model(&t).Select("Name", "Description", "Format", "Body", "IsDefault").Updates(req).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "报告模板更新成功"})
+}
+
+// DeleteReportTemplate Delete报告模板
+func DeleteReportTemplate(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.ReportTemplate{}, ctx.Param("id"))
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "报告模板不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "报告模板已删除"})
+}
+
+// clearDefaultTemplate 新的Default模板取代同格式的旧Default模板
+func clearDefaultTemplate(tx *gorm.DB, t model.ReportTemplate) error {
+	if !t.IsDefault {
+		return nil
+	}
+	return tx.Model(&model.ReportTemplate{}).Where("format = ? AND id != ?", t.Format, t.ID).Update("is_default", false).Error
+}
+
+func validateReportTemplate(t *model.ReportTemplate) string {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return "模板名称不能为空"
+	}
+	if t.Format != model.ReportMarkdown && t.Format != model.ReportHTML {
+		return "format 只能为 md / html (pdf 使用 md 模板)"
+	}
+	if strings.TrimSpace(t.Body) == "" {
+		return "模板内容不能为空"
+	}
+	if err := report.Validate(t.Format, t.Body); err != nil {
+		return "模板错误: " + err.Error()
+	}
+	return ""
+}
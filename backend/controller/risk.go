@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// ListRiskyEntities 按当ago风险分排序的实体
+// GET /risk/entities?type=user&limit=50
+func ListRiskyEntities(ctx *gin.Context) {
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": scheduler.RiskyEntities(ctx.Query("type"), limit)})
+}
+
+// GetRiskEntity 实体风险Detail及贡献风险的Alert
+// GET /risk/entities/:id?hours=24
+func GetRiskEntity(ctx *gin.Context) {
+	db := database.GetDB()
+	var entity model.RiskEntity
+	if err := db.First(&entity, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "实体不存在"})
+		return
+	}
+	hours, err := strconv.Atoi(ctx.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	now := time.Now().UTC()
+	entity.Score = scheduler.DecayedScore(entity, now)
+	entity.ScoredAt = now
+
+	var events []model.RiskEvent
+	db.Where("entity_type = ? AND entity = ? AND created_at >= ?", entity.Type, entity.Value, now.Add(-time.Duration(hours)*time.Hour)).
+		Order("id desc").Limit(500).Find(&events)
+
+	alertIDs := make([]uint, 0, len(events))
+	var total float64
+	for _, e := range events {
+		alertIDs = append(alertIDs, e.AlertID)
+		total += e.Score
+	}
+	alerts := []model.Alert{}
+	if len(alertIDs) > 0 {
+		db.Where("id IN ?", alertIDs).Order("id desc").Find(&alerts)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"entity":        entity,
+		"window_hours":  hours,
+		"window_total":  total,
+		"contributions": events,
+		"alerts":        alerts,
+	}})
+}
+
+// ListRiskPolicies Get风险阈值策略List
+func ListRiskPolicies(ctx *gin.Context) {
+	var policies []model.RiskPolicy
+	database.GetDB().Order("id asc").Find(&policies)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": policies})
+}
+
+// AddRiskPolicy Add风险阈值策略
+func AddRiskPolicy(ctx *gin.Context) {
+	var p model.RiskPolicy
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateRiskPolicy(&p); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	p.ID = 0
+	if err := database.GetDB().Create(&p).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "风险策略添加成功", "data": p})
+}
+
+// UpdateRiskPolicy Update风险阈值策略
+func UpdateRiskPolicy(ctx *gin.Context) {
+	var req model.RiskPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateRiskPolicy(&req); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var p model.RiskPolicy
+	if err := db.First(&p, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "风险策略不存在"})
+		return
+	}
+	if err := db.Model(&p).Select("Name", "EntityType", "Threshold", "WindowHours", "Severity", "Enabled").Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "风险策略更新成功"})
+}
+
+// DeleteRiskPolicy Delete风险阈值策略
+func DeleteRiskPolicy(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.RiskPolicy{}, ctx.Param("id"))
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "风险策略不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "风险策略删除成功"})
+}
+
+func validateRiskPolicy(p *model.RiskPolicy) string {
+	if p.Name == "" || p.Threshold <= 0 || p.WindowHours <= 0 {
+		return "name, threshold and window_hours are required"
+	}
+	switch p.EntityType {
+	case "", model.RiskEntityUser, model.RiskEntityHost, model.RiskEntityIP:
+	default:
+		return "entity_type must be user, host or ip"
+	}
+	if p.Severity == "" {
+		p.Severity = "high"
+	}
+	return ""
+}
@@ -2,11 +2,14 @@ package controller
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/laenix/vsentry/database"
 	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/attack"
 	"github.com/laenix/vsentry/scheduler"
 	"gorm.io/gorm"
 )
@@ -22,6 +25,8 @@ func ListRules(ctx *gin.Context) {
 		return
 	}
 
+	database.FillRuleTags(db, rules)
+
 	// Debug：打印第一个 rule 的 ID
 	if len(rules) > 0 {
 		fmt.Printf("DEBUG: First rule ID = %d, Name = %s\n", rules[0].ID, rules[0].Name)
@@ -54,14 +59,45 @@ func AddRule(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "规则名称和查询语句不能为空"})
 		return
 	}
-
-	// 自动Settings初始元Data
-	rule.Version = 1
-	rule.Enabled = true
 	// DefaultRuleType为报警Rule
 	if rule.Type == "" {
 		rule.Type = "alert"
 	}
+	validation, ok := validateRule(ctx, rule)
+	if !ok {
+		return
+	}
+
+	tags, err := attack.NormalizeTags(rule.Tags)
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	rule.Tags = tags
+
+	if rule.Type == scheduler.RuleTypeAnomaly {
+		if err := validateAnomalySettings(&rule.Anomaly); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+			return
+		}
+	}
+	if err := scheduler.ValidateFollowUps(rule.FollowUps); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := scheduler.ValidateRiskSettings(rule.Risk); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := scheduler.ValidateRuleTests(rule.Tests); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+
+	// 自动Settings初始元Data（通过 API Create的Rule不受Rule包管理）
+	rule.Version = 1
+	rule.PackID, rule.ManagedKey, rule.PackHash, rule.Drift = 0, "", "", false
+	rule.Enabled = true
 
 	// 从Medium间件Get当ago操作人 ID
 	userId, exists := ctx.Get("userid")
@@ -70,18 +106,26 @@ func AddRule(ctx *gin.Context) {
 	}
 
 	db := database.GetDB()
-	if err := db.Create(&rule).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return database.ReplaceRuleTags(tx, rule.ID, rule.Tags)
+	})
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建规则失败"})
 		return
 	}
 	scheduler.GlobalEngine.ReloadRules()
 
-	// 如果Enable了回溯，立即触发一次回溯
+	// 如果Enable了回溯，立即排队一个回溯Task
 	if rule.EnableBacktrace && rule.Type == "alert" {
-		go scheduler.TriggerBacktrace(rule.ID)
+		if _, err := scheduler.TriggerBacktrace(rule.ID); err != nil {
+			log.Printf("[Backtrace] %v", err)
+		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则添加成功", "data": rule})
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则添加成功", "data": rule, "warnings": validation.Warnings})
 }
 
 // UpdateRule Update现有Rule
@@ -96,6 +140,43 @@ func UpdateRule(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "规则 ID 不能为空"})
 		return
 	}
+	drift, ok := guardManagedRule(ctx, req.ID)
+	if !ok {
+		return
+	}
+	validation, ok := validateRule(ctx, req)
+	if !ok {
+		return
+	}
+
+	// Tags 为 nil 表示本次不修改标签
+	var tags []string
+	if req.Tags != nil {
+		var err error
+		if tags, err = attack.NormalizeTags(req.Tags); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+			return
+		}
+	}
+
+	if req.Type == scheduler.RuleTypeAnomaly {
+		if err := validateAnomalySettings(&req.Anomaly); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+			return
+		}
+	}
+	if err := scheduler.ValidateFollowUps(req.FollowUps); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := scheduler.ValidateRiskSettings(req.Risk); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := scheduler.ValidateRuleTests(req.Tests); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
 
 	db := database.GetDB()
 	var existing model.Rule
@@ -115,7 +196,29 @@ func UpdateRule(ctx *gin.Context) {
 		}
 
 		// 使用 Select 指定AllowUpdate的字段，防止恶意覆盖元Data
-		return tx.Model(&existing).Select("Name", "Description", "Query", "Interval", "Severity", "Version", "AuthorID", "Type", "EnableBacktrace", "BacktraceCron", "BacktraceStart").Updates(req).Error
+		// Query或实体/数值字段变化后旧基线不再可比，需要重New学习
+		if existing.Type == scheduler.RuleTypeAnomaly &&
+			(req.Type != existing.Type || req.Query != existing.Query ||
+				req.Anomaly.EntityField != existing.Anomaly.EntityField || req.Anomaly.ValueField != existing.Anomaly.ValueField) {
+			if err := tx.Where("rule_id = ?", existing.ID).Delete(&model.AnomalyBaseline{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&existing).Select("Name", "Description", "Query", "Interval", "Severity", "Version", "AuthorID", "Type", "EnableBacktrace", "BacktraceCron", "BacktraceStart",
+			"anomaly_entity_field", "anomaly_value_field", "anomaly_method", "anomaly_threshold", "anomaly_direction", "anomaly_learning_days", "anomaly_min_samples",
+			"risk_score", "risk_entities", "risk_only", "FollowUps", "Tests").Updates(req).Error; err != nil {
+			return err
+		}
+		if drift {
+			if err := tx.Model(&existing).Update("drift", true).Error; err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			return database.ReplaceRuleTags(tx, existing.ID, tags)
+		}
+		return nil
 	})
 
 	if err != nil {
@@ -128,21 +231,27 @@ func UpdateRule(ctx *gin.Context) {
 	}
 	scheduler.GlobalEngine.ReloadRules()
 
-	// 如果Enable了回溯（New增或修改），立即触发一次回溯
-	if req.EnableBacktrace && req.Type == "alert" && req.Enabled {
-		go scheduler.TriggerBacktrace(req.ID)
+	// 如果Enable了回溯（New增或修改），立即排队一个回溯Task（已有活跃Task时跳过）
+	if req.EnableBacktrace && req.Type == "alert" && existing.Enabled {
+		if _, err := scheduler.TriggerBacktrace(req.ID); err != nil {
+			log.Printf("[Backtrace] %v", err)
+		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则更新成功"})
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则更新成功", "warnings": validation.Warnings})
 }
 
 // DeleteRule DeleteRule
 func DeleteRule(ctx *gin.Context) {
-	id := ctx.Query("id")
-	if id == "" {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 ID 参数"})
 		return
 	}
+	// flag 模式下Delete的受管Rule会在下次同步时重New创建
+	if _, ok := guardManagedRule(ctx, uint(id)); !ok {
+		return
+	}
 
 	db := database.GetDB()
 	// 硬DeleteRule，如果是ProductionEnvironment建议在 model Medium加入 gorm.DeletedAt 实现软Delete
@@ -150,6 +259,7 @@ func DeleteRule(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
 		return
 	}
+	db.Where("rule_id = ?", id).Delete(&model.AnomalyBaseline{})
 	scheduler.GlobalEngine.ReloadRules()
 	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则删除成功"})
 }
@@ -166,7 +276,23 @@ func SetRuleStatus(ctx *gin.Context, enabled bool) {
 	}
 
 	db := database.GetDB()
-	result := db.Model(&model.Rule{}).Where("id = ?", req.ID).Update("enabled", enabled)
+	var existing model.Rule
+	db.Select("id", "auto_disabled").Limit(1).Find(&existing, req.ID)
+
+	updates := map[string]interface{}{"enabled": enabled}
+	if enabled && existing.AutoDisabled {
+		// 人工重新启用被自动停用的Rule：恢复为Rule包期望的Status，不算手动修改
+		updates["auto_disabled"] = false
+	} else {
+		drift, ok := guardManagedRule(ctx, req.ID)
+		if !ok {
+			return
+		}
+		if drift {
+			updates["drift"] = true
+		}
+	}
+	result := db.Model(&model.Rule{}).Where("id = ?", req.ID).Updates(updates)
 
 	if result.Error != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "状态更新失败"})
@@ -193,3 +319,56 @@ func EnableRule(ctx *gin.Context) {
 func DisableRule(ctx *gin.Context) {
 	SetRuleStatus(ctx, false)
 }
+
+// validateAnomalySettings 补全DefaultValue并校验异常检测配置
+func validateAnomalySettings(s *model.AnomalySettings) error {
+	*s = s.WithDefaults()
+	switch s.Method {
+	case "zscore":
+	case "percentile":
+		if s.Threshold <= 50 || s.Threshold >= 100 {
+			return fmt.Errorf("percentile threshold must be between 50 and 100")
+		}
+	default:
+		return fmt.Errorf("unsupported anomaly method: %s", s.Method)
+	}
+	switch s.Direction {
+	case "up", "down", "both":
+	default:
+		return fmt.Errorf("unsupported anomaly direction: %s", s.Direction)
+	}
+	return nil
+}
+
+// ValidateRule 校验Rule但不保存，供编辑器实时提示
+// POST /rules/validate
+func ValidateRule(ctx *gin.Context) {
+	var rule model.Rule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if rule.Type == "" {
+		rule.Type = "alert"
+	}
+	result := scheduler.ValidateRule(rule)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"valid":    result.Valid(),
+		"errors":   result.Errors,
+		"warnings": result.Warnings,
+	}})
+}
+
+// validateRule 保存ago校验 Query / Interval，有Error时Return 422 和结构化的 errors / warnings
+func validateRule(ctx *gin.Context, rule model.Rule) (*scheduler.RuleValidation, bool) {
+	result := scheduler.ValidateRule(rule)
+	if !result.Valid() {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code": 422,
+			"msg":  result.Errors[0].Message,
+			"data": result,
+		})
+		return nil, false
+	}
+	return result, true
+}
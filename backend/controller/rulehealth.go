@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// GetRuleHealth GetRule健康度（上次成功、连续Failed次数、p95 耗时），?id= 只看单条Rule
+func GetRuleHealth(ctx *gin.Context) {
+	db := database.GetDB().Where("type NOT IN ?", []string{"forensic", "investigation"})
+	if id := ctx.Query("id"); id != "" {
+		db = db.Where("id = ?", id)
+	}
+
+	var rules []model.Rule
+	if err := db.Order("id asc").Find(&rules).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	result := make([]model.RuleHealth, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, scheduler.RuleHealthSummary(r))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": result})
+}
+
+// ListRuleExecutions Get单条Rule最近的Execute记录
+// GET /rules/executions?id=1&kind=scheduled&limit=50
+func ListRuleExecutions(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少 id 参数"})
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	db := database.GetDB().Where("rule_id = ?", id)
+	if kind := ctx.Query("kind"); kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+
+	var execs []model.RuleExecution
+	db.Order("id desc").Limit(limit).Find(&execs)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": execs})
+}
+
+// GetStreamRuleStats Get实时Rule当ago分钟的求值次数、命Medium数和 CPU 耗时
+func GetStreamRuleStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": scheduler.StreamStats()})
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/rulepack"
+)
+
+// ListRulePacks GetRule包List
+func ListRulePacks(ctx *gin.Context) {
+	var packs []model.RulePack
+	database.GetDB().Order("id asc").Find(&packs)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": packs})
+}
+
+// AddRulePack AddRule包（本地目录或本地 git 仓库）
+func AddRulePack(ctx *gin.Context) {
+	var pack model.RulePack
+	if err := ctx.ShouldBindJSON(&pack); err != nil || pack.Name == "" || pack.Path == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "名称和路径不能为空"})
+		return
+	}
+	if pack.Mode == "" {
+		pack.Mode = model.RulePackBlock
+	}
+	if pack.Mode != model.RulePackBlock && pack.Mode != model.RulePackFlag {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "mode must be block or flag"})
+		return
+	}
+	if _, err := rulepack.CheckPath(&pack); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	pack.ID = 0
+	if err := database.GetDB().Create(&pack).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则包添加成功", "data": pack})
+}
+
+// UpdateRulePack UpdateRule包配置
+func UpdateRulePack(ctx *gin.Context) {
+	var req model.RulePack
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if req.Mode != "" && req.Mode != model.RulePackBlock && req.Mode != model.RulePackFlag {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "mode must be block or flag"})
+		return
+	}
+	db := database.GetDB()
+	var pack model.RulePack
+	if err := db.First(&pack, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "规则包不存在"})
+		return
+	}
+	if _, err := rulepack.CheckPath(&req); err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	if err := db.Model(&pack).Select("Name", "Path", "Ref", "Subdir", "Mode", "SyncMinutes", "Enabled").Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则包更新成功"})
+}
+
+// DeleteRulePack DeleteRule包，其Rule保留并转为手动Rule
+func DeleteRulePack(ctx *gin.Context) {
+	var pack model.RulePack
+	db := database.GetDB()
+	if err := db.First(&pack, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "规则包不存在"})
+		return
+	}
+	if err := rulepack.Release(pack.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	db.Delete(&pack)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "规则包删除成功"})
+}
+
+// SyncRulePack 同步Rule包，?dry_run=true 只Return变更计划
+func SyncRulePack(ctx *gin.Context) {
+	var pack model.RulePack
+	if err := database.GetDB().First(&pack, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "规则包不存在"})
+		return
+	}
+	result, err := rulepack.Sync(&pack, ctx.Query("dry_run") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "同步完成", "data": result})
+}
+
+// ExportRules 将Rule导出为Rule包 YAML
+// GET /rulepacks/export?ids=1,2,3 (为空时导出全部)
+func ExportRules(ctx *gin.Context) {
+	db := database.GetDB()
+	if ids := ctx.Query("ids"); ids != "" {
+		db = db.Where("id IN ?", strings.Split(ids, ","))
+	}
+	var rules []model.Rule
+	if err := db.Order("id asc").Find(&rules).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询规则失败"})
+		return
+	}
+	database.FillRuleTags(database.GetDB(), rules)
+
+	content, err := rulepack.Export(rules)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "YAML 序列化失败", "error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"format":  "yaml",
+			"count":   len(rules),
+			"content": string(content),
+		},
+	})
+}
+
+// guardManagedRule 受管Rule的修改检查：block 模式直接Return 409；flag 模式Allow修改，Return true 表示需标记 drift
+func guardManagedRule(ctx *gin.Context, ruleID uint) (bool, bool) {
+	drift, err := rulepack.CheckEdit(ruleID)
+	if err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+		return false, false
+	}
+	return drift, true
+}
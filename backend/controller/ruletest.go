@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// RunRuleTests 运行单条Rule的测试用例
+// POST /rules/:id/tests/run
+func RunRuleTests(ctx *gin.Context) {
+	var rule model.Rule
+	if err := database.GetDB().First(&rule, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "规则不存在"})
+		return
+	}
+	if len(rule.Tests) == 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "规则没有测试用例"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": scheduler.RunRuleTests(rule)})
+}
+
+// RunAllRuleTests 批量运行全部带测试用例的Rule，供 CI 在Rule包合并ago检查
+// POST /rules/tests/run?enabled=true
+func RunAllRuleTests(ctx *gin.Context) {
+	db := database.GetDB()
+	if ctx.Query("enabled") == "true" {
+		db = db.Where("enabled = ?", true)
+	}
+	var rules []model.Rule
+	if err := db.Order("id asc").Find(&rules).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+
+	var tested []model.Rule
+	for _, r := range rules {
+		if len(r.Tests) > 0 && scheduler.SupportsRuleTests(r.Type) {
+			tested = append(tested, r)
+		}
+	}
+
+	// every条Rule写入各自的隔离 stream，可以并发运行
+	reports := make([]*model.RuleTestReport, len(tested))
+	sem := make(chan struct{}, 4)
+	var wg sync.WaitGroup
+	for i, r := range tested {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r model.Rule) {
+			defer func() { <-sem; wg.Done() }()
+			reports[i] = scheduler.RunRuleTests(r)
+		}(i, r)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, rep := range reports {
+		if !rep.Passed {
+			failed++
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"passed":  failed == 0,
+		"total":   len(reports),
+		"failed":  failed,
+		"reports": reports,
+	}})
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+)
+
+// ListSLAPolicies Get SLA 策略List
+func ListSLAPolicies(ctx *gin.Context) {
+	var policies []model.SLAPolicy
+	database.GetDB().Order("id asc").Find(&policies)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": policies})
+}
+
+// AddSLAPolicy Add SLA 策略，每个Severity一条
+func AddSLAPolicy(ctx *gin.Context) {
+	var p model.SLAPolicy
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateSLAPolicy(&p); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var count int64
+	db.Model(&model.SLAPolicy{}).Where("severity = ?", p.Severity).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "该级别已有 SLA 策略"})
+		return
+	}
+	p.ID = 0
+	if err := db.Create(&p).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "SLA 策略添加成功", "data": p})
+}
+
+// UpdateSLAPolicy Update SLA 策略，只影响之后创建或调整级别的 Incident
+func UpdateSLAPolicy(ctx *gin.Context) {
+	var req model.SLAPolicy
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	if msg := validateSLAPolicy(&req); msg != "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": msg})
+		return
+	}
+	db := database.GetDB()
+	var p model.SLAPolicy
+	if err := db.First(&p, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "SLA 策略不存在"})
+		return
+	}
+	var count int64
+	db.Model(&model.SLAPolicy{}).Where("severity = ? AND id != ?", req.Severity, p.ID).Count(&count)
+	if count > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "该级别已有 SLA 策略"})
+		return
+	}
+	if err := db.Model(&p).Select("Name", "Severity", "AckMinutes", "ResolveMinutes", "Enabled",
+		"EscalateAssignee", "EscalateSeverity", "EscalatePlaybookID", "EscalateWebhook", "AutoCloseHours").Updates(req).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "SLA 策略更新成功"})
+}
+
+// DeleteSLAPolicy Delete SLA 策略
+func DeleteSLAPolicy(ctx *gin.Context) {
+	result := database.GetDB().Delete(&model.SLAPolicy{}, ctx.Param("id"))
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "SLA 策略不存在"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "SLA 策略删除成功"})
+}
+
+func validateSLAPolicy(p *model.SLAPolicy) string {
+	if !severityLevels[p.Severity] {
+		return "severity must be info, low, medium, high or critical"
+	}
+	if p.Name == "" {
+		p.Name = p.Severity
+	}
+	if p.AckMinutes < 0 || p.ResolveMinutes < 0 || p.AutoCloseHours < 0 {
+		return "ack_minutes, resolve_minutes and auto_close_hours must not be negative"
+	}
+	if p.EscalateSeverity != "" && !severityLevels[p.EscalateSeverity] {
+		return "escalate_severity must be info, low, medium, high or critical"
+	}
+	if p.EscalateAssignee != 0 && !userExists(p.EscalateAssignee) {
+		return "escalate_assignee does not exist"
+	}
+	if p.EscalatePlaybookID != 0 {
+		var count int64
+		database.GetDB().Model(&model.Playbook{}).Where("id = ?", p.EscalatePlaybookID).Count(&count)
+		if count == 0 {
+			return "escalate_playbook_id does not exist"
+		}
+	}
+	if p.EscalateWebhook != "" {
+		if u, err := url.Parse(p.EscalateWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "escalate_webhook must be an http(s) URL"
+		}
+	}
+	return ""
+}
+
+// ListSLABreaches 未Resolve且已违约或即将到期的 Incident
+// GET /sla/breaches?within=30m
+func ListSLABreaches(ctx *gin.Context) {
+	within, err := time.ParseDuration(ctx.DefaultQuery("within", "0s"))
+	if err != nil || within < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "within 格式错误"})
+		return
+	}
+	deadline := time.Now().UTC().Add(within)
+	var incidents []model.Incident
+	database.GetDB().
+		Where("status != ?", "resolved").
+		Where("ack_breached = ? OR resolve_breached = ? OR (status = ? AND ack_due_at <= ?) OR resolve_due_at <= ?",
+			true, true, "new", deadline, deadline).
+		Order("resolve_due_at asc").Find(&incidents)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": incidents})
+}
+
+// GetSLAStats 按级别或处置人统计 MTTA/MTTR
+// GET /sla/stats?from=2024-01-01T00:00:00Z&to=...&group_by=severity|assignee&interval=day|week|month
+func GetSLAStats(ctx *gin.Context) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := ctx.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": param + " 需为 RFC3339 时间"})
+				return
+			}
+			*target = t
+		}
+	}
+	summary, series, err := scheduler.SLAStats(from, to, ctx.DefaultQuery("group_by", "severity"), ctx.Query("interval"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{
+		"from":    from,
+		"to":      to,
+		"summary": summary,
+		"series":  series,
+	}})
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// ListSuppressionProposals Get误报抑制建议，默认只列出待处理的建议
+// GET /tuning/proposals?rule_id=12&status=proposed
+func ListSuppressionProposals(ctx *gin.Context) {
+	db := database.GetDB().Model(&model.SuppressionProposal{})
+	if ruleID := ctx.Query("rule_id"); ruleID != "" {
+		db = db.Where("rule_id = ?", ruleID)
+	}
+	status := ctx.DefaultQuery("status", model.ProposalOpen)
+	if status != "all" {
+		db = db.Where("status = ?", status)
+	}
+	proposals := []model.SuppressionProposal{}
+	db.Order("rule_id asc, false_positives desc, true_positives asc").Find(&proposals)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": proposals})
+}
+
+// AnalyzeFalsePositives 立即重New分析误报，rule_id 为空时分析全部有误报的Rule
+func AnalyzeFalsePositives(ctx *gin.Context) {
+	var req struct {
+		RuleID uint `json:"rule_id"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+		return
+	}
+	proposals, err := scheduler.AnalyzeFalsePositives(req.RuleID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到该规则"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": proposals})
+}
+
+// AcceptSuppressionProposal 接受建议：mode=suppression (默认) Create条件型 suppress 维护窗口，
+// mode=query 把建议的 Query 写回Rule
+func AcceptSuppressionProposal(ctx *gin.Context) {
+	var req struct {
+		Mode string `json:"mode"`
+		Days int    `json:"days"` // 维护窗口有效天数，默认 tuning.suppression_days
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数格式错误"})
+			return
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = model.ProposalSuppress
+	}
+	if req.Mode != model.ProposalSuppress && req.Mode != model.ProposalQuery {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "mode 只能为 suppression 或 query"})
+		return
+	}
+	if req.Days < 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "days 不能为负数"})
+		return
+	}
+
+	db := database.GetDB()
+	var p model.SuppressionProposal
+	if err := db.First(&p, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "建议不存在"})
+		return
+	}
+	if p.Status != model.ProposalOpen {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "该建议已处理"})
+		return
+	}
+	var rule model.Rule
+	if err := db.First(&rule, p.RuleID).Error; err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未找到该规则"})
+		return
+	}
+
+	now := time.Now().UTC()
+	userID := currentUserID(ctx)
+	updates := map[string]interface{}{
+		"status":      model.ProposalAccepted,
+		"accepted_as": req.Mode,
+		"decided_by":  userID,
+		"decided_at":  now,
+	}
+
+	var window *model.MaintenanceWindow
+	var drift bool
+	if req.Mode == model.ProposalSuppress {
+		days := req.Days
+		if days == 0 {
+			days = viper.GetInt("tuning.suppression_days")
+		}
+		if days <= 0 {
+			days = 90
+		}
+		ends := now.AddDate(0, 0, days)
+		window = &model.MaintenanceWindow{
+			Name:        fmt.Sprintf("误报抑制: %s = %s", p.Field, p.Value),
+			Description: fmt.Sprintf("由误报分析建议 #%d 生成 (规则 %s，%d/%d 条误报命中，影响 %d 条真阳性告警)", p.ID, rule.Name, p.FalsePositives, p.FalseTotal, p.TruePositives),
+			Enabled:     true,
+			Action:      model.MaintenanceSuppress,
+			StartsAt:    &now,
+			EndsAt:      &ends,
+			RuleIDs:     strconv.FormatUint(uint64(rule.ID), 10),
+			Condition:   p.Condition,
+			AuthorID:    userID,
+		}
+		if err := scheduler.ValidateMaintenanceWindow(window); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": err.Error()})
+			return
+		}
+	} else {
+		if p.RefinedQuery == "" {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"code": 422, "msg": "该规则类型不支持修改查询，请使用 suppression"})
+			return
+		}
+		var ok bool
+		if drift, ok = guardManagedRule(ctx, rule.ID); !ok {
+			return
+		}
+		refined := rule
+		refined.Query = p.RefinedQuery
+		if _, ok := validateRule(ctx, refined); !ok {
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if window != nil {
+			if err := tx.Create(window).Error; err != nil {
+				return err
+			}
+			updates["maintenance_id"] = window.ID
+		} else {
+			// 排除条件改变了基线的数据来源，与 UpdateRule 一致重New学习
+			if rule.Type == scheduler.RuleTypeAnomaly {
+				if err := tx.Where("rule_id = ?", rule.ID).Delete(&model.AnomalyBaseline{}).Error; err != nil {
+					return err
+				}
+			}
+			ruleUpdates := map[string]interface{}{"query": p.RefinedQuery, "version": rule.Version + 1, "author_id": userID}
+			if drift {
+				ruleUpdates["drift"] = true
+			}
+			if err := tx.Model(&model.Rule{}).Where("id = ?", rule.ID).Updates(ruleUpdates).Error; err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&model.SuppressionProposal{}).Where("id = ? AND status = ?", p.ID, model.ProposalOpen).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errProposalDecided
+		}
+		return nil
+	})
+	if err == errProposalDecided {
+		ctx.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "该建议已处理"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "接受建议失败"})
+		return
+	}
+	if window == nil {
+		scheduler.GlobalEngine.ReloadRules()
+	}
+	db.First(&p, p.ID)
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "建议已接受", "data": gin.H{"proposal": p, "maintenance": window}})
+}
+
+var errProposalDecided = fmt.Errorf("proposal already decided")
+
+// DismissSuppressionProposal 忽略建议，之后的分析不再提出同一字段值
+func DismissSuppressionProposal(ctx *gin.Context) {
+	now := time.Now().UTC()
+	res := database.GetDB().Model(&model.SuppressionProposal{}).
+		Where("id = ? AND status = ?", ctx.Param("id"), model.ProposalOpen).
+		Updates(map[string]interface{}{"status": model.ProposalDismissed, "decided_by": currentUserID(ctx), "decided_at": now})
+	if res.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "操作失败"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "建议不存在或已处理"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 200, "msg": "建议已忽略"})
+}
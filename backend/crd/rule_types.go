@@ -0,0 +1,74 @@
+package crd
+
+import "github.com/laenix/vsentry/model"
+
+// Rule 是 VSentry 检测规则的声明式定义，用于规则包 (detection as code)
+// 一个 YAML 文件可以包含多个以 --- 分隔的规则
+//
+// 示例用法:
+//
+// apiVersion: vsentry.io/v1
+// kind: Rule
+// metadata:
+//   name: ssh-brute-force        # 规则在规则包内的稳定标识
+// spec:
+//   name: SSH Brute Force
+//   type: alert
+//   severity: high
+//   interval: "@every 5m"
+//   query: |
+//     _time:5m event.action:"ssh_login" AND event.outcome:"failure"
+//     | stats by (src_endpoint.ip) count() as failures
+//     | filter failures:>20
+//   tags:
+//     - attack.t1110
+//   backtrace:
+//     enabled: true
+//     start: 30d
+
+type Rule struct {
+	APIVersion string   `json:"apiVersion"` // vsentry.io/v1
+	Kind       string   `json:"kind"`       // Rule
+	Metadata   Metadata `json:"metadata"`
+	Spec       RuleSpec `json:"spec"`
+}
+
+type RuleSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Type: alert / anomaly / stream / forensic / investigation，默认 alert
+	Type     string `json:"type,omitempty"`
+	Query    string `json:"query"`
+	Interval string `json:"interval,omitempty"`
+	Severity string `json:"severity,omitempty"`
+
+	// 可选：启用状态，默认 true
+	Enabled *bool  `json:"enabled,omitempty"`
+	Source  string `json:"source,omitempty"`
+
+	// 标签，attack.* 前缀的为 MITRE ATT&CK 映射
+	Tags []string `json:"tags,omitempty"`
+
+	// 可选：回溯配置 (仅 alert 规则)
+	Backtrace *RuleBacktrace `json:"backtrace,omitempty"`
+
+	// 可选：异常检测配置 (仅 anomaly 规则)
+	Anomaly *model.AnomalySettings `json:"anomaly,omitempty"`
+
+	// 可选：风险贡献 (基于风险的告警)
+	Risk *model.RiskSettings `json:"risk,omitempty"`
+
+	// 可选：后续Query，every条NewAlert自动Execute
+	FollowUps []model.FollowUpQuery `json:"followUps,omitempty"`
+
+	// 可选：测试用例，样例Event及期望结果 (match / no_match)
+	Tests []model.RuleTest `json:"tests,omitempty"`
+}
+
+// RuleBacktrace 回溯配置
+type RuleBacktrace struct {
+	Enabled bool   `json:"enabled"`
+	Cron    string `json:"cron,omitempty"`
+	Start   string `json:"start,omitempty"` // 7d / 30d / 90d / 180d / 1y / 2024-01-01
+}
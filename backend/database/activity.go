@@ -0,0 +1,33 @@
+package database
+
+import (
+	"log"
+
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// SystemActor 平台自动操作的Actor名称
+const SystemActor = "system"
+
+// LogIncidentActivity 追加一条Incident活动记录，Actor 为空时按 ActorID 填充用户名
+func LogIncidentActivity(db *gorm.DB, a model.IncidentActivity) {
+	if a.IncidentID == 0 {
+		return
+	}
+	if a.Actor == "" {
+		a.Actor = SystemActor
+		if a.ActorID != 0 {
+			var user model.User
+			if db.Select("user_name").First(&user, a.ActorID).Error == nil {
+				a.Actor = user.UserName
+			}
+		}
+	}
+	a.ID = 0
+	if err := db.Create(&a).Error; err != nil {
+		log.Printf("[Incident:%d] Failed to record activity %s: %v", a.IncidentID, a.Type, err)
+		return
+	}
+	publishIncident(db, a)
+}
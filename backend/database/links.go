@@ -0,0 +1,37 @@
+package database
+
+import (
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// IncidentLinks Incident 两个方向的关联，附带对端的名称和Status
+func IncidentLinks(db *gorm.DB, id uint) []model.IncidentLinkRef {
+	var links []model.IncidentLink
+	db.Where("source_id = ? OR target_id = ?", id, id).Order("id asc").Find(&links)
+	if len(links) == 0 {
+		return nil
+	}
+	others := make([]uint, 0, len(links))
+	for _, l := range links {
+		others = append(others, l.SourceID, l.TargetID)
+	}
+	var incidents []model.Incident
+	db.Select("id", "name", "status", "severity").Where("id IN ?", others).Find(&incidents)
+	byID := make(map[uint]model.Incident, len(incidents))
+	for _, inc := range incidents {
+		byID[inc.ID] = inc
+	}
+
+	refs := make([]model.IncidentLinkRef, 0, len(links))
+	for _, l := range links {
+		ref := model.IncidentLinkRef{LinkID: l.ID, Type: l.Type, Direction: "outgoing", IncidentID: l.TargetID, Comment: l.Comment}
+		if l.SourceID != id {
+			ref.Direction, ref.IncidentID = "incoming", l.SourceID
+		}
+		other := byID[ref.IncidentID]
+		ref.Name, ref.Status, ref.Severity = other.Name, other.Status, other.Severity
+		refs = append(refs, ref)
+	}
+	return refs
+}
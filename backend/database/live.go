@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/laenix/vsentry/live"
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// publishIncident 每条活动记录对应一条实时Event，附带 Incident 当ago状态
+func publishIncident(db *gorm.DB, a model.IncidentActivity) {
+	var incidents []model.Incident
+	db.Where("id = ?", a.IncidentID).Limit(1).Find(&incidents)
+	if len(incidents) == 0 {
+		return
+	}
+	incident := incidents[0]
+	typ := live.IncidentUpdated
+	if a.Type == model.ActivityCreated {
+		typ = live.IncidentCreated
+	}
+	live.Publish(typ, incident.ID, incident.RuleID, incident.Severity, map[string]interface{}{
+		"incident": incident,
+		"activity": a,
+	})
+}
+
+// registerLiveCallbacks NewAlert不论由哪个模块写入都推送 alert.created，被抑制的Alert除外
+func registerLiveCallbacks(db *gorm.DB) {
+	db.Callback().Create().After("gorm:create").Register("live:alert_created", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Name != "Alert" {
+			return
+		}
+		alert, ok := tx.Statement.Dest.(*model.Alert)
+		if !ok || alert.Suppressed {
+			return
+		}
+		severity := alert.Severity
+		if severity == "" && alert.IncidentID != 0 {
+			var severities []string
+			tx.Session(&gorm.Session{NewDB: true}).Model(&model.Incident{}).
+				Where("id = ?", alert.IncidentID).Limit(1).Pluck("severity", &severities)
+			if len(severities) > 0 {
+				severity = severities[0]
+			}
+		}
+		live.Publish(live.AlertCreated, alert.IncidentID, alert.RuleID, severity, alert)
+	})
+}
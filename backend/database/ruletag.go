@@ -0,0 +1,49 @@
+package database
+
+import (
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// LoadRuleTags 批量加载Rule标签，Return ruleID -> tags
+func LoadRuleTags(db *gorm.DB, ruleIDs ...uint) map[uint][]string {
+	result := make(map[uint][]string)
+	if len(ruleIDs) == 0 {
+		return result
+	}
+
+	var tags []model.RuleTag
+	db.Where("rule_id IN ?", ruleIDs).Order("id asc").Find(&tags)
+	for _, t := range tags {
+		result[t.RuleID] = append(result[t.RuleID], t.Tag)
+	}
+	return result
+}
+
+// FillRuleTags 为Rule列表填充 Tags 字段
+func FillRuleTags(db *gorm.DB, rules []model.Rule) {
+	ids := make([]uint, len(rules))
+	for i, r := range rules {
+		ids[i] = r.ID
+	}
+	tagMap := LoadRuleTags(db, ids...)
+	for i := range rules {
+		rules[i].Tags = tagMap[rules[i].ID]
+	}
+}
+
+// ReplaceRuleTags 全量覆盖Rule标签
+func ReplaceRuleTags(tx *gorm.DB, ruleID uint, tags []string) error {
+	if err := tx.Unscoped().Where("rule_id = ?", ruleID).Delete(&model.RuleTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]model.RuleTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, model.RuleTag{RuleID: ruleID, Tag: tag})
+	}
+	return tx.Create(&rows).Error
+}
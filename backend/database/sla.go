@@ -0,0 +1,57 @@
+package database
+
+import (
+	"time"
+
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// ApplySLA 按当ago Severity 的 SLA 策略计算截止Time，起点为 Incident 创建Time
+func ApplySLA(db *gorm.DB, incident *model.Incident) {
+	incident.AckDueAt, incident.ResolveDueAt = nil, nil
+	var policies []model.SLAPolicy
+	db.Where("severity = ? AND enabled = ?", incident.Severity, true).Limit(1).Find(&policies)
+	if len(policies) == 0 {
+		return
+	}
+	policy := policies[0]
+	start := incident.CreatedAt
+	if start.IsZero() {
+		start = incident.FirstSeen
+	}
+	if start.IsZero() {
+		start = time.Now().UTC()
+	}
+	if policy.AckMinutes > 0 {
+		due := start.Add(time.Duration(policy.AckMinutes) * time.Minute)
+		incident.AckDueAt = &due
+	}
+	if policy.ResolveMinutes > 0 {
+		due := start.Add(time.Duration(policy.ResolveMinutes) * time.Minute)
+		incident.ResolveDueAt = &due
+	}
+}
+
+// RefreshSLA Severity变化后重新计算已有 Incident 的截止Time
+func RefreshSLA(db *gorm.DB, incident *model.Incident) {
+	ApplySLA(db, incident)
+	db.Model(incident).Updates(map[string]interface{}{
+		"ack_due_at":     incident.AckDueAt,
+		"resolve_due_at": incident.ResolveDueAt,
+	})
+}
+
+// IncidentStatusUpdates Status变更需要写入的字段，同时记录首次认领Time和ResolveTime (重新打开时清空)
+func IncidentStatusUpdates(incident model.Incident, status string, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{"status": status}
+	if status != "new" && incident.AcknowledgedAt == nil {
+		updates["acknowledged_at"] = now
+	}
+	if status == "resolved" {
+		updates["resolved_at"] = now
+	} else if incident.ResolvedAt != nil {
+		updates["resolved_at"] = nil
+	}
+	return updates
+}
@@ -35,17 +35,49 @@ func InitDB() *gorm.DB {
 	db.AutoMigrate(&model.Connector{})
 	db.AutoMigrate(&model.CollectorConfig{})
 	db.AutoMigrate(&model.Rule{})
+	db.AutoMigrate(&model.RuleTag{})
+	db.AutoMigrate(&model.RuleExecution{})
+	db.AutoMigrate(&model.RulePack{})
+	db.AutoMigrate(&model.AnomalyBaseline{})
 	db.AutoMigrate(&model.Alert{})
 	db.AutoMigrate(&model.Incident{})
+	db.AutoMigrate(&model.IncidentComment{})
+	db.AutoMigrate(&model.IncidentActivity{})
+	db.AutoMigrate(&model.IncidentLink{})
+	db.AutoMigrate(&model.Observable{})
+	db.AutoMigrate(&model.ReportTemplate{})
 	db.AutoMigrate(&model.ForensicTask{})
 	db.AutoMigrate(&model.ForensicFile{})
 	db.AutoMigrate(&model.Playbook{})
 	db.AutoMigrate(&model.PlaybookExecution{})
+	db.AutoMigrate(&model.BacktraceJob{})
+	db.AutoMigrate(&model.Indicator{})
+	db.AutoMigrate(&model.IndicatorFeed{})
+	db.AutoMigrate(&model.MaintenanceWindow{})
+	db.AutoMigrate(&model.PendingAlert{})
+	db.AutoMigrate(&model.RiskEntity{})
+	db.AutoMigrate(&model.RiskEvent{})
+	db.AutoMigrate(&model.RiskPolicy{})
+	db.AutoMigrate(&model.SLAPolicy{})
+	db.AutoMigrate(&model.NotifyChannel{})
+	db.AutoMigrate(&model.NotifyPolicy{})
+	db.AutoMigrate(&model.NotifyDelivery{})
+	db.AutoMigrate(&model.SuppressionProposal{})
+	db.AutoMigrate(&model.Asset{})
+
+	// gorm.Model 的 created_at 无法通过标签建索引，List接口按创建Time过滤/排序时使用
+	for _, table := range []string{"incidents", "alerts"} {
+		db.Exec("CREATE INDEX IF NOT EXISTS idx_" + table + "_created_at ON " + table + "(created_at)")
+	}
+
+	registerLiveCallbacks(db)
 
 	DB = db
 	createAdminIfNotExist(db)
 	createDefaultIngest(db)
 	createDefaultRules(db)
+	createDefaultRiskPolicy(db)
+	createDefaultSLAPolicies(db)
 	return db
 }
 
@@ -76,6 +108,38 @@ func createAdminIfNotExist(db *gorm.DB) {
 	}
 }
 
+// createDefaultRiskPolicy Default风险阈值策略：任意实体 24 小时内累计 100 分
+func createDefaultRiskPolicy(db *gorm.DB) {
+	var count int64
+	db.Model(&model.RiskPolicy{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	db.Create(&model.RiskPolicy{
+		Name:        "Entity risk threshold",
+		Threshold:   100,
+		WindowHours: 24,
+		Severity:    "high",
+		Enabled:     true,
+	})
+}
+
+// createDefaultSLAPolicies Default SLA：级别越高时限越短，low / info 长期无活动自动关闭
+func createDefaultSLAPolicies(db *gorm.DB) {
+	var count int64
+	db.Model(&model.SLAPolicy{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	db.Create(&[]model.SLAPolicy{
+		{Name: "Critical", Severity: "critical", AckMinutes: 15, ResolveMinutes: 4 * 60, Enabled: true},
+		{Name: "High", Severity: "high", AckMinutes: 30, ResolveMinutes: 8 * 60, Enabled: true},
+		{Name: "Medium", Severity: "medium", AckMinutes: 2 * 60, ResolveMinutes: 24 * 60, Enabled: true},
+		{Name: "Low", Severity: "low", AckMinutes: 8 * 60, ResolveMinutes: 72 * 60, Enabled: true, AutoCloseHours: 7 * 24},
+		{Name: "Info", Severity: "info", AckMinutes: 24 * 60, ResolveMinutes: 7 * 24 * 60, Enabled: true, AutoCloseHours: 72},
+	})
+}
+
 func createDefaultIngest(db *gorm.DB) {
 	var count int64
 	db.Model(&model.Ingest{}).Count(&count)
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/intel"
+	"github.com/laenix/vsentry/inventory"
+	"github.com/laenix/vsentry/scheduler"
 	"github.com/spf13/viper"
 )
 
@@ -86,7 +89,16 @@ func processPayload(payload LogPayload) {
 		workerMu.Unlock()
 	}
 
-	// 3. 投递Log到实例私有通道 (完全非阻塞)
+	// 3. 威胁情报匹配：命Medium的 IOC 由后台协程产生Alert
+	intel.Match(payload.Data)
+
+	// 资产清单：记录上报该主机的 Ingest
+	inventory.Observe(id, payload.Data)
+
+	// 4. 实时Rule求值：命Medium结果由后台协程写入Alert
+	scheduler.EvaluateStream(payload.Data)
+
+	// 5. 投递Log到实例私有通道 (完全非阻塞)
 	w.lastSeen = time.Now()
 	w.instance.Send(payload.Data)
 }
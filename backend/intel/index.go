@@ -0,0 +1,153 @@
+package intel
+
+import (
+	"net"
+	"strings"
+
+	"github.com/laenix/vsentry/model"
+)
+
+// Index 内存Medium的 IOC 匹配索引，构建后只读，Reload 时整体替换
+type Index struct {
+	exact   map[string]*model.Indicator // type|value -> IOC (ip / url / hash)
+	domains map[string]*model.Indicator // 域名后缀匹配：命Medium evil.com 也会匹配 a.evil.com
+	cidr4   *cidrNode
+	cidr6   *cidrNode
+	size    int
+}
+
+// cidrNode 按 bit 展开的前缀树，查找复杂度与地址长度相关，与 CIDR 数量无关
+type cidrNode struct {
+	child [2]*cidrNode
+	ind   *model.Indicator
+}
+
+func newIndex() *Index {
+	return &Index{
+		exact:   make(map[string]*model.Indicator),
+		domains: make(map[string]*model.Indicator),
+		cidr4:   &cidrNode{},
+		cidr6:   &cidrNode{},
+	}
+}
+
+func (idx *Index) add(ind *model.Indicator) {
+	switch ind.Type {
+	case model.IndicatorDomain:
+		idx.domains[ind.Value] = ind
+	case model.IndicatorCIDR:
+		_, network, err := net.ParseCIDR(ind.Value)
+		if err != nil {
+			return
+		}
+		ones, _ := network.Mask.Size()
+		if ip4 := network.IP.To4(); ip4 != nil {
+			idx.cidr4.insert(ip4, ones, ind)
+		} else {
+			idx.cidr6.insert(network.IP.To16(), ones, ind)
+		}
+	default:
+		idx.exact[ind.Type+"|"+ind.Value] = ind
+	}
+	idx.size++
+}
+
+// Size 索引Medium的 IOC 数量
+func (idx *Index) Size() int {
+	return idx.size
+}
+
+// MatchIP 精确 IP 优先，其次最长前缀匹配的 CIDR
+func (idx *Index) MatchIP(ip net.IP) *model.Indicator {
+	if ind, ok := idx.exact[model.IndicatorIP+"|"+ip.String()]; ok {
+		return ind
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return idx.cidr4.lookup(ip4)
+	}
+	return idx.cidr6.lookup(ip.To16())
+}
+
+// MatchDomain 逐级去掉左侧标签匹配后缀
+func (idx *Index) MatchDomain(domain string) *model.Indicator {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	for domain != "" {
+		if ind, ok := idx.domains[domain]; ok {
+			return ind
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return nil
+}
+
+// MatchURL 精确 URL 匹配，其次匹配 URL Medium的主机
+func (idx *Index) MatchURL(raw string) *model.Indicator {
+	if ind, ok := idx.exact[model.IndicatorURL+"|"+normalizeURL(raw)]; ok {
+		return ind
+	}
+	host := urlHost(raw)
+	if host == "" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return idx.MatchIP(ip)
+	}
+	return idx.MatchDomain(host)
+}
+
+// MatchHash 按长度判断哈希Type
+func (idx *Index) MatchHash(hash string) *model.Indicator {
+	typ := hashType(hash)
+	if typ == "" {
+		return nil
+	}
+	return idx.exact[typ+"|"+strings.ToLower(hash)]
+}
+
+// MatchValue 自动识别字段Value的Type并匹配
+func (idx *Index) MatchValue(v string) *model.Indicator {
+	switch typ, value := DetectType(v); typ {
+	case model.IndicatorIP:
+		return idx.MatchIP(net.ParseIP(value))
+	case model.IndicatorURL:
+		return idx.MatchURL(value)
+	case model.IndicatorDomain:
+		return idx.MatchDomain(value)
+	case model.IndicatorMD5, model.IndicatorSHA1, model.IndicatorSHA256:
+		return idx.MatchHash(value)
+	}
+	return nil
+}
+
+func (n *cidrNode) insert(ip net.IP, ones int, ind *model.Indicator) {
+	node := n
+	for i := 0; i < ones; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		if node.child[bit] == nil {
+			node.child[bit] = &cidrNode{}
+		}
+		node = node.child[bit]
+	}
+	node.ind = ind
+}
+
+// lookup Return覆盖该地址的最具体 (最长前缀) CIDR
+func (n *cidrNode) lookup(ip net.IP) *model.Indicator {
+	var found *model.Indicator
+	node := n
+	for i := 0; node != nil; i++ {
+		if node.ind != nil {
+			found = node.ind
+		}
+		if i >= len(ip)*8 {
+			break
+		}
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		node = node.child[bit]
+	}
+	return found
+}
@@ -0,0 +1,183 @@
+package intel
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/laenix/vsentry/automation"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/inventory"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/laenix/vsentry/observable"
+	"gorm.io/gorm"
+)
+
+// IncidentSource 情报命Medium产生的Incident
+const IncidentSource = "intel"
+
+// skipFields 自由文本字段不参与精确匹配
+var skipFields = map[string]bool{"raw_data": true, "_msg": true, "message": true}
+
+type hit struct {
+	indicator model.Indicator
+	field     string
+	content   string
+	at        time.Time
+}
+
+// hits 匹配结果交给后台协程写库，不阻塞 ingest 分发
+var hits = make(chan hit, 1000)
+
+// Match 用当ago索引匹配一条Event的所有字段，命Medium时异步产生Alert
+func Match(event interface{}) {
+	idx := current.Load()
+	if idx == nil || idx.Size() == 0 {
+		return
+	}
+
+	type match struct {
+		ind   *model.Indicator
+		field string
+	}
+	matched := make(map[uint]match)
+	walkEvent("", event, func(field, value string) {
+		if ind := idx.MatchValue(value); ind != nil {
+			if _, ok := matched[ind.ID]; !ok {
+				matched[ind.ID] = match{ind: ind, field: field}
+			}
+		}
+	})
+
+	for _, m := range matched {
+		content := buildHitContent(event, m.ind, m.field)
+		select {
+		case hits <- hit{indicator: *m.ind, field: m.field, content: content, at: time.Now()}:
+		default:
+			log.Printf("[Intel] Hit queue full, dropping match for %s %s", m.ind.Type, m.ind.Value)
+		}
+	}
+}
+
+// walkEvent 递归遍历Event，对every个字符串叶子节点回调 (字段Path用 . 连接)
+func walkEvent(path string, v interface{}, fn func(field, value string)) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if skipFields[k] {
+				continue
+			}
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			walkEvent(p, child, fn)
+		}
+	case []interface{}:
+		for _, child := range val {
+			walkEvent(path, child, fn)
+		}
+	case string:
+		fn(path, val)
+	}
+}
+
+// buildHitContent Alert内容为原始Event，附加 _ioc 说明命Medium的 IOC
+func buildHitContent(event interface{}, ind *model.Indicator, field string) string {
+	doc := make(map[string]interface{})
+	if m, ok := event.(map[string]interface{}); ok {
+		for k, v := range m {
+			doc[k] = v
+		}
+	} else {
+		doc["event"] = event
+	}
+	doc["_ioc"] = map[string]interface{}{
+		"indicator_id": ind.ID,
+		"type":         ind.Type,
+		"value":        ind.Value,
+		"field":        field,
+		"source":       ind.Source,
+		"confidence":   ind.Confidence,
+	}
+	raw, _ := json.Marshal(doc)
+	return string(raw)
+}
+
+func runHitWriter() {
+	for h := range hits {
+		recordHit(h)
+	}
+}
+
+// recordHit 同一 IOC 复用未Resolve的Incident，Alert指纹基于 IOC + Event内容去重
+func recordHit(h hit) {
+	db := database.GetDB()
+	now := time.Now().UTC()
+	ind := h.indicator
+	name := fmt.Sprintf("IOC match: %s %s", ind.Type, ind.Value)
+
+	fp := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("ioc-%d-%s", ind.ID, h.content))))
+	var count int64
+	db.Model(&model.Alert{}).Where("fingerprint = ?", fp).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	var incident model.Incident
+	created := false
+	err := db.Where("source = ? AND name = ? AND status != ?", IncidentSource, name, "resolved").
+		Order("last_seen desc").First(&incident).Error
+	if err != nil {
+		incident = model.Incident{
+			Name:      name,
+			Severity:  ind.Severity,
+			Status:    "new",
+			Source:    IncidentSource,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		database.ApplySLA(db, &incident)
+		if err := db.Create(&incident).Error; err != nil {
+			log.Printf("[Intel] Failed to create incident: %v", err)
+			return
+		}
+		created = true
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incident.ID,
+			Type:       model.ActivityCreated,
+			To:         incident.Status,
+			Detail:     fmt.Sprintf("indicator %s %s from %s", ind.Type, ind.Value, ind.Source),
+		})
+	}
+
+	alert := model.Alert{
+		IncidentID:  incident.ID,
+		Content:     h.content,
+		Fingerprint: fp,
+		IndicatorID: ind.ID,
+	}
+	inventory.Enrich(db, &alert)
+	if err := db.Create(&alert).Error; err != nil {
+		return
+	}
+	observable.Record(db, alert)
+	db.Model(&incident).Updates(map[string]interface{}{
+		"alert_count": gorm.Expr("alert_count + ?", 1),
+		"last_seen":   now,
+	})
+	db.Model(&model.Indicator{}).Where("id = ?", ind.ID).Updates(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + ?", 1),
+		"last_hit_at": h.at,
+	})
+
+	if created {
+		log.Printf("[Intel] %s (field: %s)", name, h.field)
+		incident.AlertCount = 1
+		go notify.Incident(model.NotifyIncidentCreated, incident, fmt.Sprintf("indicator %s %s matched field %s", ind.Type, ind.Value, h.field))
+		go automation.DispatchByIncident(incident)
+	}
+}
@@ -0,0 +1,452 @@
+package intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/model"
+)
+
+// Feed 格式
+const (
+	FormatCSV  = "csv"
+	FormatList = "list"
+	FormatMISP = "misp"
+	FormatSTIX = "stix"
+)
+
+var (
+	hexPattern    = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	domainPattern = regexp.MustCompile(`^(?i)([a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,62}\.?$`)
+	// STIX pattern Medium的比较表达式，如 [ipv4-addr:value = '1.2.3.4'] / [file:hashes.'SHA-256' = '...']
+	stixComparison = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|url|file):(value|hashes\.'?([A-Za-z0-9-]+)'?)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+)
+
+// Parse 按格式Parse情报数据；defaultType 用于无法自动识别的条目
+func Parse(format string, data []byte, defaultType string) ([]model.Indicator, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return parseCSV(data, defaultType)
+	case FormatList, "", "txt":
+		return parseList(data, defaultType)
+	case FormatMISP:
+		return parseMISP(data)
+	case FormatSTIX, "stix2":
+		return parseSTIX(data)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: %s", format)
+	}
+}
+
+// ValidFormat 是否为支持的 Feed 格式
+func ValidFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatCSV, FormatList, "", "txt", FormatMISP, FormatSTIX, "stix2":
+		return true
+	}
+	return false
+}
+
+// Normalize 校验并规范化 IOC；typ 为空时自动识别
+func Normalize(typ, value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", fmt.Errorf("empty indicator value")
+	}
+	if typ == "" {
+		typ, _ = DetectType(value)
+	}
+
+	switch typ {
+	case model.IndicatorIP:
+		ip := net.ParseIP(strings.Trim(value, "[]"))
+		if ip == nil {
+			return "", "", fmt.Errorf("invalid ip: %s", value)
+		}
+		return typ, ip.String(), nil
+	case model.IndicatorCIDR:
+		ip, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid cidr: %s", value)
+		}
+		if ones, bits := network.Mask.Size(); ones == bits {
+			return model.IndicatorIP, ip.String(), nil
+		}
+		return typ, network.String(), nil
+	case model.IndicatorDomain:
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+		if !domainPattern.MatchString(value) {
+			return "", "", fmt.Errorf("invalid domain: %s", value)
+		}
+		return typ, value, nil
+	case model.IndicatorURL:
+		if urlHost(value) == "" {
+			return "", "", fmt.Errorf("invalid url: %s", value)
+		}
+		return typ, normalizeURL(value), nil
+	case model.IndicatorMD5, model.IndicatorSHA1, model.IndicatorSHA256:
+		if hashType(value) != typ {
+			return "", "", fmt.Errorf("invalid %s: %s", typ, value)
+		}
+		return typ, strings.ToLower(value), nil
+	case "":
+		return "", "", fmt.Errorf("cannot detect indicator type: %s", value)
+	default:
+		return "", "", fmt.Errorf("unsupported indicator type: %s", typ)
+	}
+}
+
+// DetectType 根据Value的形态识别 IOC Type，无法识别时Return空
+func DetectType(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if v == "" || len(v) > 2048 || strings.ContainsAny(v, " \t\r\n") {
+		return "", v
+	}
+	if ip := net.ParseIP(strings.Trim(v, "[]")); ip != nil {
+		return model.IndicatorIP, ip.String()
+	}
+	if strings.Contains(v, "/") {
+		if _, _, err := net.ParseCIDR(v); err == nil {
+			return model.IndicatorCIDR, v
+		}
+	}
+	if strings.Contains(v, "://") {
+		return model.IndicatorURL, v
+	}
+	if typ := hashType(v); typ != "" {
+		return typ, strings.ToLower(v)
+	}
+	if strings.Contains(v, ".") && domainPattern.MatchString(v) {
+		return model.IndicatorDomain, strings.TrimSuffix(strings.ToLower(v), ".")
+	}
+	return "", v
+}
+
+func hashType(v string) string {
+	if !hexPattern.MatchString(v) {
+		return ""
+	}
+	switch len(v) {
+	case 32:
+		return model.IndicatorMD5
+	case 40:
+		return model.IndicatorSHA1
+	case 64:
+		return model.IndicatorSHA256
+	}
+	return ""
+}
+
+// normalizeURL scheme/host 小写，去掉末尾的 /
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+func urlHost(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// parseList 每行一个 IOC，# 开头为注释
+func parseList(data []byte, defaultType string) ([]model.Indicator, error) {
+	var result []model.Indicator
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		result = appendIndicator(result, model.Indicator{Value: line}, defaultType)
+	}
+	return result, scanner.Err()
+}
+
+// parseCSV 支持表头 type,value,source,confidence,severity,description,expires_at；没有表头时第一列为Value
+func parseCSV(data []byte, defaultType string) ([]model.Indicator, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	cols := map[string]int{"value": 0}
+	first := true
+	var result []model.Indicator
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		if first {
+			first = false
+			if header := csvHeader(record); header != nil {
+				cols = header
+				continue
+			}
+		}
+
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		ind := model.Indicator{
+			Type:        strings.ToLower(get("type")),
+			Value:       get("value"),
+			Source:      get("source"),
+			Severity:    strings.ToLower(get("severity")),
+			Description: get("description"),
+		}
+		if c, err := strconv.Atoi(get("confidence")); err == nil {
+			ind.Confidence = c
+		}
+		if t := parseTime(get("expires_at")); t != nil {
+			ind.ExpiresAt = t
+		}
+		result = appendIndicator(result, ind, defaultType)
+	}
+	return result, nil
+}
+
+func csvHeader(record []string) map[string]int {
+	aliases := map[string]string{
+		"type": "type", "indicator_type": "type",
+		"value": "value", "indicator": "value", "ioc": "value",
+		"source": "source", "confidence": "confidence", "severity": "severity",
+		"description": "description", "comment": "description",
+		"expires_at": "expires_at", "expiry": "expires_at", "valid_until": "expires_at",
+	}
+	cols := make(map[string]int)
+	for i, name := range record {
+		if col, ok := aliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			cols[col] = i
+		}
+	}
+	if _, ok := cols["value"]; !ok {
+		return nil
+	}
+	return cols
+}
+
+type mispAttribute struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Comment string `json:"comment"`
+	ToIDS   *bool  `json:"to_ids"`
+}
+
+type mispEvent struct {
+	Info      string          `json:"info"`
+	Attribute []mispAttribute `json:"Attribute"`
+	Object    []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+type mispWrapper struct {
+	Event mispEvent `json:"Event"`
+}
+
+// parseMISP 支持单个 Event、Event 数Group以及 /events/restSearch 的 {"response": [...]} 格式
+func parseMISP(data []byte) ([]model.Indicator, error) {
+	var wrappers []mispWrapper
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &wrappers); err != nil {
+			return nil, fmt.Errorf("invalid MISP JSON: %v", err)
+		}
+	default:
+		var doc struct {
+			Event    *mispEvent    `json:"Event"`
+			Response []mispWrapper `json:"response"`
+		}
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("invalid MISP JSON: %v", err)
+		}
+		if doc.Event != nil {
+			wrappers = append(wrappers, mispWrapper{Event: *doc.Event})
+		}
+		wrappers = append(wrappers, doc.Response...)
+	}
+
+	var result []model.Indicator
+	for _, w := range wrappers {
+		attrs := w.Event.Attribute
+		for _, obj := range w.Event.Object {
+			attrs = append(attrs, obj.Attribute...)
+		}
+		for _, a := range attrs {
+			if a.ToIDS != nil && !*a.ToIDS {
+				continue
+			}
+			desc := a.Comment
+			if desc == "" {
+				desc = w.Event.Info
+			}
+			for _, ind := range mispIndicators(a) {
+				ind.Description = desc
+				result = appendIndicator(result, ind, "")
+			}
+		}
+	}
+	return result, nil
+}
+
+// mispIndicators 将 MISP Attribute Type映射为 IOC，复合Type (如 domain|ip、filename|sha256) 拆开
+func mispIndicators(a mispAttribute) []model.Indicator {
+	parts := strings.Split(a.Value, "|")
+	types := strings.Split(a.Type, "|")
+	var result []model.Indicator
+	for i, t := range types {
+		if i >= len(parts) {
+			break
+		}
+		var typ string
+		switch t {
+		case "ip-src", "ip-dst":
+			typ = ""
+		case "domain", "hostname":
+			typ = model.IndicatorDomain
+		case "url", "uri", "link":
+			typ = model.IndicatorURL
+		case "md5", "sha1", "sha256":
+			typ = t
+		default:
+			continue
+		}
+		result = append(result, model.Indicator{Type: typ, Value: parts[i]})
+	}
+	return result
+}
+
+// parseSTIX Parse STIX 2.1 bundle：indicator 对象的 pattern，以及直接出现的 SCO (ipv4-addr / domain-name / url / file)
+func parseSTIX(data []byte) ([]model.Indicator, error) {
+	var bundle struct {
+		Objects []struct {
+			Type        string            `json:"type"`
+			Name        string            `json:"name"`
+			Description string            `json:"description"`
+			Pattern     string            `json:"pattern"`
+			PatternType string            `json:"pattern_type"`
+			Confidence  *int              `json:"confidence"`
+			ValidUntil  string            `json:"valid_until"`
+			Revoked     bool              `json:"revoked"`
+			Value       string            `json:"value"`
+			Hashes      map[string]string `json:"hashes"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid STIX bundle: %v", err)
+	}
+
+	var result []model.Indicator
+	for _, obj := range bundle.Objects {
+		if obj.Revoked {
+			continue
+		}
+		switch obj.Type {
+		case "indicator":
+			if obj.PatternType != "" && obj.PatternType != "stix" {
+				continue
+			}
+			desc := obj.Name
+			if desc == "" {
+				desc = obj.Description
+			}
+			for _, m := range stixComparison.FindAllStringSubmatch(obj.Pattern, -1) {
+				typ := stixType(m[1], m[3])
+				if typ == "-" {
+					continue
+				}
+				ind := model.Indicator{Type: typ, Value: strings.ReplaceAll(m[4], `\'`, `'`), Description: desc}
+				if obj.Confidence != nil {
+					ind.Confidence = *obj.Confidence
+				}
+				ind.ExpiresAt = parseTime(obj.ValidUntil)
+				result = appendIndicator(result, ind, "")
+			}
+		case "ipv4-addr", "ipv6-addr", "domain-name", "url":
+			result = appendIndicator(result, model.Indicator{Type: stixType(obj.Type, ""), Value: obj.Value}, "")
+		case "file":
+			for algo, hash := range obj.Hashes {
+				if typ := stixType("file", algo); typ != "-" {
+					result = appendIndicator(result, model.Indicator{Type: typ, Value: hash}, "")
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// stixType STIX 对象Type -> IOC Type，"-" 表示不支持；IP 留空由 Normalize 区分 IP/CIDR
+func stixType(object, hashAlgo string) string {
+	switch object {
+	case "ipv4-addr", "ipv6-addr":
+		return ""
+	case "domain-name":
+		return model.IndicatorDomain
+	case "url":
+		return model.IndicatorURL
+	case "file":
+		switch strings.ToUpper(strings.ReplaceAll(hashAlgo, "-", "")) {
+		case "MD5":
+			return model.IndicatorMD5
+		case "SHA1":
+			return model.IndicatorSHA1
+		case "SHA256":
+			return model.IndicatorSHA256
+		}
+	}
+	return "-"
+}
+
+// appendIndicator 规范化后追加，无法识别的条目直接丢弃
+func appendIndicator(list []model.Indicator, ind model.Indicator, defaultType string) []model.Indicator {
+	typ, value, err := Normalize(ind.Type, ind.Value)
+	if err != nil && ind.Type == "" && defaultType != "" {
+		typ, value, err = Normalize(defaultType, ind.Value)
+	}
+	if err != nil {
+		return list
+	}
+	ind.Type, ind.Value = typ, value
+	return append(list, ind)
+}
+
+func parseTime(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
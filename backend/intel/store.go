@@ -0,0 +1,281 @@
+package intel
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+	"gorm.io/gorm/clause"
+)
+
+// maxFeedSize 单个情报源最大 100MB
+const maxFeedSize = 100 << 20
+
+var current atomic.Pointer[Index]
+
+// Init 加载 IOC 索引，StartAlert写入协程和情报源定时同步
+func Init() {
+	if err := Reload(); err != nil {
+		log.Printf("[Intel] Failed to load indicators: %v", err)
+	}
+	go runHitWriter()
+	go runRefresher()
+}
+
+// Current Return当ago索引（可能为 nil）
+func Current() *Index {
+	return current.Load()
+}
+
+// Reload 从Data库重建索引，已过期的 IOC 不参与匹配
+func Reload() error {
+	var indicators []model.Indicator
+	if err := database.GetDB().Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&indicators).Error; err != nil {
+		return err
+	}
+	idx := newIndex()
+	for i := range indicators {
+		idx.add(&indicators[i])
+	}
+	current.Store(idx)
+	log.Printf("[Intel] Loaded %d indicators", idx.Size())
+	return nil
+}
+
+// ImportOptions 导入时的Default属性，数据本身带有的字段优先
+type ImportOptions struct {
+	FeedID     uint
+	Source     string
+	Confidence int
+	Severity   string
+	TTLDays    int
+}
+
+// Import Write入 IOC：(type, value) 已存在时Update元Data并续期，完成后重建索引
+func Import(indicators []model.Indicator, opts ImportOptions) (int, error) {
+	if len(indicators) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	seen := make(map[string]bool)
+	batch := make([]model.Indicator, 0, len(indicators))
+	for _, ind := range indicators {
+		key := ind.Type + "|" + ind.Value
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		ind.FeedID = opts.FeedID
+		if ind.Source == "" {
+			ind.Source = opts.Source
+		}
+		if ind.Confidence <= 0 {
+			ind.Confidence = opts.Confidence
+		}
+		if ind.Confidence <= 0 {
+			ind.Confidence = 50
+		}
+		if ind.Severity == "" {
+			ind.Severity = opts.Severity
+		}
+		if ind.Severity == "" {
+			ind.Severity = severityFromConfidence(ind.Confidence)
+		}
+		if ind.ExpiresAt == nil && opts.TTLDays > 0 {
+			expires := now.AddDate(0, 0, opts.TTLDays)
+			ind.ExpiresAt = &expires
+		}
+		batch = append(batch, ind)
+	}
+
+	err := database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "type"}, {Name: "value"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source", "confidence", "severity", "description", "feed_id", "expires_at", "updated_at"}),
+	}).CreateInBatches(&batch, 500).Error
+	if err != nil {
+		return 0, err
+	}
+	return len(batch), Reload()
+}
+
+// Fetch 下载 http/https 情报源，或读取 intel.feed_dir 目录下的本地File
+func Fetch(location string) ([]byte, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		resp, err := fetchClient.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("fetch %s: HTTP %d", location, resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	}
+	if err := CheckLocation(location); err != nil {
+		return nil, err
+	}
+
+	path, err := localFeedPath(location)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxFeedSize))
+}
+
+// CheckLocation 保存情报源时校验地址，规则与 Fetch 相同
+func CheckLocation(location string) error {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return nil
+	}
+	if strings.Contains(location, "://") {
+		return fmt.Errorf("unsupported feed scheme: only http and https are allowed")
+	}
+	_, err := localFeedPath(location)
+	return err
+}
+
+// localFeedPath 本地File必须位于 intel.feed_dir 之内 (解析符号链接后)，未配置时不允许读取本地File
+func localFeedPath(location string) (string, error) {
+	dir := viper.GetString("intel.feed_dir")
+	if dir == "" {
+		return "", fmt.Errorf("local feed files are disabled, set intel.feed_dir to allow them")
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("intel.feed_dir: %v", err)
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return "", err
+	}
+	path := location
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("feed file must be inside intel.feed_dir")
+	}
+	return path, nil
+}
+
+// fetchClient 连接时检查目标地址 (含重定向)：本机、链路本地和内网地址需开启 intel.allow_internal
+var fetchClient = &http.Client{
+	Timeout: 60 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || viper.GetBool("intel.allow_internal") {
+					return nil
+				}
+				if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsPrivate() {
+					return fmt.Errorf("feed address %s is internal, set intel.allow_internal to allow it", ip)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// SyncFeed 拉取并导入一个情报源，记录同步结果
+func SyncFeed(feed *model.IndicatorFeed) (int, error) {
+	count, err := syncFeed(feed)
+	now := time.Now()
+	updates := map[string]interface{}{"last_sync_at": now, "last_error": ""}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		log.Printf("[Intel] Feed %s sync failed: %v", feed.Name, err)
+	} else {
+		updates["last_count"] = count
+		log.Printf("[Intel] Feed %s synced: %d indicators", feed.Name, count)
+	}
+	database.GetDB().Model(feed).Updates(updates)
+	return count, err
+}
+
+func syncFeed(feed *model.IndicatorFeed) (int, error) {
+	data, err := Fetch(feed.Location)
+	if err != nil {
+		return 0, err
+	}
+	indicators, err := Parse(feed.Format, data, feed.DefaultType)
+	if err != nil {
+		return 0, err
+	}
+	source := feed.Source
+	if source == "" {
+		source = feed.Name
+	}
+	return Import(indicators, ImportOptions{
+		FeedID:     feed.ID,
+		Source:     source,
+		Confidence: feed.Confidence,
+		TTLDays:    feed.TTLDays,
+	})
+}
+
+// runRefresher every分钟同步到期的情报源；有 IOC 过期时重建索引将其剔除
+func runRefresher() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		db := database.GetDB()
+		var feeds []model.IndicatorFeed
+		db.Where("enabled = ? AND refresh_minutes > 0", true).Find(&feeds)
+		for i := range feeds {
+			feed := &feeds[i]
+			if feed.LastSyncAt != nil && now.Sub(*feed.LastSyncAt) < time.Duration(feed.RefreshMinutes)*time.Minute {
+				continue
+			}
+			SyncFeed(feed)
+		}
+
+		var expired int64
+		db.Model(&model.Indicator{}).Where("expires_at > ? AND expires_at <= ?", last, now).Count(&expired)
+		if expired > 0 {
+			Reload()
+		}
+		last = now
+	}
+}
+
+func severityFromConfidence(confidence int) string {
+	switch {
+	case confidence >= 80:
+		return "high"
+	case confidence >= 50:
+		return "medium"
+	default:
+		return "low"
+	}
+}
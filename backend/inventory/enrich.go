@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"encoding/json"
+
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/observable"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// maxMatchValues 每类 Observable 最多用于匹配的取值数
+const maxMatchValues = 20
+
+// criticalityRank 资产重要性排序
+var criticalityRank = map[string]int{
+	model.CriticalityLow:      1,
+	model.CriticalityMedium:   2,
+	model.CriticalityHigh:     3,
+	model.CriticalityCritical: 4,
+}
+
+// ValidCriticality 重要性是否合法，空表示未设置
+func ValidCriticality(c string) bool {
+	_, ok := criticalityRank[c]
+	return c == "" || ok
+}
+
+// SeverityBoost 涉及该重要性资产的Alert至少提升到的级别 (inventory.severity_boost)，未配置时 critical 资产提升到 high
+func SeverityBoost(criticality string) string {
+	if s := viper.GetString("inventory.severity_boost." + criticality); s != "" {
+		return s
+	}
+	if criticality == model.CriticalityCritical {
+		return "high"
+	}
+	return ""
+}
+
+// Match 找出Alert内容Medium出现的资产 (主机名、IP、账号)
+func Match(db *gorm.DB, content string) []model.AssetRef {
+	var hosts, ips, users []string
+	for _, item := range observable.Extract(content) {
+		switch item.Type {
+		case model.ObservableHost:
+			if h := hostName(item.Value); h != "" && len(hosts) < maxMatchValues {
+				hosts = append(hosts, h)
+			}
+		case model.ObservableIP:
+			if len(ips) < maxMatchValues {
+				ips = append(ips, item.Value)
+			}
+		case model.ObservableUser:
+			if u, _ := UserName(item.Value, ""); u != "" && len(users) < maxMatchValues {
+				users = append(users, u)
+			}
+		}
+	}
+	if len(hosts)+len(ips)+len(users) == 0 {
+		return nil
+	}
+
+	query := db.Model(&model.Asset{}).Where("1 = 0")
+	if len(hosts) > 0 {
+		query = query.Or("kind = ? AND name IN ?", model.AssetHost, hosts)
+	}
+	if len(users) > 0 {
+		query = query.Or("kind = ? AND name IN ?", model.AssetUser, users)
+	}
+	for _, ip := range ips {
+		query = query.Or("kind = ? AND (',' || ips || ',') LIKE ?", model.AssetHost, "%,"+ip+",%")
+	}
+	var assets []model.Asset
+	query.Order("id asc").Limit(50).Find(&assets)
+
+	refs := make([]model.AssetRef, 0, len(assets))
+	for _, a := range assets {
+		refs = append(refs, model.AssetRef{
+			ID:          a.ID,
+			Kind:        a.Kind,
+			Name:        a.Name,
+			Scope:       a.Scope,
+			Owner:       a.Owner,
+			Criticality: a.Criticality,
+			Environment: a.Environment,
+		})
+	}
+	return refs
+}
+
+// Enrich 把命Medium的资产写入 alert.Assets，Return其中重要性最高的资产 (均未设置重要性时为 nil)
+func Enrich(db *gorm.DB, alert *model.Alert) *model.AssetRef {
+	refs := Match(db, alert.Content)
+	if len(refs) == 0 {
+		return nil
+	}
+	alert.Assets, _ = json.Marshal(refs)
+	var top *model.AssetRef
+	for i := range refs {
+		if rank := criticalityRank[refs[i].Criticality]; rank > 0 && (top == nil || rank > criticalityRank[top.Criticality]) {
+			top = &refs[i]
+		}
+	}
+	return top
+}
@@ -0,0 +1,370 @@
+// Package inventory 资产清单：定期对 VictoriaLogs 做 stats 聚合，从 OCSF 字段中发现主机、账号和服务，
+// 合并到 Asset 表 (首次/最近出现Time、IP、操作系统、登录过的主机等)。人工维护的负责人、重要性等属性不会被覆盖。
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// 列表字段最多保留的条目数
+const (
+	maxIPs     = 16
+	maxSources = 16
+	maxHosts   = 50
+)
+
+// classAuthentication OCSF Authentication，账号在这类Event的 observer 上视为登录过该主机
+const classAuthentication = "3002"
+
+var client = &http.Client{Timeout: 60 * time.Second}
+
+var (
+	syncMu   sync.Mutex
+	lastSync time.Time
+
+	// 主机名 => 最近上报该主机Log的 Ingest，由 Observe 在写入链路上记录
+	reportMu  sync.Mutex
+	reporters = make(map[string]uint)
+)
+
+func syncInterval() time.Duration {
+	if d := viper.GetDuration("inventory.interval"); d > 0 {
+		return d
+	}
+	return 10 * time.Minute
+}
+
+// lookback 首次同步 (或重启后) 回溯的Time
+func lookback() time.Duration {
+	if d := viper.GetDuration("inventory.lookback"); d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// ingestDelay 同步截止到 now-delay，给 VictoriaLogs 留出写入刷盘的Time
+func ingestDelay() time.Duration {
+	if d := viper.GetDuration("inventory.delay"); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+func maxRows() int {
+	if n := viper.GetInt("inventory.max_rows"); n > 0 {
+		return n
+	}
+	return 50000
+}
+
+func victoriaLogsURL() string {
+	addr := viper.GetString("victorialogs.url")
+	if addr == "" {
+		addr = "http://127.0.0.1:9428"
+	}
+	return addr
+}
+
+// Init Start后台同步
+func Init() {
+	go func() {
+		if _, err := Sync(time.Now().UTC()); err != nil {
+			log.Printf("[Inventory] Sync failed: %v", err)
+		}
+		ticker := time.NewTicker(syncInterval())
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if _, err := Sync(now.UTC()); err != nil {
+				log.Printf("[Inventory] Sync failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Observe 记录上报该Event的 Ingest，在写入链路上调用，只做内存操作
+func Observe(ingestID uint, event interface{}) {
+	host := strings.ToLower(stringField(event, "observer", "hostname"))
+	if host == "" || ingestID == 0 {
+		return
+	}
+	reportMu.Lock()
+	reporters[host] = ingestID
+	reportMu.Unlock()
+}
+
+// stringField 读取嵌套或已展开 (a.b) 的字符串字段
+func stringField(event interface{}, path ...string) string {
+	m, ok := event.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if v, ok := m[strings.Join(path, ".")].(string); ok {
+		return v
+	}
+	for i, key := range path {
+		child, ok := m[key]
+		if !ok {
+			return ""
+		}
+		if i == len(path)-1 {
+			s, _ := child.(string)
+			return s
+		}
+		if m, ok = child.(map[string]interface{}); !ok {
+			return ""
+		}
+	}
+	return ""
+}
+
+// SyncResult 一次同步的统计
+type SyncResult struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Hosts    int       `json:"hosts"`
+	Users    int       `json:"users"`
+	Services int       `json:"services"`
+}
+
+// observation 一次同步Medium对同一资产的聚合结果
+type observation struct {
+	kind, name, scope string
+	ips, sources      []string
+	hosts             []string
+	os, osVersion     string
+	sid               string
+	startType, state  string
+	first, last       time.Time
+	events            int64
+}
+
+// Sync 聚合 [上次同步, now-delay) 之间的Log并更新资产清单，窗口左闭右开，相邻两次同步不会重复统计边界上的Event
+func Sync(now time.Time) (*SyncResult, error) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	until := now.Add(-ingestDelay())
+	since := lastSync
+	if since.IsZero() {
+		since = resumePoint(until)
+	}
+	result := &SyncResult{Since: since, Until: until}
+	if !until.After(since) {
+		return result, nil
+	}
+
+	found := make(map[string]*observation)
+	for _, src := range sources {
+		rows, err := queryStats(src.query, since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			o := src.parse(row)
+			if o == nil || o.name == "" {
+				continue
+			}
+			o.first, o.last = rowTime(row["first_seen"]), rowTime(row["last_seen"])
+			o.events, _ = strconv.ParseInt(row["events"], 10, 64)
+			key := o.kind + "\x00" + o.name + "\x00" + o.scope
+			if prev, ok := found[key]; ok {
+				prev.merge(o)
+			} else {
+				found[key] = o
+			}
+		}
+	}
+
+	// 全部资产在一个事务中写入，部分Failed时整体回滚且不推进 lastSync，下次同步不会重复累计Event数
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, o := range found {
+			if err := save(tx, o); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range found {
+		switch o.kind {
+		case model.AssetHost:
+			result.Hosts++
+		case model.AssetUser:
+			result.Users++
+		case model.AssetService:
+			result.Services++
+		}
+	}
+
+	reportMu.Lock()
+	pending := reporters
+	reporters = make(map[string]uint)
+	reportMu.Unlock()
+	for host, ingestID := range pending {
+		db.Model(&model.Asset{}).Where("kind = ? AND name = ? AND scope = ?", model.AssetHost, host, "").Update("ingest_id", ingestID)
+	}
+
+	lastSync = until
+	return result, nil
+}
+
+// resumePoint 进程启动后从最近一次发现的资产之后继续 (该Event已统计)，避免重复累计Event数；最多回溯 lookback
+func resumePoint(until time.Time) time.Time {
+	since := until.Add(-lookback())
+	var latest model.Asset
+	database.GetDB().Where("manual = ?", false).Order("last_seen desc").Limit(1).Find(&latest)
+	if next := latest.LastSeen.Add(time.Nanosecond); latest.ID != 0 && next.After(since) && next.Before(until) {
+		return next.UTC()
+	}
+	return since
+}
+
+func (o *observation) merge(other *observation) {
+	o.ips = appendUnique(o.ips, maxIPs, other.ips...)
+	o.sources = appendUnique(o.sources, maxSources, other.sources...)
+	o.hosts = appendUnique(o.hosts, maxHosts, other.hosts...)
+	o.os = firstNonEmpty(other.os, o.os)
+	o.osVersion = firstNonEmpty(other.osVersion, o.osVersion)
+	o.sid = firstNonEmpty(other.sid, o.sid)
+	if other.last.After(o.last) {
+		o.startType = firstNonEmpty(other.startType, o.startType)
+		o.state = firstNonEmpty(other.state, o.state)
+	}
+	if o.first.IsZero() || (!other.first.IsZero() && other.first.Before(o.first)) {
+		o.first = other.first
+	}
+	if other.last.After(o.last) {
+		o.last = other.last
+	}
+	o.events += other.events
+}
+
+// save 把聚合结果合并到资产表
+func save(db *gorm.DB, o *observation) error {
+	var a model.Asset
+	db.Where(model.Asset{Kind: o.kind, Name: o.name, Scope: o.scope}).FirstOrInit(&a)
+	if a.FirstSeen.IsZero() || (!o.first.IsZero() && o.first.Before(a.FirstSeen)) {
+		a.FirstSeen = o.first
+	}
+	if o.last.After(a.LastSeen) {
+		a.LastSeen = o.last
+		a.StartType = firstNonEmpty(o.startType, a.StartType)
+		a.State = firstNonEmpty(o.state, a.State)
+	}
+	a.Manual = false
+	a.Events += o.events
+	a.IPs = joinList(appendUnique(splitList(a.IPs), maxIPs, o.ips...))
+	a.Sources = joinList(appendUnique(splitList(a.Sources), maxSources, o.sources...))
+	a.Hosts = joinList(appendUnique(splitList(a.Hosts), maxHosts, o.hosts...))
+	a.OS = firstNonEmpty(o.os, a.OS)
+	a.OSVersion = firstNonEmpty(o.osVersion, a.OSVersion)
+	a.SID = firstNonEmpty(o.sid, a.SID)
+	return db.Save(&a).Error
+}
+
+// queryStats Execute stats Query，Return每行字段 (VictoriaLogs 的 stats 结果均为字符串)；
+// VictoriaLogs 的 start/end 都包含边界，end 取 until 之前 1ns 使窗口为 [since, until)
+func queryStats(query string, since, until time.Time) ([]map[string]string, error) {
+	resp, err := client.PostForm(victoriaLogsURL()+"/select/logsql/query", url.Values{
+		"query": {query},
+		"start": {since.Format(time.RFC3339Nano)},
+		"end":   {until.Add(-time.Nanosecond).Format(time.RFC3339Nano)},
+		"limit": {strconv.Itoa(maxRows())},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("query error (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var rows []map[string]string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var raw map[string]interface{}
+		if json.Unmarshal([]byte(line), &raw) != nil {
+			continue
+		}
+		row := make(map[string]string, len(raw))
+		for k, v := range raw {
+			if v != nil {
+				row[k] = strings.TrimSpace(fmt.Sprint(v))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func rowTime(v string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, v)
+	return t.UTC()
+}
+
+// blank 日志中表示缺失的取值
+func blank(v string) bool {
+	return v == "" || v == "-" || v == "N/A"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if !blank(v) {
+			return v
+		}
+	}
+	return ""
+}
+
+func appendUnique(list []string, max int, values ...string) []string {
+	for _, v := range values {
+		if blank(v) || len(list) >= max {
+			continue
+		}
+		dup := false
+		for _, have := range list {
+			if strings.EqualFold(have, v) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func joinList(items []string) string {
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"strings"
+
+	"github.com/laenix/vsentry/model"
+)
+
+// source 一条 stats 聚合Query及其结果到资产的映射
+type source struct {
+	query string
+	parse func(row map[string]string) *observation
+}
+
+const statsTail = ` min(_time) first_seen, max(_time) last_seen, count() events`
+
+// sources 资产发现使用的 OCSF 字段：
+// observer 为上报Log的主机 (带操作系统和数据源)，src/dst_endpoint 为通信两端的主机，
+// actor.user / target.user 为账号 (认证Event的 observer 即登录的主机)，service 为服务
+var sources = []source{
+	{
+		query: `observer.hostname:* | stats by (observer.hostname, observer.ip, observer.os.type, observer.os.name, observer.os.version, metadata.product)` + statsTail,
+		parse: func(row map[string]string) *observation {
+			return &observation{
+				kind:      model.AssetHost,
+				name:      hostName(row["observer.hostname"]),
+				ips:       []string{row["observer.ip"]},
+				os:        firstNonEmpty(row["observer.os.name"], row["observer.os.type"]),
+				osVersion: row["observer.os.version"],
+				sources:   []string{row["metadata.product"]},
+			}
+		},
+	},
+	endpointSource("src_endpoint"),
+	endpointSource("dst_endpoint"),
+	userSource("actor.user"),
+	userSource("target.user"),
+	{
+		query: `service.name:* | stats by (service.name, service.start_type, service.state, observer.hostname)` + statsTail,
+		parse: func(row map[string]string) *observation {
+			return &observation{
+				kind:      model.AssetService,
+				name:      strings.ToLower(row["service.name"]),
+				scope:     hostName(row["observer.hostname"]),
+				startType: row["service.start_type"],
+				state:     row["service.state"],
+			}
+		},
+	},
+}
+
+func endpointSource(prefix string) source {
+	return source{
+		query: prefix + `.hostname:* | stats by (` + prefix + `.hostname, ` + prefix + `.ip)` + statsTail,
+		parse: func(row map[string]string) *observation {
+			return &observation{
+				kind: model.AssetHost,
+				name: hostName(row[prefix+".hostname"]),
+				ips:  []string{row[prefix+".ip"]},
+			}
+		},
+	}
+}
+
+func userSource(prefix string) source {
+	return source{
+		query: prefix + `.name:* | stats by (` + prefix + `.name, ` + prefix + `.domain, ` + prefix + `.uid, observer.hostname, class_uid)` + statsTail,
+		parse: func(row map[string]string) *observation {
+			name, domain := UserName(row[prefix+".name"], row[prefix+".domain"])
+			o := &observation{kind: model.AssetUser, name: name, scope: domain, sid: row[prefix+".uid"]}
+			if row["class_uid"] == classAuthentication {
+				o.hosts = []string{hostName(row["observer.hostname"])}
+			}
+			return o
+		},
+	}
+}
+
+// hostName 主机名统一小写并去掉末尾的点
+func hostName(v string) string {
+	v = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(v)), ".")
+	if blank(v) || v == "localhost" {
+		return ""
+	}
+	return v
+}
+
+// UserName 拆分 DOMAIN\user 与 user@domain 两种写法，统一小写
+func UserName(name, domain string) (string, string) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if d, u, ok := strings.Cut(name, `\`); ok {
+		name, domain = u, firstNonEmpty(domain, d)
+	} else if u, d, ok := strings.Cut(name, "@"); ok && u != "" {
+		name, domain = u, firstNonEmpty(domain, d)
+	}
+	if blank(name) {
+		return "", ""
+	}
+	if blank(domain) {
+		domain = ""
+	}
+	return name, domain
+}
@@ -0,0 +1,199 @@
+// Package live 实时Event推送 (SSE / WebSocket)
+//
+// Event按递增 ID 保存在内存环形缓冲Medium，客户端断线重连时带上 Last-Event-ID 即可补发错过的Event；
+// ID 已超出缓冲 (或来自重启前的进程) 时先收到一条 reset，提示客户端重新拉取List。
+package live
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Event Type
+const (
+	IncidentCreated  = "incident.created"
+	IncidentUpdated  = "incident.updated"
+	AlertCreated     = "alert.created"
+	PlaybookStep     = "playbook.step"
+	PlaybookFinished = "playbook.finished"
+	ForensicProgress = "forensic.progress"
+	Reset            = "reset" // 无法补发，需要重新拉取
+)
+
+// Event 推送给客户端的Event
+type Event struct {
+	ID         uint64          `json:"id"`
+	Type       string          `json:"type"`
+	Time       time.Time       `json:"time"`
+	IncidentID uint            `json:"incident_id,omitempty"`
+	RuleID     uint            `json:"rule_id,omitempty"`
+	Severity   string          `json:"severity,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// Filter 订阅过滤条件，空集合表示不限
+type Filter struct {
+	Types       map[string]bool
+	Severities  map[string]bool
+	RuleIDs     map[uint]bool
+	IncidentIDs map[uint]bool
+}
+
+// ParseFilter 从逗号分隔的参数构造过滤条件
+func ParseFilter(types, severities, ruleIDs, incidentIDs string) Filter {
+	return Filter{
+		Types:       stringSet(types),
+		Severities:  stringSet(severities),
+		RuleIDs:     idSet(ruleIDs),
+		IncidentIDs: idSet(incidentIDs),
+	}
+}
+
+// Match Event是否满足过滤条件；reset 总是发送
+func (f Filter) Match(e Event) bool {
+	if e.Type == Reset {
+		return true
+	}
+	if len(f.Types) > 0 && !f.Types[e.Type] && !f.Types[strings.SplitN(e.Type, ".", 2)[0]] {
+		return false
+	}
+	if len(f.Severities) > 0 && !f.Severities[e.Severity] {
+		return false
+	}
+	if len(f.RuleIDs) > 0 && !f.RuleIDs[e.RuleID] {
+		return false
+	}
+	if len(f.IncidentIDs) > 0 && !f.IncidentIDs[e.IncidentID] {
+		return false
+	}
+	return true
+}
+
+func stringSet(csv string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range strings.Split(csv, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+func idSet(csv string) map[uint]bool {
+	set := make(map[uint]bool)
+	for _, item := range strings.Split(csv, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(item), 10, 64); err == nil {
+			set[uint(id)] = true
+		}
+	}
+	return set
+}
+
+// Subscription 一个客户端连接；客户端处理不过来时 C 被关闭，需带 Last-Event-ID 重连
+type Subscription struct {
+	C      chan Event
+	filter Filter
+	closed bool
+}
+
+type hub struct {
+	mu     sync.Mutex
+	seed   uint64 // 本进程第一个Event的 ID
+	next   uint64
+	buffer []Event
+	start  int // 环形缓冲Medium最早Event的位置
+	subs   map[*Subscription]bool
+}
+
+// 以启动Time为起点，重启后的 ID 总是大于之ago进程的 ID
+var h = &hub{
+	seed: uint64(time.Now().UnixMicro()),
+	subs: make(map[*Subscription]bool),
+}
+
+func init() {
+	h.next = h.seed
+}
+
+func bufferSize() int {
+	if n := viper.GetInt("live.buffer"); n > 0 {
+		return n
+	}
+	return 1000
+}
+
+// Publish 发布一条Event，data 在发布时序列化
+func Publish(typ string, incidentID, ruleID uint, severity string, data interface{}) {
+	raw, _ := json.Marshal(data)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := Event{ID: h.next, Type: typ, Time: time.Now().UTC(), IncidentID: incidentID, RuleID: ruleID, Severity: severity, Data: raw}
+	h.next++
+
+	if size := bufferSize(); len(h.buffer) < size {
+		h.buffer = append(h.buffer, e)
+	} else {
+		h.buffer[h.start] = e
+		h.start = (h.start + 1) % len(h.buffer)
+	}
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			// 慢客户端：断开，由客户端带 Last-Event-ID 重连补发
+			h.drop(s)
+		}
+	}
+}
+
+// Subscribe 订阅Event；lastID 不为 0 时先写入错过的Event (或一条 reset)
+func Subscribe(f Filter, lastID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var backlog []Event
+	if lastID != 0 {
+		oldest := h.next
+		if len(h.buffer) > 0 {
+			oldest = h.buffer[h.start].ID
+		}
+		if lastID < h.seed || lastID+1 < oldest {
+			backlog = append(backlog, Event{Type: Reset, Time: time.Now().UTC()})
+		}
+		for i := 0; i < len(h.buffer); i++ {
+			e := h.buffer[(h.start+i)%len(h.buffer)]
+			if e.ID > lastID && f.Match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	s := &Subscription{C: make(chan Event, len(backlog)+256), filter: f}
+	for _, e := range backlog {
+		s.C <- e
+	}
+	h.subs[s] = true
+	return s
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(s)
+}
+
+func (h *hub) drop(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	close(s.C)
+}
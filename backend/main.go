@@ -13,7 +13,13 @@ import (
 	"github.com/laenix/vsentry/config"
 	"github.com/laenix/vsentry/database"
 	"github.com/laenix/vsentry/ingest"
+	"github.com/laenix/vsentry/intel"
+	"github.com/laenix/vsentry/inventory"
+	"github.com/laenix/vsentry/middleware"
+	"github.com/laenix/vsentry/notify"
+	"github.com/laenix/vsentry/pkg/attack"
 	"github.com/laenix/vsentry/routers"
+	"github.com/laenix/vsentry/rulepack"
 	"github.com/laenix/vsentry/scheduler"
 	"github.com/spf13/viper"
 )
@@ -28,14 +34,33 @@ func main() {
 	// 3. Initialize本地High速缓存 (BadgerDB)
 	database.InitBadger()
 
+	// 可选：使用官方 enterprise-attack STIX bundle 替换内置 ATT&CK 数据集
+	if path := viper.GetString("attack.dataset"); path != "" {
+		if err := attack.LoadSTIX(path); err != nil {
+			log.Printf("Failed to load ATT&CK dataset %s, using bundled data: %v", path, err)
+		}
+	}
+
+	// 加载威胁情报 IOC 索引，ingest 分发时进行匹配
+	intel.Init()
+
+	// 通知渠道：重试Failed投递、发送到期摘要
+	notify.Init()
+
+	// 资产清单：定期从 VictoriaLogs 聚合主机、账号和服务
+	inventory.Init()
+
 	// 4. StartAsyncLog分发Schedule器 (消费者)
 	// 该协程负责根据 IngestID 分发Log并Manage VictoriaLogs 实例的生命周期
 	go ingest.StartDispatcher()
 
 	scheduler.InitScheduler()            // StartEngine
 	scheduler.GlobalEngine.ReloadRules() // 首次加载Rule
+	scheduler.InitBacktrace()            // 恢复未完成的回溯Task
+	rulepack.Init()                      // Rule包定时同步
 	// 5. Settings Gin Engine
 	r := gin.New()
+	r.Use(middleware.StreamTokenMiddleware()) // 访问Log不记录 /stream/* 的 ?token=
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	// 支持大FileUpload (100MB)
@@ -19,28 +19,72 @@ func AuthMiddleware() gin.HandlerFunc {
 			ctx.Abort()
 			return
 		}
-		tokenString = tokenString[7:]
-		token, Claims, err := ParseToken(tokenString)
-		if err != nil || !token.Valid {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401, "msg": "Token失效，请重新登录", "data": err,
-			})
-			ctx.Abort()
-			return
+		authenticate(ctx, tokenString[7:])
+	}
+}
+
+// streamTokenKey StreamTokenMiddleware 从 URL 取出的 JWT 在上下文Medium的键
+const streamTokenKey = "stream_token"
+
+// StreamTokenMiddleware 须注册在 gin.Logger 之ago：把 /stream/* 请求 URL Medium的 token 移到上下文，
+// 避免 JWT 被写入访问Log
+func StreamTokenMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		path := strings.TrimPrefix(ctx.Request.URL.Path, "/api")
+		if strings.HasPrefix(path, "/stream/") {
+			query := ctx.Request.URL.Query()
+			if token := query.Get("token"); token != "" {
+				ctx.Set(streamTokenKey, token)
+				query.Del("token")
+				ctx.Request.URL.RawQuery = query.Encode()
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// StreamAuthMiddleware 用于 SSE / WebSocket：浏览器的 EventSource 和 WebSocket 无法Settings请求头，
+// 允许通过 ?token= 传递 JWT (由 StreamTokenMiddleware 取出)
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tokenString := ctx.GetHeader("Authorization")
+		if strings.HasPrefix(tokenString, "Bearer") {
+			tokenString = tokenString[7:]
+		} else {
+			tokenString = ctx.GetString(streamTokenKey)
 		}
-		userId := Claims.UserId
-		DB := database.GetDB()
-		var user model.User
-		DB.Table("users").Where("id = ?", userId).Scan(&user)
-		if user.ID == 0 {
+		if tokenString == "" {
 			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401, "msg": "用户不存在",
+				"code": 401, "msg": "Token验证失败",
 			})
 			ctx.Abort()
 			return
 		}
-		ctx.Set("user", user)
-		ctx.Set("userid", user.ID)
-		ctx.Next()
+		authenticate(ctx, tokenString)
+	}
+}
+
+func authenticate(ctx *gin.Context, tokenString string) {
+	token, Claims, err := ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code": 401, "msg": "Token失效，请重新登录", "data": err,
+		})
+		ctx.Abort()
+		return
+	}
+	userId := Claims.UserId
+	DB := database.GetDB()
+	var user model.User
+	DB.Table("users").Where("id = ?", userId).Scan(&user)
+	if user.ID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code": 401, "msg": "用户不存在",
+		})
+		ctx.Abort()
+		return
 	}
+	ctx.Set("user", user)
+	ctx.Set("userid", user.ID)
+	ctx.Next()
 }
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// IncidentActivity Type
+const (
+	ActivityCreated  = "created"
+	ActivityStatus   = "status_changed"
+	ActivityAssignee = "assignee_changed"
+	ActivitySeverity = "severity_changed"
+	ActivityComment  = "comment"
+	ActivityPlaybook = "playbook_run"
+	ActivityAlerts   = "alerts_triaged"
+	ActivitySLA      = "sla_breached"
+	ActivityMerged   = "merged"
+	ActivitySplit    = "split"
+	ActivityLinked   = "linked"
+	ActivityUnlinked = "unlinked"
+	ActivityQuery    = "investigation_query"
+)
+
+// IncidentComment 分析人员在处置过程Medium留下的备注 (Markdown)，@username 提及的用户记录在 Mentions
+type IncidentComment struct {
+	gorm.Model
+	IncidentID uint                      `json:"incident_id" gorm:"index"`
+	AuthorID   uint                      `json:"author_id"`
+	Author     string                    `json:"author"`
+	Body       string                    `json:"body"`
+	Mentions   datatypes.JSONSlice[uint] `json:"mentions"`
+}
+
+// IncidentActivity Incident活动记录，只追加不修改，组成 Incident 的Timeline
+// ActorID 为 0 表示平台自动操作 (Rule、风险策略、Playbook触发等)
+type IncidentActivity struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	IncidentID uint      `json:"incident_id" gorm:"index"`
+	ActorID    uint      `json:"actor_id"`
+	Actor      string    `json:"actor"`
+	Type       string    `json:"type"`
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	// 关联对象：comment 为评论 ID，playbook_run 为Execute记录 ID，investigation_query 为调查Rule ID
+	RefID uint `json:"ref_id,omitempty"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// AnomalySettings 异常检测Rule配置（Rule.Type == "anomaly"）
+// Rule的 Query 为 LogSQL stats Query，如 `_time:1h | stats by (observer.hostname) count() as count`
+type AnomalySettings struct {
+	EntityField  string  `json:"entity_field"`  // stats by 的实体字段，空表示全局基线
+	ValueField   string  `json:"value_field"`   // stats 结果Medium的数值字段，Default count
+	Method       string  `json:"method"`        // zscore / percentile
+	Threshold    float64 `json:"threshold"`     // zscore: 标准差倍数 (Default 3)；percentile: 百分位 (Default 99)
+	Direction    string  `json:"direction"`     // up / down / both，Default up
+	LearningDays int     `json:"learning_days"` // 学习期days数，期间只积累基线不Alert，Default 7
+	MinSamples   int     `json:"min_samples"`   // every个 实体+时段 最少样本数，Default 3
+}
+
+// WithDefaults 补全未填写的配置
+func (s AnomalySettings) WithDefaults() AnomalySettings {
+	if s.ValueField == "" {
+		s.ValueField = "count"
+	}
+	if s.Method == "" {
+		s.Method = "zscore"
+	}
+	if s.Threshold <= 0 {
+		if s.Method == "percentile" {
+			s.Threshold = 99
+		} else {
+			s.Threshold = 3
+		}
+	}
+	if s.Direction == "" {
+		s.Direction = "up"
+	}
+	if s.LearningDays <= 0 {
+		s.LearningDays = 7
+	}
+	if s.MinSamples <= 0 {
+		s.MinSamples = 3
+	}
+	return s
+}
+
+// AnomalyBaseline 实体在某个 hour-of-week 时段的基线（保留最近的样本用于百分位计算）
+type AnomalyBaseline struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	RuleID    uint      `json:"rule_id" gorm:"uniqueIndex:idx_anomaly_baseline,priority:1"`
+	Entity    string    `json:"entity" gorm:"uniqueIndex:idx_anomaly_baseline,priority:2"`
+	Bucket    int       `json:"bucket" gorm:"uniqueIndex:idx_anomaly_baseline,priority:3"` // 0-167，周日 00 点为 0
+	Samples   int       `json:"samples"`
+	Mean      float64   `json:"mean"`
+	StdDev    float64   `json:"stddev"`
+	Values    string    `json:"values"` // 最近样本 JSON 数Group
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Asset Type
+const (
+	AssetHost    = "host"
+	AssetUser    = "user"
+	AssetService = "service"
+)
+
+// Asset 重要性
+const (
+	CriticalityLow      = "low"
+	CriticalityMedium   = "medium"
+	CriticalityHigh     = "high"
+	CriticalityCritical = "critical"
+)
+
+// Asset 资产清单：主机、账号和服务，由后台任务根据 VictoriaLogs Medium的 OCSF 字段自动发现，
+// 负责人、重要性、环境等属性由人工维护，自动发现不会覆盖
+type Asset struct {
+	gorm.Model
+	Kind string `json:"kind" gorm:"uniqueIndex:idx_asset"`
+	// 主机名 / 账号名 / 服务名，统一小写
+	Name string `json:"name" gorm:"uniqueIndex:idx_asset"`
+	// 账号为所属域，服务为所在主机，主机为空
+	Scope string `json:"scope" gorm:"uniqueIndex:idx_asset"`
+
+	// 主机
+	IPs       string `json:"ips"` // 逗号分隔
+	OS        string `json:"os"`
+	OSVersion string `json:"os_version"`
+	IngestID  uint   `json:"ingest_id"` // 最近上报该主机Log的 Ingest
+	Sources   string `json:"sources"`   // 上报的数据源 (metadata.product)，逗号分隔
+
+	// 账号
+	SID   string `json:"sid"`
+	Hosts string `json:"hosts"` // 登录过的主机，逗号分隔
+
+	// 服务
+	StartType string `json:"start_type"`
+	State     string `json:"state"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" gorm:"index"`
+	Events    int64     `json:"events"`
+	Manual    bool      `json:"manual"` // 人工添加，尚未在Log中出现过
+
+	// 人工维护的属性
+	Owner       string `json:"owner"`
+	Criticality string `json:"criticality" gorm:"index"`
+	Environment string `json:"environment"`
+	Notes       string `json:"notes"`
+}
+
+// AssetRef Alert关联的资产摘要
+type AssetRef struct {
+	ID          uint   `json:"id"`
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Scope       string `json:"scope,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Criticality string `json:"criticality,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
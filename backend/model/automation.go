@@ -25,11 +25,11 @@ type Playbook struct {
 // PlaybookExecution Execute历史
 type PlaybookExecution struct {
 	gorm.Model
-	PlaybookID       uint   `json:"playbook_id"`
-	Status           string `json:"status"`             // "running", "success", "failed"
-	TriggerContextID uint   `json:"trigger_context_id"` // 关联的 Incident ID
+	PlaybookID       uint   `json:"playbook_id" gorm:"index"`
+	Status           string `json:"status" gorm:"index"`             // "running", "success", "failed"
+	TriggerContextID uint   `json:"trigger_context_id" gorm:"index"` // 关联的 Incident ID
 
-	StartTime time.Time `json:"start_time"`
+	StartTime time.Time `json:"start_time" gorm:"index"`
 	EndTime   time.Time `json:"end_time"`
 	Duration  int64     `json:"duration_ms"` // 毫seconds
 
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 回溯TaskStatus机：queued -> running -> done/failed，running <-> paused，任意未结束Status均可 cancelled
+const (
+	BacktraceQueued    = "queued"
+	BacktraceRunning   = "running"
+	BacktracePaused    = "paused"
+	BacktraceDone      = "done"
+	BacktraceFailed    = "failed"
+	BacktraceCancelled = "cancelled"
+)
+
+// BacktraceJob 持久化的Rule回溯Task，按days推进并记录检查点，重启后可从 Cursor 处Continue
+type BacktraceJob struct {
+	gorm.Model
+	RuleID uint   `json:"rule_id" gorm:"index"`
+	Status string `json:"status" gorm:"index"`
+
+	// 回溯范围 [RangeStart, RangeEnd)，Cursor 为下一个待Execute的days（检查点）
+	RangeStart time.Time `json:"range_start"`
+	RangeEnd   time.Time `json:"range_end"`
+	Cursor     time.Time `json:"cursor"`
+
+	// 进度
+	DaysTotal   int `json:"days_total"`
+	DaysDone    int `json:"days_done"`
+	AlertsFound int `json:"alerts_found"`
+
+	Error      string     `json:"error"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// IsFinished 是否已处于终止Status
+func (j *BacktraceJob) IsFinished() bool {
+	return j.Status == BacktraceDone || j.Status == BacktraceFailed || j.Status == BacktraceCancelled
+}
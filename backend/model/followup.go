@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 后续Query命Medium (Rows >= MinRows) 时的动作
+const (
+	FollowUpEnrich   = "enrich"   // 只把结果附加到Alert
+	FollowUpRequire  = "require"  // 未命Medium时丢弃Alert (记录为已抑制)
+	FollowUpEscalate = "escalate" // 命Medium时提升Alert严重级别
+)
+
+// FollowUpQuery Rule的后续Query：every条NewAlert产生时，用Alert字段替换 ${field} 后在Alert Time附近Execute
+type FollowUpQuery struct {
+	Name     string `json:"name"`
+	Query    string `json:"query"`    // LogSQL，如 src_endpoint.ip:"${src_endpoint.ip}" AND activity_name:"Network Connect"
+	Before   string `json:"before"`   // Alert _time 之ago的Time范围，如 5m，Default 0
+	After    string `json:"after"`    // Alert _time 之后的Time范围，Default 10m
+	MinRows  int    `json:"min_rows"` // 至少命Medium的行数，Default 1
+	Action   string `json:"action"`   // enrich / require / escalate
+	Severity string `json:"severity"` // escalate 时提升到的级别
+}
+
+// FollowUpResult 一次后续Query的结果，附加在Alert上
+type FollowUpResult struct {
+	Name    string            `json:"name"`
+	Action  string            `json:"action"`
+	Query   string            `json:"query"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	Rows    int               `json:"rows"`
+	Matched bool              `json:"matched"`
+	Sample  []json.RawMessage `json:"sample,omitempty"` // 前几行结果
+	Error   string            `json:"error,omitempty"`
+}
+
+// PendingAlert 等待后续Query窗口结束的Alert，到期后由后台任务Execute后续Query再决定上报、丢弃或提升级别
+type PendingAlert struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	RuleID      uint      `json:"rule_id" gorm:"index"`
+	Content     string    `json:"content"`
+	Fingerprint string    `json:"fingerprint" gorm:"uniqueIndex"`
+	DueAt       time.Time `json:"due_at" gorm:"index"` // 所有后续Query窗口结束 + 写入延迟
+}
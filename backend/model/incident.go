@@ -3,37 +3,125 @@ package model
 import (
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // model/incident.go
 type Incident struct {
 	gorm.Model
-	RuleID     uint      `json:"rule_id"`
+	RuleID     uint      `json:"rule_id" gorm:"index"`
 	Name       string    `json:"name"`
-	Severity   string    `json:"severity"`
-	Status     string    `json:"status"` // New, Acknowledged, Resolved
+	Severity   string    `json:"severity" gorm:"index"`
+	Status     string    `json:"status" gorm:"index"` // New, Acknowledged, Resolved
 	AlertCount int       `json:"alert_count"`
 	FirstSeen  time.Time `json:"first_seen"`
-	LastSeen   time.Time `json:"last_seen"`
+	LastSeen   time.Time `json:"last_seen" gorm:"index"`
+
+	// 来源：空为检测Rule产生，platform 为平台自身产生（如Rule持续ExecuteFailed）
+	Source string `json:"source" gorm:"index"`
+
+	// 从Rule继承的 MITRE ATT&CK 映射（逗号分隔，如 "TA0002" / "T1059,T1059.001"）
+	Tactics    string `json:"tactics"`
+	Techniques string `json:"techniques"`
 
 	// 处置字段
-	Assignee              uint   `json:"assignee"`
+	Assignee              uint   `json:"assignee" gorm:"index"`
 	ClosingClassification string `json:"closing_classification"`
 	ClosingComment        string `json:"closing_comment"`
 
+	// SLA：截止Time按创建时的Severity策略计算，Breached 在违约升级后置位 (每项只升级一次)
+	AckDueAt        *time.Time `json:"ack_due_at"`
+	ResolveDueAt    *time.Time `json:"resolve_due_at"`
+	AcknowledgedAt  *time.Time `json:"acknowledged_at"`
+	ResolvedAt      *time.Time `json:"resolved_at"`
+	AckBreached     bool       `json:"ack_breached"`
+	ResolveBreached bool       `json:"resolve_breached"`
+
+	// 合并后的墓碑记录指向保留的 Incident
+	MergedInto uint `json:"merged_into,omitempty" gorm:"index"`
+
 	// 关联的Rule（用于GetRuleType）
 	Rule Rule `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
 
 	// 核心：一对多关联。ago端在拿到 Incident Detail时，可以直接拿到这个数Group
 	Alerts []Alert `json:"alerts" gorm:"foreignKey:IncidentID"`
+
+	// 处置Timeline (Status/Assignee/Severity变更、评论、Playbook运行) 和评论，仅Detail接口加载
+	Timeline []IncidentActivity `json:"timeline,omitempty" gorm:"foreignKey:IncidentID"`
+	Comments []IncidentComment  `json:"comments,omitempty" gorm:"foreignKey:IncidentID"`
+	Links    []IncidentLinkRef  `json:"links,omitempty" gorm:"-"`
+
+	// 从Alert提取的 Observable，仅Detail接口加载
+	Observables []Observable `json:"observables,omitempty" gorm:"foreignKey:IncidentID"`
+}
+
+// IncidentLink Type
+const (
+	LinkDuplicateOf = "duplicate_of"
+	LinkRelatedTo   = "related_to"
+	LinkCausedBy    = "caused_by"
+)
+
+// IncidentLink Incident 之间的有向关联：Source <Type> Target (如 A duplicate_of B)
+type IncidentLink struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	SourceID  uint      `json:"source_id" gorm:"uniqueIndex:idx_incident_link"`
+	TargetID  uint      `json:"target_id" gorm:"uniqueIndex:idx_incident_link;index"`
+	Type      string    `json:"type" gorm:"uniqueIndex:idx_incident_link"`
+	AuthorID  uint      `json:"author_id"`
+	Comment   string    `json:"comment"`
+}
+
+// IncidentLinkRef Detail中展示的关联，Direction 为 outgoing (本 Incident 为 Source) 或 incoming
+type IncidentLinkRef struct {
+	LinkID     uint   `json:"link_id"`
+	Type       string `json:"type"`
+	Direction  string `json:"direction"`
+	IncidentID uint   `json:"incident_id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Severity   string `json:"severity"`
+	Comment    string `json:"comment,omitempty"`
 }
 
 // model/alert.go
 type Alert struct {
 	gorm.Model
-	IncidentID  uint   `json:"incident_id"` // 外键
-	RuleID      uint   `json:"rule_id"`
+	IncidentID  uint   `json:"incident_id" gorm:"index"` // 外键
+	RuleID      uint   `json:"rule_id" gorm:"index"`
 	Content     string `json:"content"` // Storage VictoriaLogs 搜出的原始 JSON Data
 	Fingerprint string `gorm:"uniqueIndex" json:"fingerprint"`
+
+	// 威胁情报命Medium时关联的 IOC
+	IndicatorID uint `json:"indicator_id,omitempty" gorm:"index"`
+
+	// 后续Query结果 ([]FollowUpResult)，Severity 为后续Query提升后的级别，为空时沿用Rule级别
+	FollowUp datatypes.JSON `json:"follow_up,omitempty"`
+	Severity string         `json:"severity,omitempty"`
+
+	// 命Medium的资产清单条目 ([]AssetRef)
+	Assets datatypes.JSON `json:"assets,omitempty"`
+
+	// 维护窗口内产生的Alert只记录不上报
+	Suppressed    bool `json:"suppressed" gorm:"index"`
+	MaintenanceID uint `json:"maintenance_id,omitempty"`
+
+	// 研判Status：new / triaged / false_positive / true_positive / closed
+	Status         string     `json:"status" gorm:"index;default:new"`
+	Assignee       uint       `json:"assignee" gorm:"index"`
+	Verdict        string     `json:"verdict,omitempty"` // false_positive / true_positive
+	ClosingComment string     `json:"closing_comment,omitempty"`
+	TriagedAt      *time.Time `json:"triaged_at,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
 }
+
+// Alert 研判Status
+const (
+	AlertNew           = "new"
+	AlertTriaged       = "triaged"
+	AlertFalsePositive = "false_positive"
+	AlertTruePositive  = "true_positive"
+	AlertClosed        = "closed"
+)
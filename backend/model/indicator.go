@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Indicator Type
+const (
+	IndicatorIP     = "ip"
+	IndicatorCIDR   = "cidr"
+	IndicatorDomain = "domain"
+	IndicatorURL    = "url"
+	IndicatorMD5    = "md5"
+	IndicatorSHA1   = "sha1"
+	IndicatorSHA256 = "sha256"
+)
+
+// Indicator 威胁情报 IOC，(type, value) 唯一，重复导入时Update来源/置信度/过期Time
+type Indicator struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Type        string     `json:"type" gorm:"uniqueIndex:idx_indicator_value,priority:1"`
+	Value       string     `json:"value" gorm:"uniqueIndex:idx_indicator_value,priority:2"`
+	Source      string     `json:"source" gorm:"index"`
+	Confidence  int        `json:"confidence"` // 0-100
+	Severity    string     `json:"severity"`
+	Description string     `json:"description"`
+	FeedID      uint       `json:"feed_id" gorm:"index"` // 0 表示手动Add
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"index"`
+	HitCount    int        `json:"hit_count"`
+	LastHitAt   *time.Time `json:"last_hit_at"`
+}
+
+// IsExpired 是否已过期
+func (i *Indicator) IsExpired(now time.Time) bool {
+	return i.ExpiresAt != nil && !i.ExpiresAt.After(now)
+}
+
+// IndicatorFeed 情报源：本地File或 URL，可定时刷New
+type IndicatorFeed struct {
+	gorm.Model
+	Name           string     `json:"name"`
+	Location       string     `json:"location"`     // 本地Path或 http(s) URL
+	Format         string     `json:"format"`       // csv / list / misp / stix
+	DefaultType    string     `json:"default_type"` // list/csv 无法识别Type时使用
+	Source         string     `json:"source"`
+	Confidence     int        `json:"confidence"`
+	TTLDays        int        `json:"ttl_days"`        // 数据本身没有过期Time时的有效期，0 表示永不过期
+	RefreshMinutes int        `json:"refresh_minutes"` // 0 表示只手动同步
+	Enabled        bool       `json:"enabled"`
+	LastSyncAt     *time.Time `json:"last_sync_at"`
+	LastCount      int        `json:"last_count"`
+	LastError      string     `json:"last_error"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 维护窗口生效期间对Rule的处理方式
+const (
+	MaintenanceSkip     = "skip"     // 不ExecuteRule (条件型窗口为丢弃命Medium的Event)
+	MaintenanceSuppress = "suppress" // 照常Execute，Alert记录为已抑制，不产生 Incident 也不触发 Playbook
+)
+
+// MaintenanceWindow 维护窗口：一次性 (StartsAt~EndsAt) 或周期性 (Schedule + DurationMinutes)
+// 作用范围为 RuleIDs / Tags / Condition 的交集，均为空时对所有Rule生效
+type MaintenanceWindow struct {
+	gorm.Model
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Enabled         bool       `json:"enabled"`
+	Action          string     `json:"action"`           // skip / suppress
+	TimeZone        string     `json:"time_zone"`        // IANA 时区，如 Asia/Shanghai，为空时使用 UTC
+	Schedule        string     `json:"schedule"`         // 周期窗口的开始Time，5 段 cron，如 "0 22 * * 5"
+	DurationMinutes int        `json:"duration_minutes"` // 周期窗口的持续Time
+	StartsAt        *time.Time `json:"starts_at"`        // 一次性窗口
+	EndsAt          *time.Time `json:"ends_at"`
+	RuleIDs         string     `json:"rule_ids"`  // 逗号分隔的Rule ID
+	Tags            string     `json:"tags"`      // 逗号分隔的Rule标签，命Medium任一即可
+	Condition       string     `json:"condition"` // expr Expression，按Event字段匹配，如 host.name == "db-01"
+	AuthorID        uint       `json:"author_id"`
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 通知渠道Type
+const (
+	ChannelEmail      = "email"
+	ChannelWebhook    = "webhook"
+	ChannelSlack      = "slack"
+	ChannelTeams      = "teams"
+	ChannelMattermost = "mattermost"
+	ChannelSyslog     = "syslog"
+)
+
+// 通知Event
+const (
+	NotifyIncidentCreated   = "incident_created"
+	NotifyIncidentEscalated = "incident_escalated"
+	NotifyIncidentResolved  = "incident_resolved"
+	NotifySLABreached       = "sla_breached"
+	NotifyDigest            = "digest"
+	NotifyTest              = "test"
+)
+
+// 投递Status
+const (
+	DeliveryQueued     = "queued"     // 等待汇总到摘要
+	DeliveryPending    = "pending"    // 待发送或等待重试
+	DeliverySending    = "sending"    // 已被某个发送方领取，next_attempt_at 为租约到期Time
+	DeliverySent       = "sent"       // 已发送
+	DeliveryFailed     = "failed"     // 重试次数用尽
+	DeliveryDigested   = "digested"   // 已合并进摘要 (DigestID)
+	DeliverySuppressed = "suppressed" // 去重窗口内重复，未发送
+)
+
+// NotifyChannel 通知渠道，凭据只保存在服务端，List接口不Return密码
+type NotifyChannel struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name" gorm:"uniqueIndex"`
+	Type      string    `json:"type"` // email / webhook / slack / teams / mattermost / syslog
+	Enabled   bool      `json:"enabled"`
+
+	// webhook / slack / teams / mattermost
+	URL     string            `json:"url"`
+	Method  string            `json:"method"` // webhook，Default POST
+	Headers datatypes.JSONMap `json:"headers"`
+	// webhook 请求体的 Go 模板 (text/template)，为空时发送Default JSON
+	Template string `json:"template"`
+
+	// email：SMTP；syslog：Host:Port 为接收端
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`      // 逗号分隔的收件人
+	Network  string `json:"network"` // syslog：udp / tcp
+
+	HasPassword bool `json:"has_password" gorm:"-"`
+}
+
+// NotifyPolicy 通知路由策略：Event/Severity/Rule/Rule标签都匹配时发送到 ChannelIDs，空条件表示不限
+type NotifyPolicy struct {
+	ID         uint                      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+	Name       string                    `json:"name"`
+	Enabled    bool                      `json:"enabled"`
+	Events     string                    `json:"events"`     // 逗号分隔，如 incident_created,sla_breached
+	Severities string                    `json:"severities"` // 逗号分隔，如 critical,high
+	RuleIDs    datatypes.JSONSlice[uint] `json:"rule_ids"`
+	Tags       string                    `json:"tags"` // 逗号分隔的Rule标签，任一匹配即可
+	ChannelIDs datatypes.JSONSlice[uint] `json:"channel_ids"`
+	// 同一 Incident 同一Event在窗口内只通知一次，0 表示不去重
+	DedupMinutes int `json:"dedup_minutes"`
+	// 大于 0 时不立即发送，每隔该分钟数汇总为一条摘要
+	DigestMinutes int `json:"digest_minutes"`
+}
+
+// NotifyDelivery 一次通知投递及其重试记录
+type NotifyDelivery struct {
+	ID            uint                      `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time                 `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+	ChannelID     uint                      `json:"channel_id" gorm:"index"`
+	PolicyID      uint                      `json:"policy_id"`
+	IncidentID    uint                      `json:"incident_id" gorm:"index"`
+	IncidentIDs   datatypes.JSONSlice[uint] `json:"incident_ids,omitempty"` // 摘要包含的 Incident
+	Event         string                    `json:"event"`
+	DedupKey      string                    `json:"-" gorm:"index"`
+	Status        string                    `json:"status" gorm:"index"`
+	Title         string                    `json:"title"`
+	Message       datatypes.JSON            `json:"message"` // 渲染前的通知内容，重试时复用
+	Attempts      int                       `json:"attempts"`
+	NextAttemptAt *time.Time                `json:"next_attempt_at"`
+	LastError     string                    `json:"last_error"`
+	SentAt        *time.Time                `json:"sent_at"`
+	DigestID      uint                      `json:"digest_id,omitempty"`
+}
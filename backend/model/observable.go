@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Observable Type，Hash 沿用 Indicator 的 md5 / sha1 / sha256
+const (
+	ObservableIP      = "ip"
+	ObservableDomain  = "domain"
+	ObservableURL     = "url"
+	ObservableUser    = "user"
+	ObservableHost    = "host"
+	ObservableCommand = "command"
+)
+
+// Observable Tag
+const (
+	ObservableMalicious = "malicious"
+	ObservableBenign    = "benign"
+)
+
+// Observable 从Alert内容Medium提取的 IP / 用户 / 主机 / Hash / 域名等，按 Incident 去重
+// Tag 按 (type, value) 全局生效：标记任一条即Update所有 Incident Medium的同一 Observable
+type Observable struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	IncidentID uint      `json:"incident_id" gorm:"uniqueIndex:idx_observable,priority:1"`
+	Type       string    `json:"type" gorm:"uniqueIndex:idx_observable,priority:2;index:idx_observable_value,priority:1"`
+	Value      string    `json:"value" gorm:"uniqueIndex:idx_observable,priority:3;index:idx_observable_value,priority:2"`
+	Fields     string    `json:"fields"` // 出现过的字段Path，逗号分隔，raw_data 表示正则扫描得到
+	Count      int       `json:"count"`  // 出现该 Observable 的Alert数
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Tag        string    `json:"tag" gorm:"index"` // malicious / benign / 空
+	TagNote    string    `json:"tag_note,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// 报告模板格式，pdf 由 md 模板排版生成
+const (
+	ReportMarkdown = "md"
+	ReportHTML     = "html"
+	ReportPDF      = "pdf"
+)
+
+// ReportTemplate Incident 报告的 Go 模板 (md 使用 text/template，html 使用 html/template)
+// 每种格式最多一个 IsDefault，都未设置时使用内置模板
+type ReportTemplate struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name" gorm:"uniqueIndex"`
+	Description string    `json:"description"`
+	Format      string    `json:"format"` // md / html
+	Body        string    `json:"body"`
+	IsDefault   bool      `json:"is_default"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// 风险实体Type
+const (
+	RiskEntityUser = "user"
+	RiskEntityHost = "host"
+	RiskEntityIP   = "ip"
+)
+
+// RiskSettings Rule的风险贡献配置：every条NewAlert为其Medium提取出的实体累加 Score 分
+type RiskSettings struct {
+	Score    float64 `json:"score"`    // 0 表示不参与风险累计
+	Entities string  `json:"entities"` // 逗号分隔的 type:field，如 user:actor.user.name,host:device.hostname，为空时使用Default字段
+	Only     bool    `json:"only"`     // 只累计风险，不单独产生 Incident (由风险阈值策略产生)
+}
+
+// RiskEntity 实体当ago风险分，Score 为 ScoredAt 时刻的衰减后分Value
+type RiskEntity struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Type          string     `json:"type" gorm:"uniqueIndex:idx_risk_entity"`
+	Value         string     `json:"value" gorm:"uniqueIndex:idx_risk_entity"`
+	Score         float64    `json:"score"`
+	ScoredAt      time.Time  `json:"scored_at"`
+	LastSeen      time.Time  `json:"last_seen"`
+	Contributions int        `json:"contributions"`
+	IncidentID    uint       `json:"incident_id"` // 最近一次由风险阈值产生的 Incident
+	ThresholdAt   *time.Time `json:"threshold_at"`
+}
+
+// RiskEvent 一次风险贡献 (某条Alert为某个实体累加的分Value)
+type RiskEvent struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index:idx_risk_event,priority:3"`
+	EntityType string    `json:"entity_type" gorm:"index:idx_risk_event,priority:1"`
+	Entity     string    `json:"entity" gorm:"index:idx_risk_event,priority:2"`
+	RuleID     uint      `json:"rule_id"`
+	RuleName   string    `json:"rule_name"`
+	AlertID    uint      `json:"alert_id" gorm:"index"`
+	Score      float64   `json:"score"`
+}
+
+// RiskPolicy 风险阈值策略：实体在窗口内累计的风险分达到 Threshold 时产生 Incident
+type RiskPolicy struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `json:"name"`
+	EntityType  string    `json:"entity_type"` // 为空时适用于所有实体Type
+	Threshold   float64   `json:"threshold"`
+	WindowHours int       `json:"window_hours"`
+	Severity    string    `json:"severity"`
+	Enabled     bool      `json:"enabled"`
+}
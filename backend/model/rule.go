@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -19,13 +20,37 @@ type Rule struct {
 	AuthorID    uint   `json:"author_id"`
 	Source      string `json:"source"`
 
-	// RuleType: alert(报警Rule) / forensic(ForensicsRule) / investigation(InvestigationRule)
+	// RuleType: alert(报警Rule) / anomaly(异常检测Rule) / stream(实时Rule) / forensic(ForensicsRule) / investigation(InvestigationRule)
 	Type string `json:"type" gorm:"default:alert"`
 
 	// 回溯配置（仅报警Rule使用）
 	EnableBacktrace bool   `json:"enable_backtrace"`
 	BacktraceCron   string `json:"backtrace_cron"`
 	BacktraceStart  string `json:"backtrace_start"`
+
+	// 异常检测配置（仅异常Rule使用）
+	Anomaly AnomalySettings `json:"anomaly" gorm:"embedded;embeddedPrefix:anomaly_"`
+
+	// 风险贡献配置，every条NewAlert为提取出的实体累加风险分
+	Risk RiskSettings `json:"risk" gorm:"embedded;embeddedPrefix:risk_"`
+
+	// 后续Query（报警/实时Rule），every条NewAlert自动Execute
+	FollowUps datatypes.JSONSlice[FollowUpQuery] `json:"follow_ups"`
+
+	// 测试用例：样例Event及期望结果，POST /rules/:id/tests/run 运行
+	Tests datatypes.JSONSlice[RuleTest] `json:"tests"`
+
+	// 标签（持久化在 RuleTag 表），attack.* ago缀的为 MITRE ATT&CK 映射
+	Tags []string `json:"tags" gorm:"-"`
+
+	// Rule包受管Rule：PackID 为 0 表示手动Create
+	PackID     uint   `json:"pack_id" gorm:"index"`
+	ManagedKey string `json:"managed_key" gorm:"index"` // Rule包内的 metadata.name
+	PackHash   string `json:"-"`                        // 上次同步的 spec 哈希
+	Drift      bool   `json:"drift"`                    // 受管Rule被手动修改过
+
+	// 因连续Execute失败被自动停用，人工启用后清除；Rule包同步不会重新启用
+	AutoDisabled bool `json:"auto_disabled"`
 }
 
 // RuleResponse 用于 API Return，包含正确的 id 字段
@@ -43,6 +68,18 @@ type RuleResponse struct {
 	AuthorID    uint      `json:"author_id"`
 	Source      string    `json:"source"`
 	Type        string    `json:"type"`
+	Tags        []string  `json:"tags"`
+
+	Anomaly   AnomalySettings `json:"anomaly"`
+	Risk      RiskSettings    `json:"risk"`
+	FollowUps []FollowUpQuery `json:"follow_ups"`
+	Tests     []RuleTest      `json:"tests"`
+
+	PackID     uint   `json:"pack_id"`
+	ManagedKey string `json:"managed_key"`
+	Drift      bool   `json:"drift"`
+
+	AutoDisabled bool `json:"auto_disabled"`
 }
 
 // ToResponse 将 Rule Convert为 RuleResponse
@@ -61,13 +98,23 @@ func (r *Rule) ToResponse() RuleResponse {
 		AuthorID:    r.AuthorID,
 		Source:      r.Source,
 		Type:        r.Type,
+		Tags:        r.Tags,
+		Anomaly:     r.Anomaly,
+		Risk:        r.Risk,
+		FollowUps:   r.FollowUps,
+		Tests:       r.Tests,
+		PackID:      r.PackID,
+		ManagedKey:  r.ManagedKey,
+		Drift:       r.Drift,
+
+		AutoDisabled: r.AutoDisabled,
 	}
 }
 
 type RuleTag struct {
 	gorm.Model
-	RuleID uint
-	Tag    string `json:"tag"`
+	RuleID uint   `gorm:"index"`
+	Tag    string `json:"tag" gorm:"index"`
 }
 
 type RuleAutomation struct {
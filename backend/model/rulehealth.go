@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// RuleExecution every次RuleExecute的记录（用于健康度统计）
+type RuleExecution struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	RuleID     uint      `json:"rule_id" gorm:"index:idx_rule_exec,priority:1"`
+	Kind       string    `json:"kind"` // scheduled / backtrace
+	StartedAt  time.Time `json:"started_at" gorm:"index:idx_rule_exec,priority:2"`
+	DurationMs int64     `json:"duration_ms"`
+	Rows       int       `json:"rows"`       // VictoriaLogs Return的行数
+	NewAlerts  int       `json:"new_alerts"` // 本次New增Alert数
+	Error      string    `json:"error"`
+}
+
+// RuleHealth Rule健康度汇总
+type RuleHealth struct {
+	RuleID              uint       `json:"rule_id"`
+	RuleName            string     `json:"rule_name"`
+	Enabled             bool       `json:"enabled"`
+	Status              string     `json:"status"` // healthy / failing / never_run
+	LastRun             *time.Time `json:"last_run"`
+	LastSuccess         *time.Time `json:"last_success"`
+	LastError           string     `json:"last_error"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Executions          int        `json:"executions"` // 统计窗口内的Execute次数
+	Failures            int        `json:"failures"`
+	P95DurationMs       int64      `json:"p95_duration_ms"`
+	AvgRows             float64    `json:"avg_rows"`
+}
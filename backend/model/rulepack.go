@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 规则包受管规则的编辑策略
+const (
+	RulePackBlock = "block" // 禁止在界面/API 修改受管规则
+	RulePackFlag  = "flag"  // 允许修改，但标记为 drift，下次同步时恢复为规则包内容
+)
+
+// RulePack 规则包：本地目录或本地 git 仓库Medium的 YAML 规则
+type RulePack struct {
+	gorm.Model
+	Name        string     `json:"name" gorm:"uniqueIndex"`
+	Path        string     `json:"path"`         // 本地目录或 git 仓库Path
+	Ref         string     `json:"ref"`          // git 引用 (分支/tag/commit)，为空时读取工作区
+	Subdir      string     `json:"subdir"`       // 仓库内的子目录
+	Mode        string     `json:"mode"`         // block / flag
+	SyncMinutes int        `json:"sync_minutes"` // 0 表示只手动同步
+	Enabled     bool       `json:"enabled"`
+	LastSyncAt  *time.Time `json:"last_sync_at"`
+	Revision    string     `json:"revision"` // 上次同步的 git commit
+	LastError   string     `json:"last_error"`
+	LastResult  string     `json:"last_result"` // 上次同步结果 JSON
+}
@@ -0,0 +1,38 @@
+package model
+
+import "encoding/json"
+
+// RuleTest 期望结果
+const (
+	RuleTestMatch   = "match"
+	RuleTestNoMatch = "no_match"
+)
+
+// RuleTest Rule测试用例：一条样例 OCSF Event及期望是否被Rule命Medium
+type RuleTest struct {
+	Name   string          `json:"name"`
+	Expect string          `json:"expect"` // match / no_match
+	Event  json.RawMessage `json:"event"`
+}
+
+// RuleTestCaseResult 单个用例的运行结果
+type RuleTestCaseResult struct {
+	Name   string `json:"name"`
+	Expect string `json:"expect"`
+	Rows   int    `json:"rows"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RuleTestReport 一条Rule全部用例的运行结果
+type RuleTestReport struct {
+	RuleID     uint                 `json:"rule_id"`
+	RuleName   string               `json:"rule_name"`
+	RunID      string               `json:"run_id"`
+	Passed     bool                 `json:"passed"`
+	Total      int                  `json:"total"`
+	Failed     int                  `json:"failed"`
+	DurationMs int64                `json:"duration_ms"`
+	Error      string               `json:"error,omitempty"`
+	Cases      []RuleTestCaseResult `json:"cases"`
+}
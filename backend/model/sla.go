@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// SLAPolicy 按Severity的响应时限，Incident 创建时据此计算认领/Resolve截止Time
+type SLAPolicy struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Name           string    `json:"name"`
+	Severity       string    `json:"severity" gorm:"uniqueIndex"`
+	AckMinutes     int       `json:"ack_minutes"`     // 0 表示不限制
+	ResolveMinutes int       `json:"resolve_minutes"` // 0 表示不限制
+	Enabled        bool      `json:"enabled"`
+
+	// 违约升级：改派、提升级别、运行Playbook、通知 Webhook，空Value表示不执行该项
+	EscalateAssignee   uint   `json:"escalate_assignee"`
+	EscalateSeverity   string `json:"escalate_severity"`
+	EscalatePlaybookID uint   `json:"escalate_playbook_id"`
+	EscalateWebhook    string `json:"escalate_webhook"`
+
+	// 超过 N 小时没有New Alert和处置活动的 Incident 自动关闭，0 表示不自动关闭
+	AutoCloseHours int `json:"auto_close_hours"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// 误报抑制建议Status
+const (
+	ProposalOpen      = "proposed"
+	ProposalAccepted  = "accepted"
+	ProposalDismissed = "dismissed"
+)
+
+// 接受建议的方式
+const (
+	ProposalSuppress = "suppression" // Create条件型 suppress 维护窗口
+	ProposalQuery    = "query"       // 修改Rule Query排除该值
+)
+
+// SuppressionProposal 根据已关闭的误报Alert得出的抑制建议：某个字段值在该Rule的误报Medium占比很高
+type SuppressionProposal struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	RuleID    uint      `json:"rule_id" gorm:"uniqueIndex:idx_proposal"`
+	Field     string    `json:"field" gorm:"uniqueIndex:idx_proposal"`
+	Value     string    `json:"value" gorm:"uniqueIndex:idx_proposal"`
+
+	// 维护窗口条件 (expr) 和修改后的Rule Query，Rule类型不支持修改 Query 时为空
+	Condition    string `json:"condition"`
+	RefinedQuery string `json:"refined_query,omitempty"`
+
+	// 分析结果：误报Medium命Medium数/误报总数，以及历史上会被一并隐藏的真阳性和未定性Alert
+	FalsePositives int        `json:"false_positives"`
+	FalseTotal     int        `json:"false_total"`
+	Share          float64    `json:"share"`
+	TruePositives  int        `json:"true_positives"`
+	Undetermined   int        `json:"undetermined"`
+	AnalyzedAt     *time.Time `json:"analyzed_at"`
+
+	Status        string     `json:"status" gorm:"index"`
+	AcceptedAs    string     `json:"accepted_as,omitempty"` // suppression / query
+	MaintenanceID uint       `json:"maintenance_id,omitempty"`
+	DecidedBy     uint       `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+}
@@ -0,0 +1,438 @@
+// Package notify 通知渠道与路由策略
+//
+// Incident Event (创建、升级、SLA 违约、Resolve) 按策略匹配后为每个渠道生成一条投递记录：
+// 去重窗口内重复的记为 suppressed；配置了摘要的先 queued，由后台定时合并为一条摘要；
+// 其余立即发送，Failed 后按指数退避重试，直到 notify.max_attempts。
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// IncidentSummary 通知内容Medium的 Incident 摘要
+type IncidentSummary struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Severity   string    `json:"severity"`
+	Status     string    `json:"status"`
+	RuleID     uint      `json:"rule_id"`
+	Source     string    `json:"source,omitempty"`
+	AlertCount int       `json:"alert_count"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Event      string    `json:"event"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// Message 渠道无关的通知内容，保存在投递记录Medium用于重试
+type Message struct {
+	Event     string            `json:"event"`
+	Title     string            `json:"title"`
+	Text      string            `json:"text"`
+	Severity  string            `json:"severity"`
+	Incident  *IncidentSummary  `json:"incident,omitempty"`
+	Incidents []IncidentSummary `json:"incidents,omitempty"` // 摘要
+	Time      time.Time         `json:"time"`
+}
+
+// Events 支持的通知Event
+var Events = map[string]bool{
+	model.NotifyIncidentCreated:   true,
+	model.NotifyIncidentEscalated: true,
+	model.NotifyIncidentResolved:  true,
+	model.NotifySLABreached:       true,
+}
+
+func checkInterval() time.Duration {
+	if d := viper.GetDuration("notify.check_interval"); d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+func maxAttempts() int {
+	if n := viper.GetInt("notify.max_attempts"); n > 0 {
+		return n
+	}
+	return 5
+}
+
+// retryDelay 第 n 次Failed后的等待Time：1m、2m、4m ... 最长 1h
+func retryDelay(attempts int) time.Duration {
+	d := time.Minute << uint(attempts-1)
+	if attempts > 7 || d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
+
+// sendLease 领取一条投递后的租约，发送方中途退出时租约到期由 Process 重新投递
+const sendLease = 5 * time.Minute
+
+// Init Start后台投递：重试到期的记录、发送到期的摘要
+func Init() {
+	go func() {
+		ticker := time.NewTicker(checkInterval())
+		defer ticker.Stop()
+		for now := range ticker.C {
+			Process(now.UTC())
+		}
+	}()
+}
+
+// Process 发送到期的摘要和待重试的投递
+func Process(now time.Time) {
+	flushDigests(now)
+
+	db := database.GetDB()
+	db.Model(&model.NotifyDelivery{}).Where("status = ? AND next_attempt_at <= ?", model.DeliverySending, now).
+		Update("status", model.DeliveryPending)
+	var due []model.NotifyDelivery
+	db.Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", model.DeliveryPending, now).
+		Order("id asc").Limit(100).Find(&due)
+	for i := range due {
+		deliver(&due[i])
+	}
+}
+
+// Incident 按策略为 Incident Event生成通知，detail 为补充说明 (如升级原因)
+func Incident(event string, incident model.Incident, detail string) {
+	db := database.GetDB()
+	var policies []model.NotifyPolicy
+	db.Where("enabled = ?", true).Order("id asc").Find(&policies)
+	if len(policies) == 0 {
+		return
+	}
+	tags := database.LoadRuleTags(db, incident.RuleID)[incident.RuleID]
+
+	msg := incidentMessage(event, incident, detail)
+	payload, _ := json.Marshal(msg)
+	now := time.Now().UTC()
+	seen := make(map[uint]bool) // 同一次Event每个渠道只投递一次
+	var immediate []*model.NotifyDelivery
+	for _, p := range policies {
+		if !Matches(p, event, incident, tags) {
+			continue
+		}
+		for _, channelID := range p.ChannelIDs {
+			if seen[channelID] {
+				continue
+			}
+			seen[channelID] = true
+			d := &model.NotifyDelivery{
+				ChannelID:  channelID,
+				PolicyID:   p.ID,
+				IncidentID: incident.ID,
+				Event:      event,
+				DedupKey:   fmt.Sprintf("%d:%d:%s", channelID, incident.ID, event),
+				Title:      msg.Title,
+				Message:    payload,
+				Status:     model.DeliveryPending,
+			}
+			switch {
+			case p.DedupMinutes > 0 && duplicated(db, d.DedupKey, now.Add(-time.Duration(p.DedupMinutes)*time.Minute)):
+				d.Status = model.DeliverySuppressed
+			case p.DigestMinutes > 0:
+				d.Status = model.DeliveryQueued
+			}
+			if err := db.Create(d).Error; err != nil {
+				log.Printf("[Notify] Failed to record delivery: %v", err)
+				continue
+			}
+			if d.Status == model.DeliveryPending {
+				immediate = append(immediate, d)
+			}
+		}
+	}
+	for _, d := range immediate {
+		deliver(d)
+	}
+}
+
+// Matches 策略是否匹配该 Incident Event，空条件表示不限
+func Matches(p model.NotifyPolicy, event string, incident model.Incident, ruleTags []string) bool {
+	if !inList(p.Events, event) {
+		return false
+	}
+	if p.Severities != "" && !inList(p.Severities, incident.Severity) {
+		return false
+	}
+	if len(p.RuleIDs) > 0 {
+		found := false
+		for _, id := range p.RuleIDs {
+			if id == incident.RuleID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if p.Tags != "" {
+		for _, tag := range ruleTags {
+			if inList(p.Tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func inList(csv, v string) bool {
+	for _, item := range strings.Split(csv, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicated 去重窗口内是否已有同一渠道、Incident、Event的通知 (已发送、待发送或已入摘要)
+func duplicated(db *gorm.DB, key string, since time.Time) bool {
+	var count int64
+	db.Model(&model.NotifyDelivery{}).
+		Where("dedup_key = ? AND created_at >= ? AND status IN ?", key, since,
+			[]string{model.DeliverySent, model.DeliveryPending, model.DeliverySending, model.DeliveryQueued, model.DeliveryDigested}).
+		Count(&count)
+	return count > 0
+}
+
+var eventTitles = map[string]string{
+	model.NotifyIncidentCreated:   "New incident",
+	model.NotifyIncidentEscalated: "Incident escalated",
+	model.NotifyIncidentResolved:  "Incident resolved",
+	model.NotifySLABreached:       "Incident SLA breached",
+}
+
+func incidentMessage(event string, incident model.Incident, detail string) Message {
+	summary := IncidentSummary{
+		ID:         incident.ID,
+		Name:       incident.Name,
+		Severity:   incident.Severity,
+		Status:     incident.Status,
+		RuleID:     incident.RuleID,
+		Source:     incident.Source,
+		AlertCount: incident.AlertCount,
+		FirstSeen:  incident.FirstSeen,
+		LastSeen:   incident.LastSeen,
+		Event:      event,
+		Detail:     detail,
+	}
+	title := fmt.Sprintf("[%s] %s #%d: %s", strings.ToUpper(incident.Severity), eventTitles[event], incident.ID, incident.Name)
+	lines := []string{
+		fmt.Sprintf("Severity: %s", incident.Severity),
+		fmt.Sprintf("Status: %s", incident.Status),
+		fmt.Sprintf("Alerts: %d", incident.AlertCount),
+	}
+	if !incident.FirstSeen.IsZero() {
+		lines = append(lines, fmt.Sprintf("First seen: %s", incident.FirstSeen.UTC().Format(time.RFC3339)))
+	}
+	if detail != "" {
+		lines = append(lines, detail)
+	}
+	return Message{
+		Event:    event,
+		Title:    title,
+		Text:     strings.Join(lines, "\n"),
+		Severity: incident.Severity,
+		Incident: &summary,
+		Time:     time.Now().UTC(),
+	}
+}
+
+// flushDigests 把每个 (渠道, 策略) 下最早一条已等待满 DigestMinutes 的 queued 记录合并为一条摘要
+func flushDigests(now time.Time) {
+	db := database.GetDB()
+	var queued []model.NotifyDelivery
+	db.Where("status = ?", model.DeliveryQueued).Order("id asc").Find(&queued)
+	if len(queued) == 0 {
+		return
+	}
+	var policies []model.NotifyPolicy
+	db.Find(&policies)
+	windows := make(map[uint]time.Duration, len(policies))
+	for _, p := range policies {
+		windows[p.ID] = time.Duration(p.DigestMinutes) * time.Minute
+	}
+
+	groups := make(map[[2]uint][]model.NotifyDelivery)
+	var order [][2]uint
+	for _, d := range queued {
+		key := [2]uint{d.ChannelID, d.PolicyID}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+	for _, key := range order {
+		items := groups[key]
+		if now.Sub(items[0].CreatedAt) < windows[key[1]] {
+			continue
+		}
+		digest := digestDelivery(key[0], key[1], items)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(digest).Error; err != nil {
+				return err
+			}
+			ids := make([]uint, 0, len(items))
+			for _, d := range items {
+				ids = append(ids, d.ID)
+			}
+			return tx.Model(&model.NotifyDelivery{}).Where("id IN ?", ids).
+				Updates(map[string]interface{}{"status": model.DeliveryDigested, "digest_id": digest.ID}).Error
+		})
+		if err != nil {
+			log.Printf("[Notify] Failed to build digest: %v", err)
+			continue
+		}
+		deliver(digest)
+	}
+}
+
+func digestDelivery(channelID, policyID uint, items []model.NotifyDelivery) *model.NotifyDelivery {
+	msg := Message{Event: model.NotifyDigest, Time: time.Now().UTC()}
+	var incidentIDs []uint
+	seen := make(map[uint]bool)
+	severity := ""
+	for _, d := range items {
+		var m Message
+		if json.Unmarshal(d.Message, &m) != nil || m.Incident == nil {
+			continue
+		}
+		msg.Incidents = append(msg.Incidents, *m.Incident)
+		if !seen[m.Incident.ID] {
+			seen[m.Incident.ID] = true
+			incidentIDs = append(incidentIDs, m.Incident.ID)
+		}
+		if severityRank[m.Incident.Severity] > severityRank[severity] {
+			severity = m.Incident.Severity
+		}
+	}
+	sort.Slice(incidentIDs, func(i, j int) bool { return incidentIDs[i] < incidentIDs[j] })
+	msg.Severity = severity
+	msg.Title = fmt.Sprintf("vsentry digest: %d notification(s) for %d incident(s)", len(msg.Incidents), len(incidentIDs))
+	lines := make([]string, 0, len(msg.Incidents))
+	for _, s := range msg.Incidents {
+		lines = append(lines, fmt.Sprintf("- [%s] #%d %s (%s)", s.Severity, s.ID, s.Name, strings.ReplaceAll(s.Event, "_", " ")))
+	}
+	msg.Text = strings.Join(lines, "\n")
+	payload, _ := json.Marshal(msg)
+	return &model.NotifyDelivery{
+		ChannelID:   channelID,
+		PolicyID:    policyID,
+		IncidentIDs: incidentIDs,
+		Event:       model.NotifyDigest,
+		Title:       msg.Title,
+		Message:     payload,
+		Status:      model.DeliveryPending,
+	}
+}
+
+var severityRank = map[string]int{"info": 1, "low": 2, "medium": 3, "high": 4, "critical": 5}
+
+// deliver 领取一条待发送的投递记录后发送，已被即时发送、后台重试或手动重试领取的记录直接跳过
+func deliver(d *model.NotifyDelivery) {
+	if claim(d, []string{model.DeliveryPending}, nil) {
+		send(d)
+	}
+}
+
+// claim 原子地把 from 状态的记录改为 sending 并设置租约，RowsAffected 为 1 才算领取成功
+func claim(d *model.NotifyDelivery, from []string, updates map[string]interface{}) bool {
+	lease := time.Now().UTC().Add(sendLease)
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"], updates["next_attempt_at"] = model.DeliverySending, lease
+	res := database.GetDB().Model(&model.NotifyDelivery{}).Where("id = ? AND status IN ?", d.ID, from).Updates(updates)
+	if res.Error != nil || res.RowsAffected != 1 {
+		return false
+	}
+	d.Status, d.NextAttemptAt = model.DeliverySending, &lease
+	return true
+}
+
+// send 发送一条已领取的投递记录并Update Status，Failed时安排重试
+func send(d *model.NotifyDelivery) {
+	db := database.GetDB()
+	var channel model.NotifyChannel
+	err := db.First(&channel, d.ChannelID).Error
+	if err == nil && !channel.Enabled {
+		err = fmt.Errorf("channel %s is disabled", channel.Name)
+	}
+	if err == nil {
+		var msg Message
+		if err = json.Unmarshal(d.Message, &msg); err == nil {
+			err = Send(channel, msg)
+		}
+	}
+
+	now := time.Now().UTC()
+	d.Attempts++
+	updates := map[string]interface{}{"attempts": d.Attempts}
+	if err == nil {
+		d.Status, d.SentAt, d.LastError = model.DeliverySent, &now, ""
+		updates["status"], updates["sent_at"], updates["last_error"], updates["next_attempt_at"] = d.Status, now, "", nil
+	} else {
+		d.LastError = err.Error()
+		updates["last_error"] = d.LastError
+		if d.Attempts >= maxAttempts() {
+			d.Status = model.DeliveryFailed
+			updates["status"], updates["next_attempt_at"] = d.Status, nil
+		} else {
+			next := now.Add(retryDelay(d.Attempts))
+			d.Status, d.NextAttemptAt = model.DeliveryPending, &next
+			updates["status"], updates["next_attempt_at"] = d.Status, next
+		}
+		log.Printf("[Notify] Delivery %d to channel %d failed (attempt %d): %v", d.ID, d.ChannelID, d.Attempts, err)
+	}
+	db.Model(&model.NotifyDelivery{}).Where("id = ?", d.ID).Updates(updates)
+}
+
+// Retry 立即重新发送一条投递记录 (包括已放弃的)，记录已被其他发送方领取或状态已变化时Return false
+func Retry(d *model.NotifyDelivery) bool {
+	attempts := d.Attempts
+	if d.Status == model.DeliveryFailed {
+		attempts = 0
+	}
+	if !claim(d, []string{d.Status}, map[string]interface{}{"attempts": attempts}) {
+		return false
+	}
+	d.Attempts = attempts
+	send(d)
+	return true
+}
+
+// Test 向渠道发送测试消息并记录投递
+func Test(channel model.NotifyChannel) (*model.NotifyDelivery, error) {
+	msg := Message{
+		Event:    model.NotifyTest,
+		Title:    "vsentry test notification",
+		Text:     fmt.Sprintf("Channel %q is configured correctly.", channel.Name),
+		Severity: "info",
+		Time:     time.Now().UTC(),
+	}
+	payload, _ := json.Marshal(msg)
+	d := &model.NotifyDelivery{ChannelID: channel.ID, Event: model.NotifyTest, Title: msg.Title, Message: payload, Status: model.DeliveryPending, Attempts: 1}
+	now := time.Now().UTC()
+	err := Send(channel, msg)
+	if err != nil {
+		d.Status, d.LastError = model.DeliveryFailed, err.Error()
+	} else {
+		d.Status, d.SentAt = model.DeliverySent, &now
+	}
+	database.GetDB().Create(d)
+	return d, err
+}
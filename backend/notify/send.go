@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/laenix/vsentry/automation"
+	"github.com/laenix/vsentry/model"
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Send 通过渠道发送一条通知
+func Send(channel model.NotifyChannel, msg Message) error {
+	switch channel.Type {
+	case model.ChannelEmail:
+		return sendEmail(channel, msg)
+	case model.ChannelWebhook:
+		body, err := RenderWebhook(channel.Template, msg)
+		if err != nil {
+			return err
+		}
+		return post(channel, body)
+	case model.ChannelSlack, model.ChannelMattermost:
+		body, _ := json.Marshal(map[string]string{"text": "*" + msg.Title + "*\n" + msg.Text})
+		return post(channel, body)
+	case model.ChannelTeams:
+		body, _ := json.Marshal(map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    msg.Title,
+			"themeColor": teamsColors[msg.Severity],
+			"title":      msg.Title,
+			"text":       strings.ReplaceAll(msg.Text, "\n", "<br>"),
+		})
+		return post(channel, body)
+	case model.ChannelSyslog:
+		return sendSyslog(channel, msg)
+	}
+	return fmt.Errorf("unsupported channel type %q", channel.Type)
+}
+
+var teamsColors = map[string]string{
+	"critical": "8B0000",
+	"high":     "D9534F",
+	"medium":   "F0AD4E",
+	"low":      "5BC0DE",
+	"info":     "777777",
+}
+
+// RenderWebhook 渲染 webhook 请求体，模板为空时发送 Message 的 JSON
+func RenderWebhook(tmpl string, msg Message) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(msg)
+	}
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		// json 输出 JSON 编码的值，便于在模板Medium拼接字符串字段
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, msg); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func post(channel model.NotifyChannel, body []byte) error {
+	if channel.URL == "" {
+		return fmt.Errorf("channel %s has no url", channel.Name)
+	}
+	method := strings.ToUpper(channel.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range channel.Headers {
+		req.Header.Set(k, fmt.Sprint(v))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+func sendEmail(channel model.NotifyChannel, msg Message) error {
+	port := channel.Port
+	if port == 0 {
+		port = 25
+	}
+	content := "<h3>" + html.EscapeString(msg.Title) + "</h3><pre>" + html.EscapeString(msg.Text) + "</pre>"
+	result := automation.RunSendEmail(map[string]interface{}{
+		"host":     channel.Host,
+		"port":     float64(port),
+		"username": channel.Username,
+		"password": channel.Password,
+		"from":     channel.From,
+		"to":       channel.To,
+		"subject":  msg.Title,
+		"content":  content,
+	})
+	if result.Status != "success" {
+		return fmt.Errorf("%s", result.Error)
+	}
+	return nil
+}
+
+// syslog Severity (RFC 5424)：critical=2 ... info=6
+var syslogSeverity = map[string]int{"critical": 2, "high": 3, "medium": 4, "low": 5, "info": 6}
+
+// sendSyslog 以 RFC 5424 格式发送到 Host:Port，facility 为 local0
+func sendSyslog(channel model.NotifyChannel, msg Message) error {
+	network := channel.Network
+	if network == "" {
+		network = "udp"
+	}
+	port := channel.Port
+	if port == 0 {
+		port = 514
+	}
+	severity, ok := syslogSeverity[msg.Severity]
+	if !ok {
+		severity = 5
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	body, _ := json.Marshal(msg)
+	line := fmt.Sprintf("<%d>1 %s %s vsentry - %s - %s", 16*8+severity, msg.Time.UTC().Format(time.RFC3339),
+		hostname, msg.Event, body)
+
+	conn, err := net.DialTimeout(network, net.JoinHostPort(channel.Host, fmt.Sprint(port)), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if network == "tcp" {
+		// RFC 6587 octet counting
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	_, err = conn.Write([]byte(line))
+	return err
+}
@@ -0,0 +1,349 @@
+// Package observable 从Alert内容Medium提取 Observable (IP / 用户 / 主机 / Hash / 域名 / URL / 命令行)
+// 并按 Incident 去重保存。提取来源：已知 OCSF 字段Path + 对 raw_data 的正则扫描。
+package observable
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/model"
+	"gorm.io/gorm"
+)
+
+// RawField 正则扫描得到的 Observable 记录的字段名
+const RawField = "raw_data"
+
+// maxValueLen 超长的Value (如整段脚本) 不作为 Observable
+const maxValueLen = 2048
+
+// ocsfFields 已知 OCSF 字段 => Observable Type
+var ocsfFields = map[string]string{
+	"src_endpoint.ip":                 model.ObservableIP,
+	"dst_endpoint.ip":                 model.ObservableIP,
+	"device.ip":                       model.ObservableIP,
+	"observer.ip":                     model.ObservableIP,
+	"src_ip":                          model.ObservableIP,
+	"dst_ip":                          model.ObservableIP,
+	"actor.user.name":                 model.ObservableUser,
+	"user.name":                       model.ObservableUser,
+	"target.user":                     model.ObservableUser,
+	"target.user.name":                model.ObservableUser,
+	"target_user.name":                model.ObservableUser,
+	"device.hostname":                 model.ObservableHost,
+	"src_endpoint.hostname":           model.ObservableHost,
+	"dst_endpoint.hostname":           model.ObservableHost,
+	"observer.hostname":               model.ObservableHost,
+	"host.name":                       model.ObservableHost,
+	"hostname":                        model.ObservableHost,
+	"dst_endpoint.domain":             model.ObservableDomain,
+	"query.hostname":                  model.ObservableDomain,
+	"url.hostname":                    model.ObservableDomain,
+	"url.url_string":                  model.ObservableURL,
+	"http_request.url.url_string":     model.ObservableURL,
+	"process.cmd_line":                model.ObservableCommand,
+	"actor.process.cmd_line":          model.ObservableCommand,
+	"process.parent_process.cmd_line": model.ObservableCommand,
+}
+
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	urlPattern  = regexp.MustCompile(`(?i)\bhttps?://[^\s"'<>\\]+`)
+	hashPattern = regexp.MustCompile(`\b(?:[0-9a-fA-F]{64}|[0-9a-fA-F]{40}|[0-9a-fA-F]{32})\b`)
+	hexPattern  = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+	hashFieldPattern = regexp.MustCompile(`(^|\.)hashes(\.(md5|sha1|sha256|value))?$`)
+)
+
+// Item 一次提取结果
+type Item struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Field string `json:"field"`
+}
+
+// Extract 从一条Alert内容Medium提取 Observable，同一 (type, value) 只Return一次 (Field 为逗号分隔的全部来源)
+func Extract(content string) []Item {
+	found := make(map[[2]string][]string)
+	var order [][2]string
+	add := func(typ, value, field string) {
+		typ, value = normalize(typ, value)
+		if typ == "" {
+			return
+		}
+		key := [2]string{typ, value}
+		if _, ok := found[key]; !ok {
+			order = append(order, key)
+		}
+		for _, f := range found[key] {
+			if f == field {
+				return
+			}
+		}
+		found[key] = append(found[key], field)
+	}
+
+	var event interface{}
+	if err := json.Unmarshal([]byte(content), &event); err != nil {
+		scanRaw(content, add)
+	} else {
+		walk("", event, func(path, value string) {
+			if path == RawField {
+				scanRaw(value, add)
+				return
+			}
+			if typ, ok := ocsfFields[path]; ok {
+				add(typ, value, path)
+			} else if isHashField(path) {
+				add(hashType(value), value, path)
+			}
+		})
+	}
+
+	items := make([]Item, 0, len(order))
+	for _, key := range order {
+		items = append(items, Item{Type: key[0], Value: key[1], Field: strings.Join(found[key], ",")})
+	}
+	return items
+}
+
+// walk 递归遍历Event，数Group元素沿用父字段Path
+func walk(path string, v interface{}, fn func(path, value string)) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			walk(p, child, fn)
+		}
+	case []interface{}:
+		for _, child := range val {
+			walk(path, child, fn)
+		}
+	case string:
+		fn(path, val)
+	}
+}
+
+// isHashField file.hashes / process.file.hashes 等 OCSF Hash：hashes.md5 / sha1 / sha256 对象字段、hashes[].value 数Group或字符串数Group
+func isHashField(path string) bool {
+	return hashFieldPattern.MatchString(path)
+}
+
+// scanRaw 正则扫描原始Log文本
+func scanRaw(text string, add func(typ, value, field string)) {
+	for _, m := range ipv4Pattern.FindAllString(text, -1) {
+		add(model.ObservableIP, m, RawField)
+	}
+	for _, m := range urlPattern.FindAllString(text, -1) {
+		m = strings.TrimRight(m, ".,;:)]}")
+		add(model.ObservableURL, m, RawField)
+		if u, err := url.Parse(m); err == nil && net.ParseIP(u.Hostname()) == nil {
+			add(model.ObservableDomain, u.Hostname(), RawField)
+		}
+	}
+	for _, m := range hashPattern.FindAllString(text, -1) {
+		add(hashType(m), m, RawField)
+	}
+}
+
+// normalize 校验并规范化，无效Value (回环地址、占位符、超长) Return空Type
+func normalize(typ, value string) (string, string) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "-" || len(value) > maxValueLen {
+		return "", ""
+	}
+	switch typ {
+	case model.ObservableIP:
+		ip := net.ParseIP(strings.Trim(value, "[]"))
+		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+			return "", ""
+		}
+		return typ, ip.String()
+	case model.ObservableDomain, model.ObservableHost:
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+		if value == "" || value == "localhost" {
+			return "", ""
+		}
+		return typ, value
+	case model.IndicatorMD5, model.IndicatorSHA1, model.IndicatorSHA256:
+		if hashType(value) != typ {
+			return "", ""
+		}
+		return typ, strings.ToLower(value)
+	case model.ObservableURL, model.ObservableUser, model.ObservableCommand:
+		return typ, value
+	}
+	return "", ""
+}
+
+func hashType(v string) string {
+	if !hexPattern.MatchString(v) {
+		return ""
+	}
+	switch len(v) {
+	case 32:
+		return model.IndicatorMD5
+	case 40:
+		return model.IndicatorSHA1
+	case 64:
+		return model.IndicatorSHA256
+	}
+	return ""
+}
+
+// mu 串行化 Observable 的读改写
+var mu sync.Mutex
+
+// Record 提取Alert的 Observable 并累加到所属 Incident，新出现的 Observable 继承其他 Incident 上的全局 Tag
+func Record(db *gorm.DB, alert model.Alert) {
+	if alert.IncidentID == 0 || alert.Suppressed {
+		return
+	}
+	items := Extract(alert.Content)
+	if len(items) == 0 {
+		return
+	}
+	at := alert.CreatedAt
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, item := range items {
+		var obs model.Observable
+		db.Where(model.Observable{IncidentID: alert.IncidentID, Type: item.Type, Value: item.Value}).
+			Attrs(model.Observable{FirstSeen: at}).FirstOrInit(&obs)
+		if obs.ID == 0 {
+			obs.Tag, obs.TagNote = globalTag(db, item.Type, item.Value)
+		}
+		obs.Count++
+		obs.Fields = unionFields(obs.Fields, item.Field)
+		if at.Before(obs.FirstSeen) {
+			obs.FirstSeen = at
+		}
+		if at.After(obs.LastSeen) {
+			obs.LastSeen = at
+		}
+		if err := db.Save(&obs).Error; err != nil {
+			log.Printf("[Observable] Failed to save %s %s: %v", obs.Type, obs.Value, err)
+		}
+	}
+}
+
+// Rebuild Alert在 Incident 之间移动 (合并/拆分) 后按当ago Alert 重New计算 Observable，保留已有 Tag
+func Rebuild(db *gorm.DB, incidentIDs ...uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range incidentIDs {
+		var existing []model.Observable
+		db.Select("type", "value", "tag", "tag_note").Where("incident_id = ? AND tag != ?", id, "").Find(&existing)
+		tags := make(map[[2]string][2]string, len(existing))
+		for _, obs := range existing {
+			tags[[2]string{obs.Type, obs.Value}] = [2]string{obs.Tag, obs.TagNote}
+		}
+		if err := db.Where("incident_id = ?", id).Delete(&model.Observable{}).Error; err != nil {
+			return err
+		}
+		var alerts []model.Alert
+		db.Select("id", "created_at", "content").Where("incident_id = ? AND suppressed = ?", id, false).Order("id asc").Find(&alerts)
+
+		byKey := make(map[[2]string]*model.Observable)
+		var order [][2]string
+		for _, a := range alerts {
+			for _, item := range Extract(a.Content) {
+				key := [2]string{item.Type, item.Value}
+				obs := byKey[key]
+				if obs == nil {
+					obs = &model.Observable{IncidentID: id, Type: item.Type, Value: item.Value, FirstSeen: a.CreatedAt}
+					byKey[key] = obs
+					order = append(order, key)
+				}
+				obs.Count++
+				obs.Fields = unionFields(obs.Fields, item.Field)
+				if a.CreatedAt.Before(obs.FirstSeen) {
+					obs.FirstSeen = a.CreatedAt
+				}
+				if a.CreatedAt.After(obs.LastSeen) {
+					obs.LastSeen = a.CreatedAt
+				}
+			}
+		}
+		for _, key := range order {
+			obs := byKey[key]
+			if tag, ok := tags[key]; ok {
+				obs.Tag, obs.TagNote = tag[0], tag[1]
+			} else {
+				obs.Tag, obs.TagNote = globalTag(db, obs.Type, obs.Value)
+			}
+			if err := db.Create(obs).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetTag 为 (type, value) 在所有 Incident Medium设置 Tag，tag 为空表示清除，Return Update的记录数
+func SetTag(db *gorm.DB, typ, value, tag, note string) (int64, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if tag == "" {
+		note = ""
+	}
+	res := db.Model(&model.Observable{}).Where("type = ? AND value = ?", typ, value).
+		Updates(map[string]interface{}{"tag": tag, "tag_note": note})
+	return res.RowsAffected, res.Error
+}
+
+// Normalize 规范化Query/标记时输入的Value；typ 为空时按形态识别
+func Normalize(typ, value string) (string, string) {
+	if typ == "" {
+		switch v := strings.TrimSpace(value); {
+		case net.ParseIP(strings.Trim(v, "[]")) != nil:
+			typ = model.ObservableIP
+		case hashType(v) != "":
+			typ = hashType(v)
+		case strings.Contains(v, "://"):
+			typ = model.ObservableURL
+		default:
+			return "", strings.TrimSpace(value)
+		}
+	}
+	t, v := normalize(typ, value)
+	if t == "" {
+		return "", strings.TrimSpace(value)
+	}
+	return t, v
+}
+
+func globalTag(db *gorm.DB, typ, value string) (string, string) {
+	var tagged []model.Observable
+	db.Select("tag", "tag_note").Where("type = ? AND value = ? AND tag != ?", typ, value, "").Limit(1).Find(&tagged)
+	if len(tagged) == 0 {
+		return "", ""
+	}
+	return tagged[0].Tag, tagged[0].TagNote
+}
+
+func unionFields(existing, add string) string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, f := range strings.Split(existing+","+add, ",") {
+		if f != "" && !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
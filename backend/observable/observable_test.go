@@ -0,0 +1,79 @@
+package observable
+
+import (
+	"testing"
+
+	"github.com/laenix/vsentry/model"
+)
+
+func TestExtract(t *testing.T) {
+	const (
+		md5    = "d41d8cd98f00b204e9800998ecf8427e"
+		sha1   = "da39a3ee5e6b4b0d3255bfef95601890afd80709"
+		sha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	)
+	cases := []struct {
+		name    string
+		content string
+		want    []Item
+	}{
+		{
+			name:    "flattened file hashes",
+			content: `{"file.hashes.md5":"` + md5 + `","src_endpoint.ip":"10.1.2.3"}`,
+			want: []Item{
+				{Type: model.IndicatorMD5, Value: md5, Field: "file.hashes.md5"},
+				{Type: model.ObservableIP, Value: "10.1.2.3", Field: "src_endpoint.ip"},
+			},
+		},
+		{
+			name:    "nested file hashes",
+			content: `{"file":{"hashes":{"sha1":"` + sha1 + `","sha256":"` + sha256 + `"}}}`,
+			want: []Item{
+				{Type: model.IndicatorSHA1, Value: sha1, Field: "file.hashes.sha1"},
+				{Type: model.IndicatorSHA256, Value: sha256, Field: "file.hashes.sha256"},
+			},
+		},
+		{
+			name:    "hash array",
+			content: `{"process":{"file":{"hashes":[{"algorithm":"MD5","value":"` + md5 + `"}]}}}`,
+			want:    []Item{{Type: model.IndicatorMD5, Value: md5, Field: "process.file.hashes.value"}},
+		},
+		{
+			name:    "hash strings",
+			content: `{"hashes":["` + sha1 + `"]}`,
+			want:    []Item{{Type: model.IndicatorSHA1, Value: sha1, Field: "hashes"}},
+		},
+		{
+			name:    "not a hash field",
+			content: `{"file.name":"` + md5 + `","myhashes":"` + md5 + `"}`,
+			want:    []Item{},
+		},
+		{
+			name:    "raw data",
+			content: `{"raw_data":"download from http://evil.example/a.exe by 10.0.0.5 md5=` + md5 + `"}`,
+			want: []Item{
+				{Type: model.ObservableIP, Value: "10.0.0.5", Field: RawField},
+				{Type: model.ObservableURL, Value: "http://evil.example/a.exe", Field: RawField},
+				{Type: model.ObservableDomain, Value: "evil.example", Field: RawField},
+				{Type: model.IndicatorMD5, Value: md5, Field: RawField},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Extract(c.content)
+			if len(got) != len(c.want) {
+				t.Fatalf("Extract(%s) = %+v, want %+v", c.content, got, c.want)
+			}
+			want := make(map[Item]bool, len(c.want))
+			for _, item := range c.want {
+				want[item] = true
+			}
+			for _, item := range got {
+				if !want[item] {
+					t.Errorf("Extract(%s): unexpected %+v, want %+v", c.content, item, c.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package attack
+
+// ==============================================================================
+// MITRE ATT&CK Enterprise 数据集
+// 内置 enterprise-attack.json (tactic / technique / sub-technique 精简版)，
+// 也可以通过 LoadSTIX 加载官方 enterprise-attack STIX 2.1 bundle 替换。
+// ==============================================================================
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed enterprise-attack.json
+var bundled []byte
+
+// TagPrefix Rule标签Medium ATT&CK 映射的ago缀 (与 Sigma 一致: attack.t1059.001 / attack.execution)
+const TagPrefix = "attack."
+
+type Tactic struct {
+	ID        string `json:"id"`        // TA0002
+	Name      string `json:"name"`      // Execution
+	ShortName string `json:"shortname"` // execution
+}
+
+type Technique struct {
+	ID      string   `json:"id"`   // T1059 / T1059.001
+	Name    string   `json:"name"` // PowerShell
+	Tactics []string `json:"tactics,omitempty"`
+	Parent  string   `json:"parent,omitempty"`
+}
+
+// IsSubTechnique 是否为子技术
+func (t *Technique) IsSubTechnique() bool {
+	return t.Parent != ""
+}
+
+type Dataset struct {
+	Domain     string      `json:"domain"`
+	Version    string      `json:"version"`
+	Tactics    []Tactic    `json:"tactics"`
+	Techniques []Technique `json:"techniques"`
+
+	tacticByID    map[string]*Tactic
+	tacticByShort map[string]*Tactic
+	techByID      map[string]*Technique
+}
+
+var (
+	current *Dataset
+	mu      sync.RWMutex
+)
+
+// Default Return当ago使用的数据集，首次调用时加载内置数据
+func Default() *Dataset {
+	mu.RLock()
+	ds := current
+	mu.RUnlock()
+	if ds != nil {
+		return ds
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		var d Dataset
+		if err := json.Unmarshal(bundled, &d); err != nil {
+			panic(fmt.Sprintf("attack: invalid bundled dataset: %v", err))
+		}
+		d.index()
+		current = &d
+	}
+	return current
+}
+
+// LoadSTIX 从官方 enterprise-attack.json (STIX 2.1 bundle) 加载并替换内置数据集
+func LoadSTIX(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var bundle struct {
+		Objects []struct {
+			Type         string `json:"type"`
+			Name         string `json:"name"`
+			ShortName    string `json:"x_mitre_shortname"`
+			Revoked      bool   `json:"revoked"`
+			Deprecated   bool   `json:"x_mitre_deprecated"`
+			Version      string `json:"x_mitre_version"`
+			ExternalRefs []struct {
+				SourceName string `json:"source_name"`
+				ExternalID string `json:"external_id"`
+			} `json:"external_references"`
+			KillChainPhases []struct {
+				KillChainName string `json:"kill_chain_name"`
+				PhaseName     string `json:"phase_name"`
+			} `json:"kill_chain_phases"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return fmt.Errorf("invalid STIX bundle: %v", err)
+	}
+
+	d := Dataset{Domain: "enterprise-attack"}
+	for _, obj := range bundle.Objects {
+		if obj.Revoked || obj.Deprecated {
+			continue
+		}
+		var extID string
+		for _, ref := range obj.ExternalRefs {
+			if ref.SourceName == "mitre-attack" {
+				extID = ref.ExternalID
+				break
+			}
+		}
+		if extID == "" {
+			continue
+		}
+
+		switch obj.Type {
+		case "x-mitre-tactic":
+			d.Tactics = append(d.Tactics, Tactic{ID: extID, Name: obj.Name, ShortName: obj.ShortName})
+		case "attack-pattern":
+			tech := Technique{ID: extID, Name: obj.Name}
+			for _, phase := range obj.KillChainPhases {
+				if phase.KillChainName == "mitre-attack" {
+					tech.Tactics = append(tech.Tactics, phase.PhaseName)
+				}
+			}
+			d.Techniques = append(d.Techniques, tech)
+		case "x-mitre-collection":
+			d.Version = obj.Version
+		}
+	}
+	if len(d.Tactics) == 0 || len(d.Techniques) == 0 {
+		return fmt.Errorf("no tactics or techniques found in %s", path)
+	}
+	sort.Slice(d.Techniques, func(i, j int) bool { return d.Techniques[i].ID < d.Techniques[j].ID })
+	d.index()
+
+	mu.Lock()
+	current = &d
+	mu.Unlock()
+	return nil
+}
+
+// index 建立索引，并让子技术继承父技术的 tactic
+func (d *Dataset) index() {
+	d.tacticByID = make(map[string]*Tactic)
+	d.tacticByShort = make(map[string]*Tactic)
+	d.techByID = make(map[string]*Technique)
+
+	for i := range d.Tactics {
+		t := &d.Tactics[i]
+		d.tacticByID[t.ID] = t
+		d.tacticByShort[t.ShortName] = t
+	}
+	for i := range d.Techniques {
+		t := &d.Techniques[i]
+		if idx := strings.Index(t.ID, "."); idx > 0 {
+			t.Parent = t.ID[:idx]
+		}
+		d.techByID[t.ID] = t
+	}
+	for i := range d.Techniques {
+		t := &d.Techniques[i]
+		if t.Parent != "" && len(t.Tactics) == 0 {
+			if p, ok := d.techByID[t.Parent]; ok {
+				t.Tactics = p.Tactics
+			}
+		}
+	}
+}
+
+// Tactic 按 ID (TA0002) 或 shortname (execution) 查找 tactic
+func (d *Dataset) Tactic(key string) (*Tactic, bool) {
+	if t, ok := d.tacticByID[strings.ToUpper(key)]; ok {
+		return t, true
+	}
+	t, ok := d.tacticByShort[strings.ToLower(key)]
+	return t, ok
+}
+
+// Technique 按 ID 查找 technique / sub-technique
+func (d *Dataset) Technique(id string) (*Technique, bool) {
+	t, ok := d.techByID[strings.ToUpper(id)]
+	return t, ok
+}
+
+// Mapping 一条Rule/Event最终映射到的 ATT&CK ID
+type Mapping struct {
+	Tactics    []string `json:"tactics"`    // TA0002
+	Techniques []string `json:"techniques"` // T1059.001
+}
+
+// IsAttackTag 是否为 ATT&CK 标签
+func IsAttackTag(tag string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(tag)), TagPrefix)
+}
+
+// NormalizeTag 校验并规范化 ATT&CK 标签，统一为小写 attack.t1059.001 / attack.ta0002 / attack.execution
+func (d *Dataset) NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	key := strings.TrimPrefix(tag, TagPrefix)
+	if key == "" {
+		return "", fmt.Errorf("empty ATT&CK tag")
+	}
+	if strings.HasPrefix(key, "t") && !strings.HasPrefix(key, "ta") {
+		if _, ok := d.Technique(key); !ok {
+			return "", fmt.Errorf("unknown ATT&CK technique: %s", strings.ToUpper(key))
+		}
+		return TagPrefix + key, nil
+	}
+	key = strings.ReplaceAll(key, "_", "-")
+	if _, ok := d.Tactic(key); !ok {
+		return "", fmt.Errorf("unknown ATT&CK tactic: %s", key)
+	}
+	return TagPrefix + key, nil
+}
+
+// NormalizeTags 去重并校验Rule标签，attack.* 标签必须能在当ago数据集Medium找到
+func NormalizeTags(tags []string) ([]string, error) {
+	dataset := Default()
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if IsAttackTag(tag) {
+			normalized, err := dataset.NormalizeTag(tag)
+			if err != nil {
+				return nil, err
+			}
+			tag = normalized
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// Resolve 将标签解析为 tactic/technique ID；technique 会自动带出其所属 tactic
+func (d *Dataset) Resolve(tags []string) Mapping {
+	tactics := make(map[string]bool)
+	techniques := make(map[string]bool)
+
+	for _, tag := range tags {
+		if !IsAttackTag(tag) {
+			continue
+		}
+		key := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(tag)), TagPrefix)
+		if tech, ok := d.Technique(key); ok {
+			techniques[tech.ID] = true
+			for _, short := range tech.Tactics {
+				if t, ok := d.Tactic(short); ok {
+					tactics[t.ID] = true
+				}
+			}
+			continue
+		}
+		if t, ok := d.Tactic(strings.ReplaceAll(key, "_", "-")); ok {
+			tactics[t.ID] = true
+		}
+	}
+
+	return Mapping{Tactics: sortedKeys(tactics), Techniques: sortedKeys(techniques)}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,205 @@
+package filter
+
+// ==============================================================================
+// List接口共用的过滤语言
+//
+//   status:new,acknowledged severity:>=high rule:12 assignee:me
+//   last_seen:>=24h created:2024-01-01..2024-02-01 -status:resolved
+//   observable:10.0.0.5 "failed login" powershell
+//
+// - field:value        等于；逗号分隔多个值为 IN
+// - field:>v >=v <v <=v 比较；field:a..b 闭区间 (任一端可省略)
+// - -field:value       取反 (-word 为不包含)
+// - 其他词或引号短语为全文检索，多个词同时满足
+// Time值支持 RFC3339、2006-01-02、2006-01-02T15:04 以及相对Time (30m / 24h / 7d 或 now-24h，表示多久之ago)
+// ==============================================================================
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 比较运算符
+const (
+	OpEq    = "="
+	OpGt    = ">"
+	OpGte   = ">="
+	OpLt    = "<"
+	OpLte   = "<="
+	OpRange = ".."
+)
+
+// Term 一个过滤条件；Field 为空表示全文检索，Values[0] 为检索词
+type Term struct {
+	Field  string
+	Op     string
+	Values []string // OpEq 可有多个值；OpRange 为 [from, to]，省略的一端为空
+	Negate bool
+}
+
+// Parse 解析过滤Expression
+func Parse(q string) ([]Term, error) {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]Term, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.quoted {
+			terms = append(terms, Term{Values: []string{tok.text}, Op: OpEq})
+			continue
+		}
+		text := tok.text
+		negate := false
+		if strings.HasPrefix(text, "-") && len(text) > 1 {
+			negate = true
+			text = text[1:]
+		}
+		field, value, ok := strings.Cut(text, ":")
+		if !ok || field == "" || !isIdent(field) {
+			terms = append(terms, Term{Values: []string{text}, Op: OpEq, Negate: negate})
+			continue
+		}
+		if tok.quotedValue {
+			terms = append(terms, Term{Field: strings.ToLower(field), Op: OpEq, Values: []string{value}, Negate: negate})
+			continue
+		}
+		term := Term{Field: strings.ToLower(field), Negate: negate}
+		switch {
+		case strings.HasPrefix(value, ">="):
+			term.Op, term.Values = OpGte, []string{value[2:]}
+		case strings.HasPrefix(value, "<="):
+			term.Op, term.Values = OpLte, []string{value[2:]}
+		case strings.HasPrefix(value, ">"):
+			term.Op, term.Values = OpGt, []string{value[1:]}
+		case strings.HasPrefix(value, "<"):
+			term.Op, term.Values = OpLt, []string{value[1:]}
+		case strings.Contains(value, ".."):
+			from, to, _ := strings.Cut(value, "..")
+			term.Op, term.Values = OpRange, []string{from, to}
+			if from == "" && to == "" {
+				return nil, fmt.Errorf("%s: empty range", field)
+			}
+		default:
+			term.Op = OpEq
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					term.Values = append(term.Values, v)
+				}
+			}
+		}
+		if term.Op != OpRange && (len(term.Values) == 0 || term.Values[0] == "") {
+			return nil, fmt.Errorf("%s: missing value", field)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+type token struct {
+	text        string
+	quoted      bool // 整个 token 为引号短语
+	quotedValue bool // field:"value with spaces"
+}
+
+func tokenize(q string) ([]token, error) {
+	var tokens []token
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		if runes[i] == '"' {
+			text, next, err := quoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = next
+			continue
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' {
+			i++
+		}
+		text := string(runes[start:i])
+		if i < len(runes) && runes[i] == '"' && strings.HasSuffix(text, ":") {
+			value, next, err := quoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text + value, quotedValue: true})
+			i = next
+			continue
+		}
+		tokens = append(tokens, token{text: text})
+	}
+	return tokens, nil
+}
+
+// quoted 读取从 runes[i] (引号) 开始的短语，支持 \" 转义
+func quoted(runes []rune, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(runes); j++ {
+		switch runes[j] {
+		case '\\':
+			if j+1 < len(runes) {
+				j++
+				b.WriteRune(runes[j])
+			}
+		case '"':
+			return b.String(), j + 1, nil
+		default:
+			b.WriteRune(runes[j])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote")
+}
+
+func isIdent(s string) bool {
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseTime Parse绝对或相对Time，相对Time相对 now 向ago计算
+func ParseTime(v string, now time.Time) (time.Time, error) {
+	rel := strings.TrimPrefix(v, "now-")
+	if v == "now" {
+		return now, nil
+	}
+	if d, err := ParseDuration(rel); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", v)
+}
+
+// ParseDuration 在 time.ParseDuration 基础上支持天 (7d)
+func ParseDuration(v string) (time.Duration, error) {
+	if strings.HasSuffix(v, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return d, nil
+}
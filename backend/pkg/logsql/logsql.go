@@ -0,0 +1,312 @@
+package logsql
+
+// ==============================================================================
+// LogSQL 静态分析
+// 不是完整的 LogSQL 解析器 (完整语法以 VictoriaLogs 为准)，只做保存Rule时的
+// 基础语法检查 (引号/括号/管道) 和 lint 所需的信息提取：
+// 过滤段引用的字段、是否带 _time 过滤、ago导通配符。
+// ==============================================================================
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyntaxError 静态语法Error，Pos 为字节偏移
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Analysis Query分析结果
+type Analysis struct {
+	Fields           []string      // 过滤段 (第一个管道之ago) 引用的字段，去重且保持顺序
+	TimeFilter       bool          // 是否包含 _time 过滤
+	TimeRange        time.Duration // _time:5m 这类相对Time窗口，无法解析时为 0
+	LeadingWildcards []string      // *foo / field:*foo 形式的ago导通配符
+}
+
+// term 空白、括号、管道和逗号分隔出的一个词，引号内容原样保留
+type term struct {
+	text   string
+	quoted bool // 包含引号部分
+	pipe   int  // 所在段，0 为过滤段
+}
+
+var (
+	fieldPrefix   = regexp.MustCompile(`^[-!]?([A-Za-z_][\w.\-]*):`)
+	relativeRange = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ms|s|m|h|d|w|y)$`)
+	builtinFields = map[string]bool{"_time": true, "_stream": true, "_stream_id": true, "_msg": true}
+)
+
+// Analyze 对Query做静态检查并提取 lint 信息
+func Analyze(query string) (*Analysis, error) {
+	terms, err := scan(query)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analysis{}
+	seen := make(map[string]bool)
+	for _, t := range terms {
+		field, value := "", t.text
+		if m := fieldPrefix.FindStringSubmatch(t.text); m != nil {
+			field, value = m[1], t.text[len(m[0]):]
+		}
+
+		if field == "_time" {
+			a.TimeFilter = true
+			if m := relativeRange.FindStringSubmatch(value); m != nil && a.TimeRange == 0 {
+				a.TimeRange = parseRange(m[1], m[2])
+			}
+		}
+		if field != "" && t.pipe == 0 && !builtinFields[field] && !seen[field] {
+			seen[field] = true
+			a.Fields = append(a.Fields, field)
+		}
+		// 引号内的 * 是字面量；单独的 * 和 field:* 是合法的全匹配
+		if !t.quoted && t.pipe == 0 && len(value) > 1 && value[0] == '*' {
+			a.LeadingWildcards = append(a.LeadingWildcards, t.text)
+		}
+	}
+	return a, nil
+}
+
+// scan 按 LogSQL 的分隔规则切词，同时检查引号闭合、括号配对和空管道
+func scan(query string) ([]term, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty query"}
+	}
+
+	var (
+		terms   []term
+		cur     strings.Builder
+		quoted  bool
+		pipe    int
+		depth   int
+		opened  []int
+		pending = -1 // 最近一个管道的位置，管道后必须有内容
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			terms = append(terms, term{text: cur.String(), quoted: quoted, pipe: pipe})
+			cur.Reset()
+			quoted = false
+			pending = -1
+		}
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '"', '\'', '`':
+			end := closingQuote(query, i)
+			if end < 0 {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated quoted string"}
+			}
+			cur.WriteString(query[i : end+1])
+			quoted = true
+			i = end
+		case ' ', '\t', '\n', '\r', ',':
+			flush()
+		case '[', '(':
+			if end, ok := timeRangeEnd(query, i); ok {
+				if end < 0 {
+					return nil, &SyntaxError{Pos: i, Msg: "unclosed _time range"}
+				}
+				cur.WriteString(query[i : end+1])
+				i = end
+				continue
+			}
+			if c == '[' {
+				cur.WriteByte(c)
+				continue
+			}
+			flush()
+			depth++
+			opened = append(opened, i)
+		case ')':
+			flush()
+			if depth == 0 {
+				return nil, &SyntaxError{Pos: i, Msg: "unexpected ')'"}
+			}
+			depth--
+			opened = opened[:len(opened)-1]
+		case '|':
+			flush()
+			if depth > 0 {
+				return nil, &SyntaxError{Pos: opened[len(opened)-1], Msg: "unclosed '('"}
+			}
+			if pending >= 0 || (pipe == 0 && len(terms) == 0) {
+				return nil, &SyntaxError{Pos: i, Msg: "empty pipe"}
+			}
+			pipe++
+			pending = i
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+
+	if depth > 0 {
+		return nil, &SyntaxError{Pos: opened[len(opened)-1], Msg: "unclosed '('"}
+	}
+	if pending >= 0 {
+		return nil, &SyntaxError{Pos: pending, Msg: "empty pipe"}
+	}
+	return terms, nil
+}
+
+// timeRangeEnd 判断 i 处的 [ 或 ( 是否为 _time:[start, end) 这类区间的开头 (左右括号可任意组合)，
+// 是则Return闭合的 ] 或 ) 的位置，未闭合时为 -1
+func timeRangeEnd(s string, i int) (int, bool) {
+	prefix := strings.TrimLeft(lastWord(s[:i]), "-!")
+	if prefix != "_time:" {
+		return 0, false
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case ']', ')':
+			return j, true
+		case '|', '(', '[':
+			return -1, true
+		}
+	}
+	return -1, true
+}
+
+// lastWord s 末尾不含分隔符的部分
+func lastWord(s string) string {
+	if i := strings.LastIndexAny(s, " \t\n\r,()|"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// closingQuote Return与 start 处引号配对的位置，双引号支持反斜杠转义
+func closingQuote(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if s[i] == '\\' && q != '`' {
+			i++
+			continue
+		}
+		if s[i] == q {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseRange(num, unit string) time.Duration {
+	var n float64
+	fmt.Sscanf(num, "%g", &n)
+	scale := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+		"y":  365 * 24 * time.Hour,
+	}[unit]
+	return time.Duration(n * float64(scale))
+}
+
+var plainField = regexp.MustCompile(`^[A-Za-z_][\w.\-]*$`)
+
+// quoteField 字段名含特殊字符时加引号
+func quoteField(field string) string {
+	if plainField.MatchString(field) {
+		return field
+	}
+	return strconv.Quote(field)
+}
+
+// AnyFieldEquals 任一字段精确等于 value 的过滤条件，如 (a:="x" OR b:="x")
+func AnyFieldEquals(fields []string, value string) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, quoteField(f)+":="+strconv.Quote(value))
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+// FiltersValue 过滤段是否有 field:value 或 field:=value (值可加引号) 的条件直接匹配 value，否定条件不算
+func FiltersValue(query, field, value string) bool {
+	terms, err := scan(query)
+	if err != nil {
+		return false
+	}
+	for i, t := range terms {
+		m := fieldPrefix.FindStringSubmatch(t.text)
+		if t.pipe != 0 || m == nil || m[1] != field || t.text[0] == '-' || t.text[0] == '!' {
+			continue
+		}
+		if i > 0 && strings.EqualFold(terms[i-1].text, "NOT") {
+			continue
+		}
+		if unquote(strings.TrimPrefix(t.text[len(m[0]):], "=")) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// unquote 去掉值两侧的引号，双引号和反引号按 Go 字符串规则处理转义
+func unquote(v string) string {
+	if len(v) < 2 || v[0] != v[len(v)-1] || !strings.ContainsRune("\"'`", rune(v[0])) {
+		return v
+	}
+	if v[0] != '\'' {
+		if u, err := strconv.Unquote(v); err == nil {
+			return u
+		}
+	}
+	return v[1 : len(v)-1]
+}
+
+// ExcludeValue 在过滤段追加 field 不等于 value 的条件 (管道段保持不变)，原过滤段加括号以保留 OR 的优先级
+func ExcludeValue(query, field, value string) (string, error) {
+	if _, err := scan(query); err != nil {
+		return "", err
+	}
+	end := len(query)
+	depth := 0
+scan:
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '"', '\'', '`':
+			i = closingQuote(query, i)
+		case '[':
+			if end, ok := timeRangeEnd(query, i); ok {
+				i = end
+			}
+		case '(':
+			if end, ok := timeRangeEnd(query, i); ok {
+				i = end
+				continue
+			}
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				end = i
+				break scan
+			}
+		}
+	}
+	refined := fmt.Sprintf("(%s) -%s:=%s", strings.TrimSpace(query[:end]), quoteField(field), strconv.Quote(value))
+	if end < len(query) {
+		refined += " " + strings.TrimSpace(query[end:])
+	}
+	return refined, nil
+}
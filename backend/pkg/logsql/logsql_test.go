@@ -0,0 +1,94 @@
+package logsql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeTimeRange(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		fields []string
+		err    string
+	}{
+		{name: "relative", query: `_time:5m error`},
+		{name: "closed", query: `_time:[2024-01-01T00:00:00Z, 2024-01-02T00:00:00Z] error`},
+		{name: "half-open right", query: `_time:[2024-01-01T00:00:00Z, 2024-01-02T00:00:00Z) error`},
+		{name: "half-open left", query: `_time:(2024-01-01, 2024-01-02] error`},
+		{name: "open", query: `_time:(2024-01-01,2024-01-02) host:="a" | stats count()`, fields: []string{"host"}},
+		{name: "negated range", query: `-_time:[2024-01-01, 2024-01-02) (a:x OR b:y)`, fields: []string{"a", "b"}},
+		{name: "grouping after range", query: `(_time:[2024-01-01, 2024-01-02) OR x:1)`, fields: []string{"x"}},
+		{name: "unclosed range", query: `_time:[2024-01-01, 2024-01-02 error`, err: "unclosed _time range"},
+		{name: "unclosed group", query: `(a:x OR b:y`, err: "unclosed '('"},
+		{name: "stray paren", query: `a:x)`, err: "unexpected ')'"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, err := Analyze(c.query)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("Analyze(%q) error = %v, want %q", c.query, err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Analyze(%q): %v", c.query, err)
+			}
+			if !a.TimeFilter {
+				t.Errorf("Analyze(%q): TimeFilter = false", c.query)
+			}
+			if strings.Join(a.Fields, ",") != strings.Join(c.fields, ",") {
+				t.Errorf("Analyze(%q): Fields = %v, want %v", c.query, a.Fields, c.fields)
+			}
+		})
+	}
+}
+
+func TestExcludeValue(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{`error`, `(error) -host:="a"`},
+		{`a:x OR b:y | stats count()`, `(a:x OR b:y) -host:="a" | stats count()`},
+		{`_time:[2024-01-01T00:00:00Z, 2024-01-02T00:00:00Z) error | limit 10`, `(_time:[2024-01-01T00:00:00Z, 2024-01-02T00:00:00Z) error) -host:="a" | limit 10`},
+		{`_time:(2024-01-01, 2024-01-02] error`, `(_time:(2024-01-01, 2024-01-02] error) -host:="a"`},
+	}
+	for _, c := range cases {
+		got, err := ExcludeValue(c.query, "host", "a")
+		if err != nil {
+			t.Fatalf("ExcludeValue(%q): %v", c.query, err)
+		}
+		if got != c.want {
+			t.Errorf("ExcludeValue(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestFiltersValue(t *testing.T) {
+	cases := []struct {
+		query string
+		field string
+		value string
+		want  bool
+	}{
+		{`user.name:admin`, "user.name", "admin", true},
+		{`user.name:="admin" status:Failure`, "user.name", "admin", true},
+		{"status:`Failure`", "status", "Failure", true},
+		{`class_uid:=3002`, "class_uid", "3002", true},
+		{`(a:x OR user.name:"admin")`, "user.name", "admin", true},
+		{`src.user:admin`, "user.name", "admin", false},
+		{`"admin" login`, "user.name", "admin", false},
+		{`user.name:admins`, "user.name", "admin", false},
+		{`-user.name:admin`, "user.name", "admin", false},
+		{`NOT user.name:admin`, "user.name", "admin", false},
+		{`* | filter user.name:admin`, "user.name", "admin", false},
+		{`user.name:"unterminated`, "user.name", "admin", false},
+	}
+	for _, c := range cases {
+		if got := FiltersValue(c.query, c.field, c.value); got != c.want {
+			t.Errorf("FiltersValue(%q, %q, %q) = %v, want %v", c.query, c.field, c.value, got, c.want)
+		}
+	}
+}
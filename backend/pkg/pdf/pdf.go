@@ -0,0 +1,362 @@
+// Package pdf 纯 Go 的简单 PDF 排版，只支持文本：标题、段落、列表、等宽代码块和分隔线。
+//
+// 不嵌入字体：西文使用 PDF 标准 14 字体 (Helvetica / Courier)，Medium日韩文字使用阅读器内置的
+// STSong-Light (Adobe-GB1)，因此生成过程不依赖外部字体文件，可离线运行。
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A4，单位 pt
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	margin     = 50.0
+)
+
+// 字体资源名
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+	fontMono    = "F3"
+	fontCJK     = "F4"
+)
+
+// Document 按顺序追加内容，自动换行和分页
+type Document struct {
+	Title string
+
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+// New Create空文档
+func New(title string) *Document {
+	d := &Document{Title: title}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = pageHeight - margin
+}
+
+// Heading 标题，level 1-3
+func (d *Document) Heading(level int, text string) {
+	size := map[int]float64{1: 18, 2: 14, 3: 12}[level]
+	if size == 0 {
+		size = 11
+	}
+	d.Space(size * 0.6)
+	d.write(text, fontBold, size, 0, "")
+	d.Space(size * 0.3)
+}
+
+// Text 普通段落
+func (d *Document) Text(text string) {
+	d.write(text, fontRegular, 10, 0, "")
+}
+
+// Bold 粗体段落
+func (d *Document) Bold(text string) {
+	d.write(text, fontBold, 10, 0, "")
+}
+
+// Bullet 列表项，depth 从 0 开始
+func (d *Document) Bullet(depth int, text string) {
+	d.write(text, fontRegular, 10, 14*float64(depth+1), "- ")
+}
+
+// Code 等宽文本，保留行首空白
+func (d *Document) Code(text string) {
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		d.write(line, fontMono, 8.5, 10, "")
+	}
+}
+
+// Quote 缩进引用
+func (d *Document) Quote(text string) {
+	d.write(text, fontRegular, 10, 16, "")
+}
+
+// Space 垂直留白
+func (d *Document) Space(h float64) {
+	d.y -= h
+}
+
+// Line 水平分隔线
+func (d *Document) Line() {
+	d.ensure(12)
+	d.y -= 6
+	fmt.Fprintf(d.page, "0.6 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", margin, d.y, pageWidth-margin, d.y)
+	d.y -= 6
+}
+
+func (d *Document) ensure(h float64) {
+	if d.y-h < margin {
+		d.newPage()
+	}
+}
+
+// write 按可用宽度折行输出，prefix (如列表符号) 只出现在第一行
+func (d *Document) write(text, font string, size, indent float64, prefix string) {
+	leading := size * 1.4
+	width := pageWidth - 2*margin - indent
+	prefixWidth := textWidth(prefix, font, size)
+	lines := wrap(text, font, size, width-prefixWidth)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	for i, line := range lines {
+		d.ensure(leading)
+		d.y -= leading
+		x := margin + indent
+		if prefix != "" {
+			if i == 0 {
+				d.show(prefix, font, size, x, d.y)
+			}
+			x += prefixWidth
+		}
+		d.show(line, font, size, x, d.y)
+	}
+}
+
+// show 输出一行，西文与Medium日韩文字分段切换字体
+func (d *Document) show(line, font string, size, x, y float64) {
+	if line == "" {
+		return
+	}
+	fmt.Fprintf(d.page, "BT %.2f %.2f Td", x, y)
+	current := ""
+	var run []rune
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if current == fontCJK {
+			fmt.Fprintf(d.page, " /%s %.1f Tf <%s> Tj", fontCJK, size, ucs2(run))
+		} else {
+			fmt.Fprintf(d.page, " /%s %.1f Tf (%s) Tj", current, size, latin(run))
+		}
+		run = run[:0]
+	}
+	for _, r := range line {
+		f := font
+		if isWide(r) {
+			f = fontCJK
+		}
+		if f != current {
+			flush()
+			current = f
+		}
+		run = append(run, r)
+	}
+	flush()
+	d.page.WriteString(" ET\n")
+}
+
+// Bytes 生成 PDF 文件
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1 Catalog, 2 Pages 预留编号，页面对象在字体之后
+	catalog := obj("<< /Type /Catalog /Pages 2 0 R >>")
+	pagesObj := catalog + 1
+	offsets = append(offsets, 0) // Pages 稍后写入
+	f1 := obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	f2 := obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	f3 := obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	descriptor := obj("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cid := obj(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor %d 0 R /DW 1000 >>", descriptor))
+	f4 := obj(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cid))
+	resources := fmt.Sprintf("<< /Font << /%s %d 0 R /%s %d 0 R /%s %d 0 R /%s %d 0 R >> >>",
+		fontRegular, f1, fontBold, f2, fontMono, f3, fontCJK, f4)
+
+	kids := make([]string, 0, len(d.pages))
+	for i, p := range d.pages {
+		footer := fmt.Sprintf("BT /%s 8 Tf %.2f %.2f Td (%d / %d) Tj ET\n", fontRegular, pageWidth/2-10, margin/2, i+1, len(d.pages))
+		content := p.String() + footer
+		stream := obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+		page := obj(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			pagesObj, pageWidth, pageHeight, resources, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	info := obj(fmt.Sprintf("<< /Title <%s> /Producer (vsentry) /CreationDate (D:%s) >>",
+		utf16(d.Title), time.Now().UTC().Format("20060102150405Z")))
+
+	// 回填 Pages
+	pagesBody := fmt.Sprintf("%d 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", pagesObj, strings.Join(kids, " "), len(kids))
+	offsets[pagesObj-1] = out.Len()
+	out.WriteString(pagesBody)
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, catalog, info, xref)
+	return out.Bytes()
+}
+
+// isWide 不在 Latin-1 范围内的字符交给 CJK 字体
+func isWide(r rune) bool {
+	return r > 0xFF
+}
+
+// latin WinAnsi 字面量字符串
+func latin(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			fmt.Fprintf(&b, "\\%03o", byte(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ucs2 UniGB-UCS2-H 编码的十六进制字符串，BMP 以外的字符替换为 ?
+func ucs2(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// utf16 文档Info使用的 UTF-16BE 文本 (带 BOM)
+func utf16(s string) string {
+	var b strings.Builder
+	b.WriteString("FEFF")
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// wrap 按宽度折行：西文在空格处断开，Medium日韩文字可在任意字符间断开，超长单词强制断开
+func wrap(text, font string, size, width float64) []string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		var line []rune
+		lineWidth := 0.0
+		for _, token := range tokens(para) {
+			w := textWidth(token, font, size)
+			if lineWidth+w > width && len(line) > 0 {
+				lines = append(lines, strings.TrimRight(string(line), " "))
+				line, lineWidth = line[:0], 0
+				if strings.TrimSpace(token) == "" {
+					continue
+				}
+			}
+			if w > width {
+				for _, r := range token {
+					rw := textWidth(string(r), font, size)
+					if lineWidth+rw > width && len(line) > 0 {
+						lines = append(lines, string(line))
+						line, lineWidth = line[:0], 0
+					}
+					line = append(line, r)
+					lineWidth += rw
+				}
+				continue
+			}
+			line = append(line, []rune(token)...)
+			lineWidth += w
+		}
+		lines = append(lines, strings.TrimRight(string(line), " "))
+	}
+	return lines
+}
+
+// tokens 拆分为单词、空白和单个宽字符
+func tokens(s string) []string {
+	var out []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range s {
+		switch {
+		case isWide(r):
+			flush()
+			out = append(out, string(r))
+		case unicode.IsSpace(r):
+			if len(cur) > 0 && !unicode.IsSpace(cur[len(cur)-1]) {
+				flush()
+			}
+			cur = append(cur, r)
+		default:
+			if len(cur) > 0 && unicode.IsSpace(cur[len(cur)-1]) {
+				flush()
+			}
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return out
+}
+
+// textWidth 文本宽度 (pt)
+func textWidth(s, font string, size float64) float64 {
+	units := 0
+	for _, r := range s {
+		switch {
+		case isWide(r):
+			units += 1000
+		case font == fontMono:
+			units += 600
+		case r >= 32 && r <= 126:
+			units += helveticaWidths[r-32]
+		default:
+			units += 556
+		}
+	}
+	w := float64(units) * size / 1000
+	if font == fontBold {
+		w *= 1.06
+	}
+	return w
+}
+
+// helveticaWidths Helvetica AFM 字宽，字符 32-126
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
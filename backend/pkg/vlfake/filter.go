@@ -0,0 +1,624 @@
+package vlfake
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokLParen
+	tokRParen
+	tokPipe
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var (
+	fieldPrefix   = regexp.MustCompile(`^([A-Za-z_][\w.\-]*):`)
+	relativeRange = regexp.MustCompile(`^(\d+(?:\.\d+)?)(ms|s|m|h|d|w|y)$`)
+	streamLabel   = regexp.MustCompile(`^\s*([\w.\-]+)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"\s*$`)
+)
+
+// matcher 单个过滤条件
+type matcher func(e *entry) bool
+
+// tokenize 按 LogSQL 的分隔规则切词，引号和 {...} 内容整体保留
+func tokenize(q string) ([]token, error) {
+	var (
+		toks []token
+		cur  strings.Builder
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			toks = append(toks, token{kind: tokWord, text: cur.String()})
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; c {
+		case '"', '\'', '`':
+			end := closingQuote(q, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string at position %d", i)
+			}
+			cur.WriteString(q[i : end+1])
+			i = end
+		case '{':
+			end := strings.IndexByte(q[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '{' at position %d", i)
+			}
+			cur.WriteString(q[i : i+end+1])
+			i += end
+		case ' ', '\t', '\n', '\r', ',':
+			flush()
+		case '(':
+			flush()
+			toks = append(toks, token{kind: tokLParen})
+		case ')':
+			flush()
+			toks = append(toks, token{kind: tokRParen})
+		case '|':
+			flush()
+			toks = append(toks, token{kind: tokPipe})
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return toks, nil
+}
+
+func closingQuote(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if s[i] == '\\' && q != '`' {
+			i++
+			continue
+		}
+		if s[i] == q {
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote 去掉引号，Return内容及是否带引号
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'' && s[0] != '`') || s[len(s)-1] != s[0] {
+		return s, false
+	}
+	if s[0] == '"' {
+		if v, err := strconv.Unquote(s); err == nil {
+			return v, true
+		}
+	}
+	return s[1 : len(s)-1], true
+}
+
+// splitPipes 按管道切分，第一段为过滤条件
+func splitPipes(toks []token) ([][]token, error) {
+	segments := [][]token{{}}
+	for _, t := range toks {
+		if t.kind == tokPipe {
+			if len(segments[len(segments)-1]) == 0 {
+				return nil, fmt.Errorf("empty pipe")
+			}
+			segments = append(segments, []token{})
+			continue
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], t)
+	}
+	if len(segments[len(segments)-1]) == 0 {
+		return nil, fmt.Errorf("empty pipe")
+	}
+	return segments, nil
+}
+
+// parser 过滤Expression：or / and (可省略) / not / - / ! / 括号 / field:(...)
+type parser struct {
+	toks []token
+	pos  int
+	now  time.Time
+}
+
+func parseFilter(toks []token, now time.Time) (matcher, error) {
+	p := &parser{toks: toks, now: now}
+	m, err := p.or("")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected ')'")
+	}
+	return m, nil
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) or(field string) (matcher, error) {
+	left, err := p.and(field)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.pos++
+		right, err := p.and(field)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *entry) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+func (p *parser) and(field string) (matcher, error) {
+	var parts []matcher
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokRParen || p.keyword("or") {
+			break
+		}
+		if p.keyword("and") {
+			p.pos++
+			continue
+		}
+		m, err := p.unary(field)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, m)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("missing filter")
+	}
+	return func(e *entry) bool {
+		for _, m := range parts {
+			if !m(e) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (p *parser) unary(field string) (matcher, error) {
+	t := p.peek()
+	if t.kind == tokLParen {
+		return p.group(field)
+	}
+	if t.text == "!" || t.text == "-" || strings.EqualFold(t.text, "not") {
+		p.pos++
+		if p.peek() == nil {
+			return nil, fmt.Errorf("missing filter after %q", t.text)
+		}
+		m, err := p.unary(field)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *entry) bool { return !m(e) }, nil
+	}
+	if len(t.text) > 1 && (t.text[0] == '-' || t.text[0] == '!') {
+		t.text = t.text[1:]
+		m, err := p.unary(field)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *entry) bool { return !m(e) }, nil
+	}
+	return p.term(field)
+}
+
+func (p *parser) group(field string) (matcher, error) {
+	p.pos++ // (
+	m, err := p.or(field)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t == nil || t.kind != tokRParen {
+		return nil, fmt.Errorf("unclosed '('")
+	}
+	p.pos++
+	return m, nil
+}
+
+func (p *parser) term(field string) (matcher, error) {
+	text := p.toks[p.pos].text
+	p.pos++
+	explicit := field != ""
+	if m := fieldPrefix.FindStringSubmatch(text); m != nil {
+		field, text, explicit = m[1], text[len(m[0]):], true
+	}
+	next := p.peek()
+	switch {
+	case text == "" && next != nil && next.kind == tokLParen:
+		return p.group(field)
+	case text == "in" && next != nil && next.kind == tokLParen:
+		return p.in(field)
+	case text == "":
+		return nil, fmt.Errorf("missing value for field %q", field)
+	}
+	if field == "" {
+		field = "_msg"
+	}
+
+	if strings.HasPrefix(text, "{") && (field == "_msg" || field == "_stream") {
+		return parseStreamFilter(text)
+	}
+	if field == "_time" {
+		if m := relativeRange.FindStringSubmatch(text); m != nil {
+			since := p.now.Add(-parseRange(m[1], m[2]))
+			return func(e *entry) bool { return !e.time.Before(since) }, nil
+		}
+		// 绝对Time范围由 start/end 参数决定
+		return func(e *entry) bool { return true }, nil
+	}
+	if text == "*" {
+		if !explicit {
+			return func(e *entry) bool { return true }, nil
+		}
+		return func(e *entry) bool { return e.fields[field] != "" }, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(text, op) {
+			raw, _ := unquote(text[len(op):])
+			limit, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", raw)
+			}
+			return func(e *entry) bool {
+				v, err := strconv.ParseFloat(e.fields[field], 64)
+				if err != nil {
+					return false
+				}
+				switch op {
+				case ">=":
+					return v >= limit
+				case "<=":
+					return v <= limit
+				case ">":
+					return v > limit
+				}
+				return v < limit
+			}, nil
+		}
+	}
+	switch {
+	case strings.HasPrefix(text, "="):
+		value, quoted := unquote(text[1:])
+		if !quoted && strings.HasSuffix(value, "*") {
+			prefix := strings.TrimSuffix(value, "*")
+			return func(e *entry) bool { return strings.HasPrefix(e.fields[field], prefix) }, nil
+		}
+		return func(e *entry) bool { return e.fields[field] == value }, nil
+	case strings.HasPrefix(text, "~"):
+		pattern, _ := unquote(text[1:])
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %v", pattern, err)
+		}
+		return func(e *entry) bool { return re.MatchString(e.fields[field]) }, nil
+	}
+
+	value, quoted := unquote(text)
+	if quoted && value == "" {
+		return func(e *entry) bool { return e.fields[field] == "" }, nil
+	}
+	prefix := !quoted && strings.HasSuffix(value, "*")
+	value = strings.TrimSuffix(value, "*")
+	return func(e *entry) bool { return phraseMatch(e.fields[field], value, prefix) }, nil
+}
+
+// in field:in(a, b, c) 精确匹配任一Value
+func (p *parser) in(field string) (matcher, error) {
+	p.pos++ // (
+	values := make(map[string]bool)
+	for {
+		t := p.peek()
+		if t == nil {
+			return nil, fmt.Errorf("unclosed '('")
+		}
+		p.pos++
+		if t.kind == tokRParen {
+			break
+		}
+		v, _ := unquote(t.text)
+		values[v] = true
+	}
+	if field == "" {
+		field = "_msg"
+	}
+	return func(e *entry) bool { return values[e.fields[field]] }, nil
+}
+
+// phraseMatch 词/短语匹配：短语两端须落在词边界上，prefix 时右侧不要求边界
+func phraseMatch(s, phrase string, prefix bool) bool {
+	if phrase == "" {
+		return true
+	}
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(phrase)
+		left := start == 0 || !isWordByte(phrase[0]) || !isWordByte(s[start-1])
+		right := prefix || end == len(s) || !isWordByte(phrase[len(phrase)-1]) || !isWordByte(s[end])
+		if left && right {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseStreamFilter {label="v", label=~"re", label!="v", label!~"re"}，正则为全匹配
+func parseStreamFilter(text string) (matcher, error) {
+	body := strings.TrimSpace(text[1 : len(text)-1])
+	type cond struct {
+		label, op, value string
+		re               *regexp.Regexp
+	}
+	var conds []cond
+	for _, part := range splitOutsideQuotes(body) {
+		m := streamLabel.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid stream filter %q", text)
+		}
+		c := cond{label: m[1], op: m[2]}
+		c.value, _ = unquote(`"` + m[3] + `"`)
+		if strings.HasSuffix(c.op, "~") {
+			re, err := regexp.Compile("^(?:" + c.value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid stream filter regexp %q: %v", c.value, err)
+			}
+			c.re = re
+		}
+		conds = append(conds, c)
+	}
+	return func(e *entry) bool {
+		for _, c := range conds {
+			v := e.stream[c.label]
+			var ok bool
+			switch c.op {
+			case "=":
+				ok = v == c.value
+			case "!=":
+				ok = v != c.value
+			case "=~":
+				ok = c.re.MatchString(v)
+			case "!~":
+				ok = !c.re.MatchString(v)
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func splitOutsideQuotes(s string) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ',' && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+func parseRange(num, unit string) time.Duration {
+	n, _ := strconv.ParseFloat(num, 64)
+	scale := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+		"y":  365 * 24 * time.Hour,
+	}[unit]
+	return time.Duration(n * float64(scale))
+}
+
+// applyPipe 支持的管道子集：filter / fields / limit / head / sort / stats count()，其余Return Error
+func applyPipe(seg []token, rows []*entry, now time.Time) ([]*entry, error) {
+	name := strings.ToLower(seg[0].text)
+	args := seg[1:]
+	switch name {
+	case "filter", "where":
+		m, err := parseFilter(args, now)
+		if err != nil {
+			return nil, err
+		}
+		kept := rows[:0:0]
+		for _, e := range rows {
+			if m(e) {
+				kept = append(kept, e)
+			}
+		}
+		return kept, nil
+
+	case "fields", "keep":
+		names := words(args)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("fields pipe requires field names")
+		}
+		out := make([]*entry, len(rows))
+		for i, e := range rows {
+			projected := &entry{time: e.time, stream: e.stream, fields: make(map[string]string, len(names))}
+			for _, n := range names {
+				if v, ok := e.fields[n]; ok {
+					projected.fields[n] = v
+				}
+			}
+			out[i] = projected
+		}
+		return out, nil
+
+	case "limit", "head":
+		n := 10
+		if len(args) > 0 {
+			v, err := strconv.Atoi(args[0].text)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid limit %q", args[0].text)
+			}
+			n = v
+		}
+		if len(rows) > n {
+			rows = rows[:n]
+		}
+		return rows, nil
+
+	case "sort":
+		desc := len(args) > 0 && strings.EqualFold(args[len(args)-1].text, "desc")
+		var keys []string
+		for _, w := range words(args) {
+			if !strings.EqualFold(w, "by") && !strings.EqualFold(w, "desc") && !strings.EqualFold(w, "asc") {
+				keys = append(keys, w)
+			}
+		}
+		sorted := append([]*entry(nil), rows...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for _, k := range keys {
+				if c := compareValues(sorted[i].fields[k], sorted[j].fields[k]); c != 0 {
+					return (c < 0) != desc
+				}
+			}
+			return false
+		})
+		return sorted, nil
+
+	case "stats":
+		return statsCount(args, rows)
+	}
+	return nil, fmt.Errorf("unsupported pipe %q", name)
+}
+
+// statsCount stats [by (f1, f2)] count() [as name]
+func statsCount(args []token, rows []*entry) ([]*entry, error) {
+	var by []string
+	i := 0
+	if i < len(args) && strings.EqualFold(args[i].text, "by") {
+		i++
+		if i >= len(args) || args[i].kind != tokLParen {
+			return nil, fmt.Errorf("stats by requires (fields)")
+		}
+		for i++; i < len(args) && args[i].kind != tokRParen; i++ {
+			by = append(by, args[i].text)
+		}
+		i++
+	}
+	if i+1 >= len(args) || !strings.EqualFold(args[i].text, "count") || args[i+1].kind != tokLParen {
+		return nil, fmt.Errorf("only stats count() is supported")
+	}
+	i += 2
+	for i < len(args) && args[i].kind != tokRParen {
+		i++
+	}
+	i++
+	alias := "count(*)"
+	if i < len(args) && strings.EqualFold(args[i].text, "as") {
+		i++
+	}
+	if i < len(args) {
+		alias = args[i].text
+		i++
+	}
+	if i < len(args) {
+		return nil, fmt.Errorf("only a single stats count() is supported")
+	}
+
+	var order []string
+	groups := make(map[string]*entry)
+	counts := make(map[string]int)
+	for _, e := range rows {
+		values := make([]string, len(by))
+		for j, f := range by {
+			values[j] = e.fields[f]
+		}
+		key := strings.Join(values, "\x00")
+		if _, ok := groups[key]; !ok {
+			g := &entry{fields: make(map[string]string, len(by)+1)}
+			for j, f := range by {
+				g.fields[f] = values[j]
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	if len(by) == 0 && len(order) == 0 {
+		order = append(order, "")
+		groups[""] = &entry{fields: map[string]string{}}
+	}
+	out := make([]*entry, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		g.fields[alias] = strconv.Itoa(counts[key])
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+func words(toks []token) []string {
+	var result []string
+	for _, t := range toks {
+		if t.kind == tokWord {
+			v, _ := unquote(t.text)
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func compareValues(a, b string) int {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX == nil && errY == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,309 @@
+// Package vlfake 内存版 VictoriaLogs，供 CI 运行Rule测试用例
+//
+// 只实现 vsentry 用到的接口：/insert/jsonline、/select/logsql/query、
+// /select/logsql/field_names、/internal/force_flush。LogSQL 只支持常用子集
+// (词/短语/前缀、field:=、field:~、数值比较、field:in()、stream 过滤、and/or/not/括号，
+// 管道 filter/fields/limit/head/sort/stats count())，按 AccountID/ProjectID 请求头区分租户，不支持的语法Return 400，
+// 因此用例在这里通过不代表真实 VictoriaLogs 上一定通过，反之亦然。
+package vlfake
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// entry 一条已写入的Log，嵌套字段展开为 a.b.c
+type entry struct {
+	tenant string // AccountID:ProjectID
+	time   time.Time
+	stream map[string]string
+	fields map[string]string
+}
+
+// Server 内存Log存储，实现 http.Handler
+type Server struct {
+	mu      sync.RWMutex
+	entries []*entry
+}
+
+// New Create空的 Server
+func New() *Server {
+	return &Server{}
+}
+
+// Reset 清空已写入的Log
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.entries = nil
+	s.mu.Unlock()
+}
+
+// Len 已写入的Log条数
+func (s *Server) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/insert/jsonline":
+		s.insert(w, r)
+	case "/select/logsql/query":
+		s.query(w, r)
+	case "/select/logsql/field_names":
+		s.fieldNames(w, r)
+	case "/internal/force_flush", "/health":
+		w.Write([]byte("OK"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) insert(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	timeField := firstOr(params.Get("_time_field"), "_time")
+	msgFields := strings.Split(firstOr(params.Get("_msg_field"), "_msg"), ",")
+	streamFields := splitList(params.Get("_stream_fields"))
+
+	var batch []*entry
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			http.Error(w, fmt.Sprintf("cannot parse line %d: %v", line, err), http.StatusBadRequest)
+			return
+		}
+
+		e := &entry{tenant: tenant(r), time: time.Now().UTC(), stream: make(map[string]string), fields: make(map[string]string)}
+		flatten(doc, "", e.fields)
+		if ts, ok := e.fields[timeField]; ok {
+			if t, err := parseTime(ts); err == nil {
+				e.time = t.UTC()
+			}
+			delete(e.fields, timeField)
+		}
+		for _, f := range msgFields {
+			if v, ok := e.fields[strings.TrimSpace(f)]; ok {
+				e.fields["_msg"] = v
+				delete(e.fields, strings.TrimSpace(f))
+				break
+			}
+		}
+		labels := make([]string, 0, len(streamFields))
+		for _, f := range streamFields {
+			if v, ok := e.fields[f]; ok {
+				e.stream[f] = v
+				labels = append(labels, fmt.Sprintf("%s=%q", f, v))
+			}
+		}
+		sort.Strings(labels)
+		e.fields["_stream"] = "{" + strings.Join(labels, ",") + "}"
+		e.fields["_time"] = e.time.Format(time.RFC3339Nano)
+		batch = append(batch, e)
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.entries = append(s.entries, batch...)
+	s.mu.Unlock()
+}
+
+// selectRows 按 start/end、extra_filters、extra_stream_filters 和Query过滤并Execute管道
+func (s *Server) selectRows(r *http.Request) ([]*entry, error) {
+	r.ParseForm()
+	now := time.Now().UTC()
+	start, end := time.Time{}, time.Unix(1<<62, 0)
+	if v := r.Form.Get("start"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse start: %v", err)
+		}
+		start = t
+	}
+	if v := r.Form.Get("end"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse end: %v", err)
+		}
+		end = t
+	}
+
+	toks, err := tokenize(r.Form.Get("query"))
+	if err != nil {
+		return nil, err
+	}
+	segments, err := splitPipes(toks)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]matcher, 0, 3)
+	m, err := parseFilter(segments[0], now)
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, m)
+	for _, extra := range []string{r.Form.Get("extra_filters"), r.Form.Get("extra_stream_filters")} {
+		if strings.TrimSpace(extra) == "" {
+			continue
+		}
+		extraToks, err := tokenize(extra)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parseFilter(extraToks, now)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, m)
+	}
+
+	s.mu.RLock()
+	var rows []*entry
+	t := tenant(r)
+	for _, e := range s.entries {
+		if e.tenant != t || e.time.Before(start) || e.time.After(end) {
+			continue
+		}
+		matched := true
+		for _, f := range filters {
+			if !f(e) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, e)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, seg := range segments[1:] {
+		if rows, err = applyPipe(seg, rows, now); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.selectRows(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n, err := strconv.Atoi(r.Form.Get("limit")); err == nil && n > 0 && len(rows) > n {
+		rows = rows[:n]
+	}
+
+	w.Header().Set("Content-Type", "application/stream+json")
+	enc := json.NewEncoder(w)
+	for _, e := range rows {
+		enc.Encode(e.fields)
+	}
+}
+
+func (s *Server) fieldNames(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.selectRows(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hits := make(map[string]int)
+	for _, e := range rows {
+		for k := range e.fields {
+			hits[k]++
+		}
+	}
+	type value struct {
+		Value string `json:"value"`
+		Hits  int    `json:"hits"`
+	}
+	values := make([]value, 0, len(hits))
+	for k, n := range hits {
+		values = append(values, value{Value: k, Hits: n})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Value < values[j].Value })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"values": values})
+}
+
+// flatten 嵌套对象展开为 a.b.c，数组和其他Value按 JSON 文本保存
+func flatten(data map[string]interface{}, prefix string, out map[string]string) {
+	for k, v := range data {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch child := v.(type) {
+		case map[string]interface{}:
+			flatten(child, key, out)
+		case string:
+			out[key] = child
+		case json.Number:
+			out[key] = child.String()
+		case nil:
+		default:
+			raw, _ := json.Marshal(child)
+			out[key] = string(raw)
+		}
+	}
+}
+
+// parseTime 支持 RFC3339 和 Unix 秒/毫秒/纳秒Time戳
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unsupported time %q", s)
+	}
+	switch {
+	case n > 1e17:
+		return time.Unix(0, int64(n)), nil
+	case n > 1e11:
+		return time.UnixMilli(int64(n)), nil
+	}
+	return time.Unix(0, int64(n*float64(time.Second))), nil
+}
+
+// tenant 请求的租户，未带请求头时为Default租户 0:0
+func tenant(r *http.Request) string {
+	return firstOr(r.Header.Get("AccountID"), "0") + ":" + firstOr(r.Header.Get("ProjectID"), "0")
+}
+
+func firstOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,92 @@
+package vlfake
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	now := time.Now().UTC()
+	lines := []string{
+		`{"time":"` + now.Add(-time.Minute).Format(time.RFC3339Nano) + `","raw_data":"failed login for admin","app":"sshd","user":{"name":"admin"},"status":401,"src":"10.0.0.1"}`,
+		`{"time":"` + now.Add(-2*time.Minute).Format(time.RFC3339Nano) + `","raw_data":"accepted login for bob","app":"sshd","user":{"name":"bob"},"status":200,"src":"10.0.0.2"}`,
+		`{"time":"` + now.Add(-3*time.Hour).Format(time.RFC3339Nano) + `","raw_data":"GET /index.html","app":"nginx","status":500,"src":"10.0.0.1"}`,
+	}
+	params := url.Values{"_stream_fields": {"app"}, "_msg_field": {"raw_data"}, "_time_field": {"time"}}
+	resp, err := http.Post(srv.URL+"/insert/jsonline?"+params.Encode(), "application/stream+json", strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cases := []struct {
+		query string
+		rows  int
+		want  string // 结果中应包含的片段
+	}{
+		{query: `*`, rows: 3},
+		{query: `login`, rows: 2},
+		{query: `"failed login"`, rows: 1},
+		{query: `logi*`, rows: 2},
+		{query: `_time:5m`, rows: 2},
+		{query: `user.name:="admin"`, rows: 1},
+		{query: `user.name:~"^(admin|bob)$"`, rows: 2},
+		{query: `status:>=400`, rows: 2},
+		{query: `src:in("10.0.0.1", "10.0.0.9")`, rows: 2},
+		{query: `{app="sshd"}`, rows: 2},
+		{query: `{app=~"ng.*"}`, rows: 1},
+		{query: `login AND NOT bob`, rows: 1},
+		{query: `-login`, rows: 1},
+		{query: `(app:sshd OR app:nginx) status:200`, rows: 1},
+		{query: `* | filter status:>=400 | fields user.name | limit 1`, rows: 1},
+		{query: `* | stats by (src) count() hits`, rows: 2, want: `"hits":"2"`},
+	}
+	for _, c := range cases {
+		code, body := query(t, srv.URL, c.query)
+		if code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", c.query, code, body)
+			continue
+		}
+		if rows := countLines(body); rows != c.rows {
+			t.Errorf("%s: %d rows, want %d\n%s", c.query, rows, c.rows, body)
+		}
+		if c.want != "" && !strings.Contains(body, c.want) {
+			t.Errorf("%s: result does not contain %s\n%s", c.query, c.want, body)
+		}
+	}
+
+	for _, q := range []string{`(login`, `login | unpack_json`, `"unterminated`} {
+		if code, _ := query(t, srv.URL, q); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, code)
+		}
+	}
+}
+
+func query(t *testing.T, base, q string) (int, string) {
+	t.Helper()
+	resp, err := http.PostForm(base+"/select/logsql/query", url.Values{"query": {q}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func countLines(s string) int {
+	n := 0
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,191 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/pdf"
+)
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// ContentTypes 各格式的 HTTP Content-Type
+var ContentTypes = map[string]string{
+	model.ReportMarkdown: "text/markdown; charset=utf-8",
+	model.ReportHTML:     "text/html; charset=utf-8",
+	model.ReportPDF:      "application/pdf",
+}
+
+// TemplateFormat 渲染某种输出格式使用的模板格式 (pdf 由 md 模板生成)
+func TemplateFormat(format string) string {
+	if format == model.ReportPDF {
+		return model.ReportMarkdown
+	}
+	return format
+}
+
+// Builtin 内置模板，format 为 md / html
+func Builtin(format string) string {
+	body, _ := builtin.ReadFile("templates/incident." + TemplateFormat(format) + ".tmpl")
+	return string(body)
+}
+
+var funcs = map[string]interface{}{
+	"time": func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			if v.IsZero() {
+				return "-"
+			}
+			return v.UTC().Format("2006-01-02 15:04:05 UTC")
+		case *time.Time:
+			if v == nil || v.IsZero() {
+				return "-"
+			}
+			return v.UTC().Format("2006-01-02 15:04:05 UTC")
+		}
+		return "-"
+	},
+	"truncate": truncate,
+	// cell Markdown 表格单元格：转义竖线并去掉换行
+	"cell": func(s string) string {
+		s = strings.ReplaceAll(s, "|", `\|`)
+		return strings.Join(strings.Fields(s), " ")
+	},
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+	"upper": strings.ToUpper,
+}
+
+// Validate 校验模板能否解析并在空数据上执行
+func Validate(format, body string) error {
+	_, err := Render(format, body, &Data{})
+	return err
+}
+
+// Render 用模板渲染报告；format 为 pdf 时 body 为 md 模板
+func Render(format, body string, data *Data) ([]byte, error) {
+	var out bytes.Buffer
+	switch format {
+	case model.ReportMarkdown, model.ReportPDF:
+		tmpl, err := template.New("report").Funcs(funcs).Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		if format == model.ReportPDF {
+			return MarkdownPDF(fmt.Sprintf("Incident #%d %s", data.Incident.ID, data.Incident.Name), out.String()), nil
+		}
+	case model.ReportHTML:
+		tmpl, err := htmltemplate.New("report").Funcs(funcs).Parse(body)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported report format %q", format)
+	}
+	return out.Bytes(), nil
+}
+
+var (
+	tableSeparator = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+	inlineMarkup   = strings.NewReplacer("**", "", "__", "", "`", "", `\|`, "|")
+	orderedItem    = regexp.MustCompile(`^\d+\.\s`)
+)
+
+// MarkdownPDF 按行排版 Markdown：标题、列表、引用、表格 (逐行输出)、代码块和分隔线，其他行作为段落
+func MarkdownPDF(title, markdown string) []byte {
+	doc := pdf.New(title)
+	inCode := false
+	var code []string
+	header := false
+	for _, raw := range strings.Split(markdown, "\n") {
+		line := strings.TrimRight(raw, " \r")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				doc.Code(strings.Join(code, "\n"))
+				code = code[:0]
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			header = false
+			doc.Space(5)
+		case strings.HasPrefix(trimmed, "#"):
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+			doc.Heading(level, inlineMarkup.Replace(strings.TrimSpace(trimmed[level:])))
+		case trimmed == "---" || trimmed == "***":
+			doc.Line()
+		case tableSeparator.MatchString(trimmed) && strings.Contains(trimmed, "-"):
+			// 表头分隔行
+		case strings.HasPrefix(trimmed, "|"):
+			cells := splitRow(trimmed)
+			text := inlineMarkup.Replace(strings.Join(cells, "  |  "))
+			if !header {
+				doc.Bold(text)
+				header = true
+			} else {
+				doc.Text(text)
+			}
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			depth := (len(line) - len(strings.TrimLeft(line, " "))) / 2
+			doc.Bullet(depth, inlineMarkup.Replace(trimmed[2:]))
+		case orderedItem.MatchString(trimmed):
+			doc.Bullet(0, inlineMarkup.Replace(trimmed))
+		case strings.HasPrefix(trimmed, ">"):
+			doc.Quote(inlineMarkup.Replace(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))))
+		default:
+			doc.Text(inlineMarkup.Replace(trimmed))
+		}
+	}
+	if inCode && len(code) > 0 {
+		doc.Code(strings.Join(code, "\n"))
+	}
+	return doc.Bytes()
+}
+
+// splitRow 拆分表格行，保留转义的竖线
+func splitRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(row); i++ {
+		if row[i] == '\\' && i+1 < len(row) && row[i+1] == '|' {
+			cur.WriteByte('|')
+			i++
+			continue
+		}
+		if row[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(row[i])
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
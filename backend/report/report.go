@@ -0,0 +1,193 @@
+// Package report 根据 Incident 处置记录生成报告 (Markdown / HTML / PDF)
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Event 报告Timeline的一项：Alert、评论或处置记录
+type Event struct {
+	Time    time.Time
+	Kind    string // alert / comment / activity
+	Actor   string
+	Summary string
+}
+
+// Step Playbook 节点的Execute结果
+type Step struct {
+	Node   string
+	Status string
+	Output string
+	Error  string
+}
+
+// PlaybookRun 一次 Playbook Execute
+type PlaybookRun struct {
+	model.PlaybookExecution
+	Name  string
+	Steps []Step
+}
+
+// Data 模板可用的全部数据
+type Data struct {
+	Incident      model.Incident
+	Assignee      string
+	Duration      string // 创建到 Resolve 的时长，未 Resolve 为空
+	Alerts        []model.Alert
+	AlertsOmitted int
+	Events        []Event
+	Comments      []model.IncidentComment
+	Observables   []model.Observable
+	Links         []model.IncidentLinkRef
+	Playbooks     []PlaybookRun
+	Queries       []model.IncidentActivity
+	GeneratedAt   time.Time
+	GeneratedBy   string
+}
+
+// maxAlerts 报告Medium列出的Alert上限
+func maxAlerts() int {
+	if n := viper.GetInt("report.max_alerts"); n > 0 {
+		return n
+	}
+	return 200
+}
+
+// Build 收集 Incident 报告数据
+func Build(db *gorm.DB, incidentID uint, generatedBy string) (*Data, error) {
+	var incident model.Incident
+	if err := db.Preload("Rule").First(&incident, incidentID).Error; err != nil {
+		return nil, err
+	}
+	data := &Data{Incident: incident, GeneratedAt: time.Now().UTC(), GeneratedBy: generatedBy}
+	if incident.Assignee != 0 {
+		var users []model.User
+		db.Select("id", "user_name").Where("id = ?", incident.Assignee).Find(&users)
+		if len(users) > 0 {
+			data.Assignee = users[0].UserName
+		}
+	}
+	if incident.ResolvedAt != nil {
+		data.Duration = incident.ResolvedAt.Sub(incident.CreatedAt).Round(time.Minute).String()
+	}
+
+	var total int64
+	db.Model(&model.Alert{}).Where("incident_id = ?", incident.ID).Count(&total)
+	db.Where("incident_id = ?", incident.ID).Order("id asc").Limit(maxAlerts()).Find(&data.Alerts)
+	data.AlertsOmitted = int(total) - len(data.Alerts)
+
+	var timeline []model.IncidentActivity
+	db.Where("incident_id = ?", incident.ID).Order("id asc").Find(&timeline)
+	db.Where("incident_id = ?", incident.ID).Order("id asc").Find(&data.Comments)
+	db.Where("incident_id = ?", incident.ID).Order("count desc, id asc").Find(&data.Observables)
+	data.Links = database.IncidentLinks(db, incident.ID)
+
+	for _, a := range data.Alerts {
+		data.Events = append(data.Events, Event{Time: a.CreatedAt, Kind: "alert", Summary: alertSummary(a)})
+	}
+	for _, c := range data.Comments {
+		data.Events = append(data.Events, Event{Time: c.CreatedAt, Kind: "comment", Actor: c.Author, Summary: c.Body})
+	}
+	for _, act := range timeline {
+		if act.Type == model.ActivityComment {
+			continue
+		}
+		if act.Type == model.ActivityQuery {
+			data.Queries = append(data.Queries, act)
+		}
+		data.Events = append(data.Events, Event{Time: act.CreatedAt, Kind: "activity", Actor: act.Actor, Summary: activitySummary(act)})
+	}
+	sort.SliceStable(data.Events, func(i, j int) bool { return data.Events[i].Time.Before(data.Events[j].Time) })
+
+	data.Playbooks = playbookRuns(db, incident.ID)
+	return data, nil
+}
+
+func playbookRuns(db *gorm.DB, incidentID uint) []PlaybookRun {
+	var executions []model.PlaybookExecution
+	db.Where("trigger_context_id = ?", incidentID).Order("id asc").Find(&executions)
+	if len(executions) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(executions))
+	for _, e := range executions {
+		ids = append(ids, e.PlaybookID)
+	}
+	var playbooks []model.Playbook
+	db.Select("id", "name").Where("id IN ?", ids).Find(&playbooks)
+	names := make(map[uint]string, len(playbooks))
+	for _, p := range playbooks {
+		names[p.ID] = p.Name
+	}
+
+	runs := make([]PlaybookRun, 0, len(executions))
+	for _, e := range executions {
+		run := PlaybookRun{PlaybookExecution: e, Name: names[e.PlaybookID]}
+		if run.Name == "" {
+			run.Name = fmt.Sprintf("playbook #%d", e.PlaybookID)
+		}
+		var logs map[string]struct {
+			Status string      `json:"status"`
+			Output interface{} `json:"output"`
+			Error  string      `json:"error"`
+		}
+		json.Unmarshal(e.Logs, &logs)
+		for node, l := range logs {
+			step := Step{Node: node, Status: l.Status, Error: l.Error}
+			if l.Output != nil {
+				out, _ := json.MarshalIndent(l.Output, "", "  ")
+				step.Output = truncate(string(out), 2000)
+			}
+			run.Steps = append(run.Steps, step)
+		}
+		sort.Slice(run.Steps, func(i, j int) bool { return run.Steps[i].Node < run.Steps[j].Node })
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// alertSummary Alert的一行摘要：优先取原始Log字段
+func alertSummary(a model.Alert) string {
+	var event map[string]interface{}
+	if json.Unmarshal([]byte(a.Content), &event) == nil {
+		for _, key := range []string{"message", "_msg", "raw_data"} {
+			if v, ok := event[key].(string); ok && v != "" {
+				return truncate(v, 200)
+			}
+		}
+	}
+	return truncate(a.Content, 200)
+}
+
+// activitySummary 处置记录的可读描述
+func activitySummary(a model.IncidentActivity) string {
+	var parts []string
+	parts = append(parts, strings.ReplaceAll(a.Type, "_", " "))
+	switch {
+	case a.From != "" && a.To != "":
+		parts = append(parts, a.From+" -> "+a.To)
+	case a.To != "":
+		parts = append(parts, a.To)
+	}
+	if a.Detail != "" {
+		parts = append(parts, "("+a.Detail+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
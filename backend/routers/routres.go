@@ -140,9 +140,150 @@ func setupAPIRoutes(r *gin.RouterGroup) {
 		rules.GET("/list", controller.ListRules)
 		rules.POST("/add", controller.AddRule)
 		rules.POST("/update", controller.UpdateRule)
+		rules.POST("/validate", controller.ValidateRule)
+		rules.POST("/tests/run", controller.RunAllRuleTests)
+		rules.POST("/:id/tests/run", controller.RunRuleTests)
 		rules.POST("/delete", controller.DeleteRule)
 		rules.POST("/enable", controller.EnableRule)
 		rules.POST("/disable", controller.DisableRule)
+		rules.GET("/health", controller.GetRuleHealth)
+		rules.GET("/executions", controller.ListRuleExecutions)
+		rules.GET("/stream/stats", controller.GetStreamRuleStats)
+		rules.GET("/baseline", controller.GetAnomalyBaseline)
+		rules.POST("/baseline/reset", controller.ResetAnomalyBaseline)
+	}
+	// incident SLA
+	sla := r.Group("/sla", middleware.AuthMiddleware())
+	{
+		sla.GET("/policies", controller.ListSLAPolicies)
+		sla.POST("/policies", controller.AddSLAPolicy)
+		sla.PUT("/policies/:id", controller.UpdateSLAPolicy)
+		sla.DELETE("/policies/:id", controller.DeleteSLAPolicy)
+		sla.GET("/breaches", controller.ListSLABreaches)
+		sla.GET("/stats", controller.GetSLAStats)
+	}
+	// risk-based alerting
+	risk := r.Group("/risk", middleware.AuthMiddleware())
+	{
+		risk.GET("/entities", controller.ListRiskyEntities)
+		risk.GET("/entities/:id", controller.GetRiskEntity)
+		risk.GET("/policies", controller.ListRiskPolicies)
+		risk.POST("/policies", controller.AddRiskPolicy)
+		risk.PUT("/policies/:id", controller.UpdateRiskPolicy)
+		risk.DELETE("/policies/:id", controller.DeleteRiskPolicy)
+	}
+	// maintenance windows
+	maintenance := r.Group("/maintenance", middleware.AuthMiddleware())
+	{
+		maintenance.GET("", controller.ListMaintenanceWindows)
+		maintenance.POST("", controller.AddMaintenanceWindow)
+		maintenance.GET("/active", controller.ListMutedRules)
+		maintenance.PUT("/:id", controller.UpdateMaintenanceWindow)
+		maintenance.DELETE("/:id", controller.DeleteMaintenanceWindow)
+	}
+	// rule packs (detection as code)
+	rulePacks := r.Group("/rulepacks", middleware.AuthMiddleware())
+	{
+		rulePacks.GET("", controller.ListRulePacks)
+		rulePacks.POST("", controller.AddRulePack)
+		rulePacks.GET("/export", controller.ExportRules)
+		rulePacks.PUT("/:id", controller.UpdateRulePack)
+		rulePacks.DELETE("/:id", controller.DeleteRulePack)
+		rulePacks.POST("/:id/sync", controller.SyncRulePack)
+	}
+	// backtrace jobs
+	backtrace := r.Group("/backtrace", middleware.AuthMiddleware())
+	{
+		backtrace.GET("/jobs", controller.ListBacktraceJobs)
+		backtrace.POST("/jobs", controller.CreateBacktraceJob)
+		backtrace.GET("/jobs/:id", controller.GetBacktraceJob)
+		backtrace.POST("/jobs/:id/cancel", controller.CancelBacktraceJob)
+		backtrace.POST("/jobs/:id/pause", controller.PauseBacktraceJob)
+		backtrace.POST("/jobs/:id/resume", controller.ResumeBacktraceJob)
+	}
+	// MITRE ATT&CK coverage
+	attackGroup := r.Group("/attack", middleware.AuthMiddleware())
+	{
+		attackGroup.GET("/tactics", controller.ListAttackTactics)
+		attackGroup.GET("/techniques", controller.ListAttackTechniques)
+		attackGroup.GET("/coverage", controller.GetAttackCoverage)
+		attackGroup.GET("/navigator", controller.ExportAttackNavigator)
+	}
+	// threat intelligence
+	intelGroup := r.Group("/intel", middleware.AuthMiddleware())
+	{
+		intelGroup.GET("/indicators", controller.ListIndicators)
+		intelGroup.POST("/indicators", controller.AddIndicator)
+		intelGroup.DELETE("/indicators/:id", controller.DeleteIndicator)
+		intelGroup.POST("/import", controller.ImportIndicators)
+		intelGroup.GET("/lookup", controller.LookupIndicator)
+		intelGroup.GET("/stats", controller.GetIntelStats)
+		intelGroup.GET("/feeds", controller.ListIndicatorFeeds)
+		intelGroup.POST("/feeds", controller.AddIndicatorFeed)
+		intelGroup.PUT("/feeds/:id", controller.UpdateIndicatorFeed)
+		intelGroup.DELETE("/feeds/:id", controller.DeleteIndicatorFeed)
+		intelGroup.POST("/feeds/:id/sync", controller.SyncIndicatorFeed)
+	}
+	// incident report templates
+	reports := r.Group("/reports", middleware.AuthMiddleware())
+	{
+		reports.GET("/templates", controller.ListReportTemplates)
+		reports.GET("/templates/builtin", controller.GetBuiltinReportTemplate)
+		reports.POST("/templates", controller.AddReportTemplate)
+		reports.PUT("/templates/:id", controller.UpdateReportTemplate)
+		reports.DELETE("/templates/:id", controller.DeleteReportTemplate)
+	}
+	// live event stream (SSE / WebSocket), JWT may be passed as ?token= (kept out of the access log by StreamTokenMiddleware)
+	streamGroup := r.Group("/stream", middleware.StreamAuthMiddleware())
+	{
+		streamGroup.GET("/events", controller.StreamEvents)
+		streamGroup.GET("/ws", controller.StreamWebSocket)
+	}
+	// notification channels, routing policies and delivery log
+	notifications := r.Group("/notifications", middleware.AuthMiddleware())
+	{
+		notifications.GET("/channels", controller.ListNotifyChannels)
+		notifications.POST("/channels", controller.AddNotifyChannel)
+		notifications.PUT("/channels/:id", controller.UpdateNotifyChannel)
+		notifications.DELETE("/channels/:id", controller.DeleteNotifyChannel)
+		notifications.POST("/channels/:id/test", controller.TestNotifyChannel)
+		notifications.GET("/policies", controller.ListNotifyPolicies)
+		notifications.POST("/policies", controller.AddNotifyPolicy)
+		notifications.PUT("/policies/:id", controller.UpdateNotifyPolicy)
+		notifications.DELETE("/policies/:id", controller.DeleteNotifyPolicy)
+		notifications.GET("/deliveries", controller.ListNotifyDeliveries)
+		notifications.POST("/deliveries/:id/retry", controller.RetryNotifyDelivery)
+	}
+	// false-positive tuning proposals
+	tuning := r.Group("/tuning", middleware.AuthMiddleware())
+	{
+		tuning.GET("/proposals", controller.ListSuppressionProposals)
+		tuning.POST("/analyze", controller.AnalyzeFalsePositives)
+		tuning.POST("/proposals/:id/accept", controller.AcceptSuppressionProposal)
+		tuning.POST("/proposals/:id/dismiss", controller.DismissSuppressionProposal)
+	}
+	// asset and identity inventory
+	assets := r.Group("/assets", middleware.AuthMiddleware())
+	{
+		assets.GET("", controller.ListAssets)
+		assets.POST("", controller.AddAsset)
+		assets.POST("/sync", controller.SyncAssets)
+		assets.GET("/:id", controller.GetAsset)
+		assets.PUT("/:id", controller.UpdateAsset)
+		assets.DELETE("/:id", controller.DeleteAsset)
+	}
+	// entity pages: cross-source timeline, peers and activity for a host, user or IP
+	entities := r.Group("/entities", middleware.AuthMiddleware())
+	{
+		entities.GET("/:type", controller.GetEntity)
+		entities.GET("/:type/timeline", controller.GetEntityTimeline)
+	}
+	// observables extracted from alerts
+	observables := r.Group("/observables", middleware.AuthMiddleware())
+	{
+		observables.GET("", controller.ListObservables)
+		observables.GET("/incidents", controller.SearchObservableIncidents)
+		observables.PUT("/tag", controller.TagObservable)
 	}
 	// alerts
 	alerts := r.Group("/alerts", middleware.AuthMiddleware())
@@ -151,6 +292,7 @@ func setupAPIRoutes(r *gin.RouterGroup) {
 		alerts.POST("/acknowledge", controller.Acknowledge)
 		alerts.POST("/resolve", controller.Resolve)
 		alerts.POST("/assign", controller.Assign)
+		alerts.POST("/bulk", controller.BulkTriageAlerts)
 	}
 
 	// incidents
@@ -160,12 +302,22 @@ func setupAPIRoutes(r *gin.RouterGroup) {
 		incidentGroup.GET("/detail", controller.GetIncidentDetail)
 		incidentGroup.POST("/acknowledge", controller.AcknowledgeIncident)
 		incidentGroup.POST("/resolve", controller.ResolveIncident)
+		incidentGroup.POST("/update", controller.UpdateIncident)
+		incidentGroup.GET("/timeline", controller.GetIncidentTimeline)
+		incidentGroup.GET("/comments", controller.ListIncidentComments)
+		incidentGroup.POST("/comments", controller.AddIncidentComment)
+		incidentGroup.POST("/merge", controller.MergeIncidents)
+		incidentGroup.POST("/split", controller.SplitIncident)
+		incidentGroup.POST("/links", controller.CreateIncidentLink)
+		incidentGroup.DELETE("/links/:id", controller.DeleteIncidentLink)
+		incidentGroup.GET("/:id/report", controller.GetIncidentReport)
 	}
 
 	// investigation
 	investigationGroup := r.Group("/investigation", middleware.AuthMiddleware())
 	{
 		investigationGroup.POST("/execute", controller.ExecuteInvestigation) // 核心ExecuteEngine
+		investigationGroup.POST("/graph", controller.BuildInvestigationGraph) // 关联图谱，可增量展开
 	}
 	// forensics
 	forensicsGroup := r.Group("/forensics", middleware.AuthMiddleware())
@@ -0,0 +1,85 @@
+package rulepack
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/laenix/vsentry/crd"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/scheduler"
+	"sigs.k8s.io/yaml"
+)
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FromModel 将 model.Rule 转换为声明式Rule；受管Rule沿用Rule包内的 metadata.name
+func FromModel(r model.Rule) crd.Rule {
+	name := r.ManagedKey
+	if name == "" {
+		name = slugPattern.ReplaceAllString(strings.ToLower(r.Name), "-")
+		name = strings.Trim(name, "-")
+		if name == "" {
+			name = "rule"
+		}
+	}
+
+	enabled := r.Enabled
+	spec := crd.RuleSpec{
+		Name:        r.Name,
+		Description: r.Description,
+		Type:        r.Type,
+		Query:       r.Query,
+		Interval:    r.Interval,
+		Severity:    r.Severity,
+		Enabled:     &enabled,
+		Tags:        r.Tags,
+		FollowUps:   r.FollowUps,
+		Tests:       r.Tests,
+	}
+	if !strings.HasPrefix(r.Source, "rulepack:") {
+		spec.Source = r.Source
+	}
+	if r.EnableBacktrace || r.BacktraceStart != "" || r.BacktraceCron != "" {
+		spec.Backtrace = &crd.RuleBacktrace{Enabled: r.EnableBacktrace, Cron: r.BacktraceCron, Start: r.BacktraceStart}
+	}
+	if r.Risk.Score > 0 {
+		risk := r.Risk
+		spec.Risk = &risk
+	}
+	if r.Type == scheduler.RuleTypeAnomaly {
+		anomaly := r.Anomaly.WithDefaults()
+		spec.Anomaly = &anomaly
+	}
+
+	return crd.Rule{
+		APIVersion: APIVersion,
+		Kind:       KindRule,
+		Metadata:   crd.Metadata{Name: name},
+		Spec:       spec,
+	}
+}
+
+// Export 将Rule导出为多文档 YAML，重名的 metadata.name 追加Rule ID 区分
+func Export(rules []model.Rule) ([]byte, error) {
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		def := FromModel(r)
+		if seen[def.Metadata.Name] {
+			def.Metadata.Name = fmt.Sprintf("%s-%d", def.Metadata.Name, r.ID)
+		}
+		seen[def.Metadata.Name] = true
+
+		data, err := yaml.Marshal(def)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
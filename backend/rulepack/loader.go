@@ -0,0 +1,241 @@
+package rulepack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/laenix/vsentry/crd"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	APIVersion = "vsentry.io/v1"
+	KindRule   = "Rule"
+)
+
+var docSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// sourceFile Rule包Medium的一个 YAML File
+type sourceFile struct {
+	Path string
+	Data []byte
+}
+
+// definition 从File解析出的单条Rule
+type definition struct {
+	File string
+	Rule crd.Rule
+}
+
+// load 读取Rule包的全部 YAML File，Return解析结果和 git revision（非 git 目录为空）
+func load(pack *model.RulePack) ([]definition, string, error) {
+	var (
+		files    []sourceFile
+		revision string
+		err      error
+	)
+	dir, err := CheckPath(pack)
+	if err != nil {
+		return nil, "", err
+	}
+	if pack.Ref != "" {
+		files, revision, err = readGitRef(dir, pack.Ref, pack.Subdir)
+	} else {
+		files, err = readDir(filepath.Join(dir, pack.Subdir))
+		if err == nil && isGitRepo(dir) {
+			revision, _ = git(dir, "rev-parse", "HEAD")
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		defs []definition
+		errs []string
+	)
+	for _, f := range files {
+		rules, err := Parse(f.Data)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.Path, err))
+			continue
+		}
+		for _, r := range rules {
+			defs = append(defs, definition{File: f.Path, Rule: r})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, revision, fmt.Errorf("invalid rule files: %s", strings.Join(errs, "; "))
+	}
+	return defs, revision, nil
+}
+
+// CheckPath 规则包目录必须位于 rulepack.root 之内 (解析符号链接后)，Subdir 不能跳出规则包目录，
+// 未配置 rulepack.root 时不允许添加规则包。Return解析后的规则包目录
+func CheckPath(pack *model.RulePack) (string, error) {
+	root := viper.GetString("rulepack.root")
+	if root == "" {
+		return "", fmt.Errorf("rule packs are disabled, set rulepack.root to allow them")
+	}
+	root, err := resolve(root)
+	if err != nil {
+		return "", fmt.Errorf("rulepack.root: %v", err)
+	}
+	dir := pack.Path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	if dir, err = resolve(dir); err != nil {
+		return "", err
+	}
+	if !within(root, dir) {
+		return "", fmt.Errorf("rule pack path must be inside rulepack.root")
+	}
+	if strings.HasPrefix(pack.Ref, "-") {
+		return "", fmt.Errorf("invalid git ref %q", pack.Ref)
+	}
+	if pack.Subdir != "" {
+		if filepath.IsAbs(pack.Subdir) || !within(dir, filepath.Join(dir, pack.Subdir)) {
+			return "", fmt.Errorf("subdir must be inside the rule pack path")
+		}
+		if pack.Ref == "" {
+			sub, err := resolve(filepath.Join(dir, pack.Subdir))
+			if err != nil {
+				return "", err
+			}
+			if !within(dir, sub) {
+				return "", fmt.Errorf("subdir must be inside the rule pack path")
+			}
+		}
+	}
+	return dir, nil
+}
+
+// resolve 解析符号链接并转为绝对Path
+func resolve(p string) (string, error) {
+	p, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
+
+// within p 是否为 root 或其子Path
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Parse 解析 YAML (可包含多个 --- 分隔的文档)，跳过非 Rule 的文档
+func Parse(data []byte) ([]crd.Rule, error) {
+	var rules []crd.Rule
+	for i, doc := range docSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var r crd.Rule
+		if err := yaml.UnmarshalStrict([]byte(doc), &r); err != nil {
+			return nil, fmt.Errorf("document %d: %v", i+1, err)
+		}
+		if r.Kind != KindRule {
+			continue
+		}
+		if r.APIVersion != APIVersion {
+			return nil, fmt.Errorf("document %d: unsupported apiVersion %q", i+1, r.APIVersion)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func readDir(dir string) ([]sourceFile, error) {
+	var files []sourceFile
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isYAML(p) {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files = append(files, sourceFile{Path: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// readGitRef 直接从 git 对象读取指定引用的File，不修改工作区
+func readGitRef(repo, ref, subdir string) ([]sourceFile, string, error) {
+	revision, err := git(repo, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown git ref %s: %v", ref, err)
+	}
+
+	args := []string{"ls-tree", "-r", "--name-only", revision}
+	if subdir != "" {
+		args = append(args, "--", path.Clean(subdir))
+	}
+	list, err := git(repo, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var files []sourceFile
+	for _, name := range strings.Split(list, "\n") {
+		if name == "" || !isYAML(name) {
+			continue
+		}
+		data, err := gitBytes(repo, "show", revision+":"+name)
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, sourceFile{Path: name, Data: data})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, revision, nil
+}
+
+func isYAML(p string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+func isGitRepo(dir string) bool {
+	_, err := git(dir, "rev-parse", "--git-dir")
+	return err == nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := gitBytes(dir, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+func gitBytes(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
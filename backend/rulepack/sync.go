@@ -0,0 +1,316 @@
+package rulepack
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/laenix/vsentry/crd"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/attack"
+	"github.com/laenix/vsentry/pkg/logsql"
+	"github.com/laenix/vsentry/scheduler"
+	"gorm.io/gorm"
+)
+
+// SyncResult 一次同步的结果，dry-run 时只计算不写库
+type SyncResult struct {
+	Revision  string   `json:"revision"`
+	DryRun    bool     `json:"dry_run"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Disabled  []string `json:"disabled"`
+	Unchanged []string `json:"unchanged"`
+	Drifted   []string `json:"drifted"` // 被手动修改过、本次已恢复为Rule包内容
+	// AutoDisabled 因连续失败被自动停用、与Rule包不一致的Rule，保持停用直到人工启用
+	AutoDisabled []string `json:"auto_disabled"`
+}
+
+// ErrManagedRule 受管Rule禁止修改
+var ErrManagedRule = errors.New("rule is managed by a rule pack")
+
+// Init 启动定时同步
+func Init() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			var packs []model.RulePack
+			database.GetDB().Where("enabled = ? AND sync_minutes > 0", true).Find(&packs)
+			for i := range packs {
+				pack := &packs[i]
+				if pack.LastSyncAt != nil && now.Sub(*pack.LastSyncAt) < time.Duration(pack.SyncMinutes)*time.Minute {
+					continue
+				}
+				Sync(pack, false)
+			}
+		}
+	}()
+}
+
+// Sync 读取Rule包并与Data库对账：New增Create、变更Update、Rule包Medium已移除的Rule停用
+func Sync(pack *model.RulePack, dryRun bool) (*SyncResult, error) {
+	result, err := reconcile(pack, dryRun)
+	if dryRun {
+		return result, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"last_sync_at": now, "last_error": ""}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		log.Printf("[RulePack] %s sync failed: %v", pack.Name, err)
+	} else {
+		raw, _ := json.Marshal(result)
+		updates["revision"] = result.Revision
+		updates["last_result"] = string(raw)
+		log.Printf("[RulePack] %s synced at %s: %d created, %d updated, %d disabled",
+			pack.Name, result.Revision, len(result.Created), len(result.Updated), len(result.Disabled))
+	}
+	database.GetDB().Model(pack).Updates(updates)
+	return result, err
+}
+
+func reconcile(pack *model.RulePack, dryRun bool) (*SyncResult, error) {
+	defs, revision, err := load(pack)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{
+		Revision:  revision,
+		DryRun:    dryRun,
+		Created:   []string{},
+		Updated:   []string{},
+		Disabled:  []string{},
+		Unchanged: []string{},
+		Drifted:   []string{},
+
+		AutoDisabled: []string{},
+	}
+
+	// 先整体校验，任何一条Rule有误都不做变更，避免半同步
+	desired := make(map[string]model.Rule)
+	order := make([]string, 0, len(defs))
+	for _, def := range defs {
+		key := def.Rule.Metadata.Name
+		if key == "" {
+			return nil, fmt.Errorf("%s: metadata.name is required", def.File)
+		}
+		if _, dup := desired[key]; dup {
+			return nil, fmt.Errorf("%s: duplicate rule %s", def.File, key)
+		}
+		rule, err := ToModel(def.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", def.File, key, err)
+		}
+		rule.PackID = pack.ID
+		rule.ManagedKey = key
+		if rule.Source == "" {
+			rule.Source = "rulepack:" + pack.Name
+		}
+		desired[key] = rule
+		order = append(order, key)
+	}
+
+	db := database.GetDB()
+	var existing []model.Rule
+	if err := db.Where("pack_id = ?", pack.ID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[string]model.Rule)
+	for _, r := range existing {
+		current[r.ManagedKey] = r
+	}
+
+	var backtrace []uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range order {
+			want := desired[key]
+			have, ok := current[key]
+			if ok && have.AutoDisabled {
+				want.AutoDisabled = true
+				if want.Enabled {
+					want.Enabled = false
+					result.AutoDisabled = append(result.AutoDisabled, key)
+				}
+			}
+			switch {
+			case !ok:
+				result.Created = append(result.Created, key)
+				if dryRun {
+					continue
+				}
+				want.Version = 1
+				if err := tx.Create(&want).Error; err != nil {
+					return err
+				}
+				if err := database.ReplaceRuleTags(tx, want.ID, want.Tags); err != nil {
+					return err
+				}
+				if want.Enabled && want.EnableBacktrace && want.Type == "alert" {
+					backtrace = append(backtrace, want.ID)
+				}
+			case have.PackHash != want.PackHash || have.Drift || have.Enabled != want.Enabled:
+				if have.PackHash == want.PackHash && have.Drift {
+					result.Drifted = append(result.Drifted, key)
+				} else {
+					result.Updated = append(result.Updated, key)
+				}
+				if dryRun {
+					continue
+				}
+				if have.Type == scheduler.RuleTypeAnomaly && (have.Query != want.Query ||
+					have.Anomaly.EntityField != want.Anomaly.EntityField || have.Anomaly.ValueField != want.Anomaly.ValueField) {
+					if err := tx.Where("rule_id = ?", have.ID).Delete(&model.AnomalyBaseline{}).Error; err != nil {
+						return err
+					}
+				}
+				want.ID = have.ID
+				want.CreatedAt = have.CreatedAt
+				want.Version = have.Version + 1
+				if err := tx.Save(&want).Error; err != nil {
+					return err
+				}
+				if err := database.ReplaceRuleTags(tx, want.ID, want.Tags); err != nil {
+					return err
+				}
+			default:
+				result.Unchanged = append(result.Unchanged, key)
+			}
+		}
+
+		// Rule包Medium已Not found的Rule只停用不Delete，保留历史Alert的关联
+		for key, have := range current {
+			if _, ok := desired[key]; ok || !have.Enabled {
+				continue
+			}
+			result.Disabled = append(result.Disabled, key)
+			if dryRun {
+				continue
+			}
+			if err := tx.Model(&model.Rule{}).Where("id = ?", have.ID).Update("enabled", false).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun && len(result.Created)+len(result.Updated)+len(result.Drifted)+len(result.Disabled) > 0 {
+		scheduler.GlobalEngine.ReloadRules()
+		for _, id := range backtrace {
+			if _, err := scheduler.TriggerBacktrace(id); err != nil {
+				log.Printf("[Backtrace] %v", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ToModel 将声明式Rule转换为 model.Rule，并计算 spec 哈希用于判断是否变更
+func ToModel(r crd.Rule) (model.Rule, error) {
+	spec := r.Spec
+	if spec.Name == "" || spec.Query == "" {
+		return model.Rule{}, fmt.Errorf("spec.name and spec.query are required")
+	}
+	if spec.Type == "" {
+		spec.Type = "alert"
+	}
+	switch spec.Type {
+	case "alert", scheduler.RuleTypeAnomaly, scheduler.RuleTypeStream, "forensic", "investigation":
+	default:
+		return model.Rule{}, fmt.Errorf("unsupported rule type: %s", spec.Type)
+	}
+	if spec.Interval == "" && (spec.Type == "alert" || spec.Type == scheduler.RuleTypeAnomaly) {
+		return model.Rule{}, fmt.Errorf("spec.interval is required for %s rules", spec.Type)
+	}
+	if spec.Interval != "" {
+		if _, err := scheduler.ParseInterval(spec.Interval); err != nil {
+			return model.Rule{}, fmt.Errorf("invalid spec.interval %q: %v", spec.Interval, err)
+		}
+	}
+	if spec.Type == scheduler.RuleTypeStream {
+		if _, err := scheduler.CompileStreamQuery(spec.Query); err != nil {
+			return model.Rule{}, fmt.Errorf("invalid spec.query: %v", err)
+		}
+	} else if _, err := logsql.Analyze(spec.Query); err != nil {
+		return model.Rule{}, fmt.Errorf("invalid spec.query: %v", err)
+	}
+	if err := scheduler.ValidateFollowUps(spec.FollowUps); err != nil {
+		return model.Rule{}, err
+	}
+	if err := scheduler.ValidateRuleTests(spec.Tests); err != nil {
+		return model.Rule{}, err
+	}
+	tags, err := attack.NormalizeTags(spec.Tags)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	spec.Tags = tags
+
+	rule := model.Rule{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Query:       spec.Query,
+		Interval:    spec.Interval,
+		Severity:    spec.Severity,
+		Enabled:     spec.Enabled == nil || *spec.Enabled,
+		Source:      spec.Source,
+		Type:        spec.Type,
+		Tags:        tags,
+		FollowUps:   spec.FollowUps,
+		Tests:       spec.Tests,
+	}
+	if spec.Backtrace != nil {
+		rule.EnableBacktrace = spec.Backtrace.Enabled
+		rule.BacktraceCron = spec.Backtrace.Cron
+		rule.BacktraceStart = spec.Backtrace.Start
+	}
+	if spec.Anomaly != nil {
+		rule.Anomaly = spec.Anomaly.WithDefaults()
+	}
+	if spec.Risk != nil {
+		if err := scheduler.ValidateRiskSettings(*spec.Risk); err != nil {
+			return model.Rule{}, err
+		}
+		rule.Risk = *spec.Risk
+	}
+
+	raw, _ := json.Marshal(spec)
+	rule.PackHash = fmt.Sprintf("%x", sha256.Sum256(raw))
+	return rule, nil
+}
+
+// CheckEdit 受管Rule的修改策略：block 模式Return ErrManagedRule，flag 模式Allow修改并Return true（调用方需标记 drift）
+func CheckEdit(ruleID uint) (bool, error) {
+	db := database.GetDB()
+	var rule model.Rule
+	if err := db.Select("id", "pack_id").First(&rule, ruleID).Error; err != nil || rule.PackID == 0 {
+		return false, nil
+	}
+	var pack model.RulePack
+	if err := db.First(&pack, rule.PackID).Error; err != nil {
+		return false, nil
+	}
+	if pack.Mode == model.RulePackFlag {
+		return true, nil
+	}
+	return false, fmt.Errorf("%w %q, edit the pack source instead", ErrManagedRule, pack.Name)
+}
+
+// MarkDrift 标记受管Rule已被手动修改
+func MarkDrift(ruleID uint) {
+	database.GetDB().Model(&model.Rule{}).Where("id = ?", ruleID).Update("drift", true)
+}
+
+// Release 解除Rule包对Rule的管理（DeleteRule包时），Rule保留为手动Rule
+func Release(packID uint) error {
+	return database.GetDB().Model(&model.Rule{}).Where("pack_id = ?", packID).
+		Updates(map[string]interface{}{"pack_id": 0, "managed_key": "", "pack_hash": "", "drift": false}).Error
+}
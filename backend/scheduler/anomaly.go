@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+)
+
+const RuleTypeAnomaly = "anomaly"
+
+// maxBaselineSamples every个 实体+时段 保留的最近样本数
+const maxBaselineSamples = 64
+
+// anomalyScore 单个实体本次的评估结果
+type anomalyScore struct {
+	Entity    string  `json:"entity"`
+	Value     float64 `json:"value"`
+	Bucket    int     `json:"bucket"`
+	Samples   int     `json:"samples"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"stddev"`
+	ZScore    float64 `json:"zscore"`
+	Lower     float64 `json:"lower"` // percentile 方法的下界
+	Upper     float64 `json:"upper"` // percentile 方法的上界
+	Method    string  `json:"method"`
+	Threshold float64 `json:"threshold"`
+	Anomalous bool    `json:"-"`
+}
+
+// evaluateAnomaly 将 stats Query结果与基线比较：异常的实体产生Alert，正常值并入基线
+func evaluateAnomaly(rule model.Rule, body string, now time.Time) int {
+	db := database.GetDB()
+	settings := rule.Anomaly.WithDefaults()
+	bucket := HourOfWeek(now)
+	learning := AnomalyLearning(rule, now)
+
+	var evidence []string
+	evaluate := func(entity string, value float64, row map[string]interface{}) {
+		var baseline model.AnomalyBaseline
+		db.Where("rule_id = ? AND entity = ? AND bucket = ?", rule.ID, entity, bucket).Limit(1).Find(&baseline)
+		values := baselineValues(baseline)
+
+		score := scoreAnomaly(settings, values, value)
+		score.Entity = entity
+		score.Bucket = bucket
+
+		// 已过学习期且样本足够才评估
+		if !learning && len(values) >= settings.MinSamples && score.Anomalous {
+			label := entity
+			if settings.EntityField != "" {
+				label = settings.EntityField + "=" + entity
+			}
+			row["_time"] = now.UTC().Format(time.RFC3339)
+			row["_msg"] = fmt.Sprintf("%s: %s %.2f deviates from baseline (mean %.2f, stddev %.2f)",
+				label, settings.ValueField, value, score.Mean, score.StdDev)
+			row["anomaly"] = score
+			raw, _ := json.Marshal(row)
+			evidence = append(evidence, string(raw))
+			return
+		}
+
+		// 异常值不并入基线，避免攻击流量抬高基线
+		values = append(values, value)
+		if len(values) > maxBaselineSamples {
+			values = values[len(values)-maxBaselineSamples:]
+		}
+		saveBaseline(&baseline, rule.ID, entity, bucket, values)
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			continue
+		}
+
+		entity := "*"
+		if settings.EntityField != "" {
+			entity = fmt.Sprint(row[settings.EntityField])
+		}
+		value, err := strconv.ParseFloat(fmt.Sprint(row[settings.ValueField]), 64)
+		if err != nil {
+			log.Printf("[Rule:%d][Anomaly] Field %s is not numeric in: %s", rule.ID, settings.ValueField, line)
+			continue
+		}
+		seen[entity] = true
+		evaluate(entity, value, row)
+	}
+
+	// 有基线但本次没有结果的实体按 0 评估，用于发现量骤降 (如Log源静默)
+	var missing []string
+	db.Model(&model.AnomalyBaseline{}).Where("rule_id = ? AND bucket = ?", rule.ID, bucket).Pluck("entity", &missing)
+	for _, entity := range missing {
+		if seen[entity] {
+			continue
+		}
+		row := map[string]interface{}{settings.ValueField: 0}
+		if settings.EntityField != "" {
+			row[settings.EntityField] = entity
+		}
+		evaluate(entity, 0, row)
+	}
+
+	if len(evidence) == 0 {
+		return 0
+	}
+	return saveAlert(rule, strings.Join(evidence, "\n"))
+}
+
+// scoreAnomaly 按 zscore / percentile 计算偏离程度
+func scoreAnomaly(s model.AnomalySettings, values []float64, value float64) anomalyScore {
+	mean, std := meanStdDev(values)
+	score := anomalyScore{Value: value, Samples: len(values), Mean: mean, StdDev: std, Method: s.Method, Threshold: s.Threshold}
+	if len(values) == 0 {
+		return score
+	}
+
+	// 基线完全平稳时任何变化都视为显著偏离
+	score.ZScore = (value - mean) / math.Max(std, 1e-9)
+
+	switch s.Method {
+	case "percentile":
+		score.Upper = percentile(values, s.Threshold)
+		score.Lower = percentile(values, 100-s.Threshold)
+		above, below := value > score.Upper, value < score.Lower
+		score.Anomalous = (s.Direction != "down" && above) || (s.Direction != "up" && below)
+	default:
+		above, below := score.ZScore >= s.Threshold, score.ZScore <= -s.Threshold
+		score.Anomalous = (s.Direction != "down" && above) || (s.Direction != "up" && below)
+	}
+	return score
+}
+
+// AnomalyLearning Rule是否仍处于学习期（从第一条基线写入开始计算）
+func AnomalyLearning(rule model.Rule, now time.Time) bool {
+	until, ok := AnomalyLearningUntil(rule)
+	return !ok || now.Before(until)
+}
+
+// AnomalyLearningUntil 学习期结束Time，尚无基线时Return false
+func AnomalyLearningUntil(rule model.Rule) (time.Time, bool) {
+	var first model.AnomalyBaseline
+	if database.GetDB().Where("rule_id = ?", rule.ID).Order("created_at asc").Limit(1).Find(&first).RowsAffected == 0 {
+		return time.Time{}, false
+	}
+	return first.CreatedAt.AddDate(0, 0, rule.Anomaly.WithDefaults().LearningDays), true
+}
+
+// ResetAnomalyBaseline 清空Rule基线，学习期重New开始（Query或实体字段变化后基线不再可比）
+func ResetAnomalyBaseline(ruleID uint) error {
+	return database.GetDB().Where("rule_id = ?", ruleID).Delete(&model.AnomalyBaseline{}).Error
+}
+
+// HourOfWeek 季节性时段：周日 00 点为 0，周六 23 点为 167（按服务器时区）
+func HourOfWeek(t time.Time) int {
+	t = t.Local()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+func baselineValues(b model.AnomalyBaseline) []float64 {
+	var values []float64
+	if b.Values != "" {
+		json.Unmarshal([]byte(b.Values), &values)
+	}
+	return values
+}
+
+func saveBaseline(b *model.AnomalyBaseline, ruleID uint, entity string, bucket int, values []float64) {
+	raw, _ := json.Marshal(values)
+	b.RuleID = ruleID
+	b.Entity = entity
+	b.Bucket = bucket
+	b.Samples = len(values)
+	b.Mean, b.StdDev = meanStdDev(values)
+	b.Values = string(raw)
+	database.GetDB().Save(b)
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+// percentile 线性插值百分位 (p: 0-100)
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	p = math.Min(math.Max(p, 0), 100)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
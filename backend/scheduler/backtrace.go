@@ -0,0 +1,305 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+)
+
+// BacktraceManager 回溯Task池：全局并发Limit + 按days检查点 + 暂停/Cancel控制
+type BacktraceManager struct {
+	slots    chan struct{}
+	controls map[uint]chan string // 运行Medium的 jobID -> 控制信号 (paused/cancelled)
+	mu       sync.Mutex
+}
+
+var GlobalBacktrace *BacktraceManager
+
+// InitBacktrace Initialize回溯Task池，并恢复重启ago未完成的Task
+func InitBacktrace() {
+	workers := viper.GetInt("scheduler.backtrace_workers")
+	if workers <= 0 {
+		workers = 2
+	}
+	GlobalBacktrace = &BacktraceManager{
+		slots:    make(chan struct{}, workers),
+		controls: make(map[uint]chan string),
+	}
+	GlobalBacktrace.resumePending()
+	log.Printf("Backtrace manager initialized with %d workers", workers)
+}
+
+// resumePending 进程重启后，running 的Task已无人Execute，重置为 queued 并从检查点Continue
+func (m *BacktraceManager) resumePending() {
+	db := database.GetDB()
+	db.Model(&model.BacktraceJob{}).Where("status = ?", model.BacktraceRunning).Update("status", model.BacktraceQueued)
+
+	var jobs []model.BacktraceJob
+	db.Where("status = ?", model.BacktraceQueued).Order("id asc").Find(&jobs)
+	for _, job := range jobs {
+		log.Printf("[Backtrace] Resuming job %d for rule %d from %s", job.ID, job.RuleID, job.Cursor.Format("2006-01-02"))
+		m.dispatch(job.ID)
+	}
+}
+
+// TriggerBacktrace 为Rule创建回溯Task并排队Execute
+func TriggerBacktrace(ruleID uint) (*model.BacktraceJob, error) {
+	db := database.GetDB()
+	var rule model.Rule
+	if err := db.First(&rule, ruleID).Error; err != nil {
+		return nil, fmt.Errorf("rule not found: %d", ruleID)
+	}
+	if rule.Type != "" && rule.Type != "alert" {
+		return nil, fmt.Errorf("backtrace only supports alert rules")
+	}
+	if !rule.EnableBacktrace {
+		return nil, fmt.Errorf("backtrace not enabled for rule: %s", rule.Name)
+	}
+
+	// 同一Rule只允许一个活跃Task，避免重复扫描
+	var active int64
+	db.Model(&model.BacktraceJob{}).
+		Where("rule_id = ? AND status IN ?", rule.ID, []string{model.BacktraceQueued, model.BacktraceRunning, model.BacktracePaused}).
+		Count(&active)
+	if active > 0 {
+		return nil, fmt.Errorf("rule %d already has an active backtrace job", rule.ID)
+	}
+
+	start := truncateDay(parseBacktraceStart(rule.BacktraceStart))
+	end := time.Now().UTC()
+	if !start.Before(end) {
+		return nil, fmt.Errorf("invalid backtrace start: %s", rule.BacktraceStart)
+	}
+
+	job := model.BacktraceJob{
+		RuleID:     rule.ID,
+		Status:     model.BacktraceQueued,
+		RangeStart: start,
+		RangeEnd:   end,
+		Cursor:     start,
+		DaysTotal:  int(end.Sub(start).Hours()/24) + 1,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Backtrace] Queued job %d for rule: %s (start: %s, days: %d)", job.ID, rule.Name, rule.BacktraceStart, job.DaysTotal)
+	GlobalBacktrace.dispatch(job.ID)
+	return &job, nil
+}
+
+// Pause 暂停Task：运行Medium的Task在当ago这一days结束后停下，排队Medium的直接置为 paused
+func (m *BacktraceManager) Pause(jobID uint) error {
+	return m.control(jobID, model.BacktracePaused, model.BacktraceQueued, model.BacktraceRunning)
+}
+
+// Cancel CancelTask，已完成的Task不可Cancel
+func (m *BacktraceManager) Cancel(jobID uint) error {
+	return m.control(jobID, model.BacktraceCancelled, model.BacktraceQueued, model.BacktraceRunning, model.BacktracePaused)
+}
+
+// Resume 从检查点恢复暂停的Task
+func (m *BacktraceManager) Resume(jobID uint) error {
+	db := database.GetDB()
+	result := db.Model(&model.BacktraceJob{}).
+		Where("id = ? AND status = ?", jobID, model.BacktracePaused).
+		Update("status", model.BacktraceQueued)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("only paused jobs can be resumed")
+	}
+	m.dispatch(jobID)
+	return nil
+}
+
+// control 向运行Medium的Task发信号；未运行的Task直接修改Status
+func (m *BacktraceManager) control(jobID uint, target string, allowed ...string) error {
+	db := database.GetDB()
+	var job model.BacktraceJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		return fmt.Errorf("job not found: %d", jobID)
+	}
+
+	ok := false
+	for _, s := range allowed {
+		if job.Status == s {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return fmt.Errorf("cannot change job from %s to %s", job.Status, target)
+	}
+
+	// 运行Medium的Task只在每days开始前读取一次信号：未读取的信号被替换为最New的，但 Cancel 优先于 Pause
+	m.mu.Lock()
+	ctl, running := m.controls[jobID]
+	if running {
+		defer m.mu.Unlock()
+		select {
+		case pending := <-ctl:
+			if pending == model.BacktraceCancelled && target != model.BacktraceCancelled {
+				ctl <- pending
+				return errors.New("job is being cancelled")
+			}
+		default:
+		}
+		ctl <- target // 发送方只有 control 且持有锁，缓冲已清空，不会阻塞
+		return nil
+	}
+	m.mu.Unlock()
+
+	updates := map[string]interface{}{"status": target}
+	if target == model.BacktraceCancelled {
+		updates["finished_at"] = time.Now()
+	}
+	return db.Model(&model.BacktraceJob{}).Where("id = ? AND status IN ?", jobID, allowed).Updates(updates).Error
+}
+
+// dispatch 等待空闲槽位后ExecuteTask
+func (m *BacktraceManager) dispatch(jobID uint) {
+	go func() {
+		m.slots <- struct{}{}
+		defer func() { <-m.slots }()
+		m.run(jobID)
+	}()
+}
+
+func (m *BacktraceManager) run(jobID uint) {
+	db := database.GetDB()
+
+	// 先Register控制通道，保证认领后到达的暂停/Cancel信号不会丢失
+	ctl := make(chan string, 1)
+	m.mu.Lock()
+	if _, exists := m.controls[jobID]; exists {
+		m.mu.Unlock()
+		return
+	}
+	m.controls[jobID] = ctl
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.controls, jobID)
+		m.mu.Unlock()
+	}()
+
+	// 原子地认领Task：等待槽位期间Task可能已被暂停/Cancel，或被另一个协程认领
+	now := time.Now()
+	claim := db.Model(&model.BacktraceJob{}).
+		Where("id = ? AND status = ?", jobID, model.BacktraceQueued).
+		Updates(map[string]interface{}{"status": model.BacktraceRunning, "error": ""})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return
+	}
+
+	var job model.BacktraceJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		return
+	}
+	if job.StartedAt == nil {
+		db.Model(&job).Update("started_at", now)
+	}
+
+	var rule model.Rule
+	if err := db.First(&rule, job.RuleID).Error; err != nil {
+		m.finish(&job, model.BacktraceFailed, "rule not found")
+		return
+	}
+
+	log.Printf("[Backtrace] Job %d running for rule: %s (%d/%d days done)", job.ID, rule.Name, job.DaysDone, job.DaysTotal)
+
+	// 逐days回溯：every完成一days写入一次检查点
+	for job.Cursor.Before(job.RangeEnd) {
+		select {
+		case sig := <-ctl:
+			if sig == model.BacktraceCancelled {
+				m.finish(&job, model.BacktraceCancelled, "")
+			} else {
+				db.Model(&job).Update("status", sig)
+			}
+			log.Printf("[Backtrace] Job %d %s at %s", job.ID, sig, job.Cursor.Format("2006-01-02"))
+			return
+		default:
+		}
+
+		dayStart := job.Cursor
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		if dayEnd.After(job.RangeEnd) {
+			dayEnd = job.RangeEnd
+		}
+
+		query := buildQueryWithTimeRange(rule.Query, dayStart, dayEnd)
+		found, err := ExecuteRuleWithQuery(rule, query)
+		if err != nil {
+			m.finish(&job, model.BacktraceFailed, fmt.Sprintf("%s: %v", dayStart.Format("2006-01-02"), err))
+			return
+		}
+
+		job.Cursor = dayEnd
+		job.DaysDone++
+		job.AlertsFound += found
+		db.Model(&job).Updates(map[string]interface{}{
+			"cursor":       job.Cursor,
+			"days_done":    job.DaysDone,
+			"alerts_found": job.AlertsFound,
+		})
+	}
+
+	m.finish(&job, model.BacktraceDone, "")
+	log.Printf("[Backtrace] Completed job %d for rule: %s (%d alerts)", job.ID, rule.Name, job.AlertsFound)
+}
+
+func (m *BacktraceManager) finish(job *model.BacktraceJob, status string, errMsg string) {
+	now := time.Now()
+	job.Status = status
+	job.Error = errMsg
+	job.FinishedAt = &now
+	database.GetDB().Model(job).Updates(map[string]interface{}{
+		"status":      status,
+		"error":       errMsg,
+		"finished_at": now,
+	})
+}
+
+// parseBacktraceStart Parse回溯开始Time
+func parseBacktraceStart(startStr string) time.Time {
+	now := time.Now().UTC()
+
+	switch startStr {
+	case "1y":
+		return now.AddDate(-1, 0, 0)
+	case "180d":
+		return now.AddDate(0, -6, 0)
+	case "90d":
+		return now.AddDate(0, -3, 0)
+	case "30d":
+		return now.AddDate(0, 0, -30)
+	case "7d":
+		return now.AddDate(0, 0, -7)
+	default:
+		// 尝试ParseDate格式
+		if t, err := time.Parse("2006-01-02", startStr); err == nil {
+			return t
+		}
+		// Default回溯1year
+		return now.AddDate(-1, 0, 0)
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// buildQueryWithTimeRange 构建带Time范围的Query，区间左闭右开，保证相邻两days不重复
+func buildQueryWithTimeRange(query string, start, end time.Time) string {
+	return fmt.Sprintf("_time:[%s, %s) %s", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), query)
+}
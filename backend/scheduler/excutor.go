@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -12,132 +13,271 @@ import (
 
 	"github.com/laenix/vsentry/automation"
 	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/inventory"
 	"github.com/laenix/vsentry/model"
-	"github.com/spf13/viper"
+	"github.com/laenix/vsentry/notify"
+	"github.com/laenix/vsentry/observable"
+	"github.com/laenix/vsentry/pkg/attack"
 )
 
-// ExecuteRule ExecuteRuleQuery
+// ExecuteRule ExecuteRuleQuery，并记录本次Execute (耗时 / 行数 / Error) 用于健康度统计
 func ExecuteRule(rule model.Rule) {
-	vLogsAddr := viper.GetString("victorialogs.url")
-	if vLogsAddr == "" {
-		vLogsAddr = "http://127.0.0.1:9428"
-	}
-
 	// ✅ 核心修正：绝对信任User的Rule！不强加任何额外的Time窗口拼接
 	finalQuery := strings.TrimSpace(rule.Query)
+	if w := skipWindow(rule, time.Now()); w != nil {
+		log.Printf("[Rule:%d] Skipped: maintenance window %q", rule.ID, w.Name)
+		return
+	}
 	log.Printf("[Rule:%d] Executing: %s", rule.ID, finalQuery)
 
-	// Send给 VictoriaLogs
-	resp, err := http.PostForm(vLogsAddr+"/select/logsql/query", url.Values{
-		"query": {finalQuery},
+	exec := model.RuleExecution{RuleID: rule.ID, Kind: ExecScheduled, StartedAt: time.Now()}
+	body, err := queryVictoriaLogs(finalQuery)
+	if err != nil {
+		// ✅ 致命Error拦截：如果 LogSQL 写错了 (如拼写Error)，阻断Execute，防止污染Data库
+		log.Printf("[Rule:%d] %v | Query: %s", rule.ID, err, finalQuery)
+		exec.Error = err.Error()
+	} else {
+		exec.Rows = countRows(body)
+		// 异常Rule在结果为空时也要评估：有基线的实体全部静默时按 0 计算偏离
+		if rule.Type == RuleTypeAnomaly {
+			exec.NewAlerts = evaluateAnomaly(rule, body, exec.StartedAt)
+		} else if body != "" {
+			exec.NewAlerts = saveAlert(rule, body)
+		}
+	}
+	exec.DurationMs = time.Since(exec.StartedAt).Milliseconds()
+
+	recordExecution(rule, exec)
+}
+
+// queryVictoriaLogs Send LogSQL 给 VictoriaLogs，Return NDJSON 结果
+func queryVictoriaLogs(query string) (string, error) {
+	resp, err := http.PostForm(victoriaLogsURL()+"/select/logsql/query", url.Values{
+		"query": {query},
 		"limit": {"1000"}, // 在 HTTP API 层面Settings兜底 limit，不影响User的 LogSQL
 	})
 	if err != nil {
-		log.Printf("[Rule:%d] Request failed: %v", rule.ID, err)
-		return
+		return "", fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	if len(body) == 0 {
-		return
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("query error (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
+	return strings.TrimSpace(string(body)), nil
+}
 
-	// ✅ 致命Error拦截：如果 LogSQL 写错了 (如拼写Error)，阻断Execute，防止污染Data库
-	if resp.StatusCode >= 400 {
-		log.Printf("[Rule:%d] Query Syntax Error: %s | Query: %s", rule.ID, string(body), finalQuery)
-		return
+// countRows 统计 NDJSON 行数
+func countRows(body string) int {
+	rows := 0
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) != "" {
+			rows++
+		}
 	}
+	return rows
+}
 
-	saveAlert(rule, string(body))
+// saveAlert 将Query结果写入 Incident/Alert，Return本次New增的Alert数
+// 处于维护窗口的Event按窗口配置丢弃 (skip) 或记录为已抑制Alert (suppress)；
+// Rule配置了后续Query时，NewAlert先进入待确认队列，窗口结束后由 follow-up worker 决定是否上报
+func saveAlert(rule model.Rule, evidence string) int {
+	return storeAlerts(rule, strings.Split(strings.TrimSpace(evidence), "\n"), nil)
 }
 
-func saveAlert(rule model.Rule, evidence string) {
+// storeAlerts resolved 与 lines 一一对应，为 follow-up worker 已完成的后续Query结果；nil 表示检测产生的NewEvent
+func storeAlerts(rule model.Rule, lines []string, resolved []*followUpOutcome) int {
 	db := database.GetDB()
 	now := time.Now().UTC()
+	windows := maintenanceFor(rule)
 
+	// Incident 在出现第一条需要上报的Alert时才获取/Create
 	var incident model.Incident
-	err := db.Where("rule_id = ? AND status != ?", rule.ID, "resolved").
-		Order("last_seen desc").First(&incident).Error
-
-	if err != nil {
-		incident = model.Incident{
-			RuleID:    rule.ID,
-			Name:      rule.Name,
-			Severity:  rule.Severity,
-			Status:    "new",
-			FirstSeen: now,
-			LastSeen:  now,
-		}
-		db.Create(&incident)
+	created := false
+	loadIncident := func() {
+		if incident.ID != 0 {
+			return
+		}
+		err := db.Where("rule_id = ? AND status != ?", rule.ID, "resolved").
+			Order("last_seen desc").First(&incident).Error
+		if err != nil {
+			incident = model.Incident{
+				RuleID:    rule.ID,
+				Name:      rule.Name,
+				Severity:  rule.Severity,
+				Status:    "new",
+				FirstSeen: now,
+				LastSeen:  now,
+			}
+			InheritAttack(&incident)
+			database.ApplySLA(db, &incident)
+			if db.Create(&incident).Error == nil {
+				created = true
+				database.LogIncidentActivity(db, model.IncidentActivity{
+					IncidentID: incident.ID,
+					Type:       model.ActivityCreated,
+					To:         incident.Status,
+					Detail:     fmt.Sprintf("rule %q matched", rule.Name),
+				})
+			}
+		}
 	}
 
-	// Parse NDJSON，针对every一条原始Log计算独立指纹
-	lines := strings.Split(strings.TrimSpace(evidence), "\n")
+	// 针对every一条原始Log计算独立指纹
 	newAlertsCount := 0
+	followUps := followUpBudget()
+	escalated, escalatedBy := "", ""
+	riskOnly := 0
 
-	for _, line := range lines {
+	for i, line := range lines {
 		if line == "" {
 			continue
 		}
 		// 指纹计算基于RuleID和该单条Log内容
 		fp := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d-%s", rule.ID, line))))
-		
+
 		var count int64
 		db.Model(&model.Alert{}).Where("fingerprint = ?", fp).Count(&count)
-		
-		if count == 0 {
-			alert := model.Alert{
-				IncidentID:  incident.ID,
-				RuleID:      rule.ID,
-				Content:     line,
-				Fingerprint: fp,
+		if count > 0 {
+			continue
+		}
+		// 定时Rule的Query窗口可能重叠，等待后续Query的同一Event不重复排队
+		if resolved == nil {
+			db.Model(&model.PendingAlert{}).Where("fingerprint = ?", fp).Count(&count)
+			if count > 0 {
+				continue
+			}
+		}
+
+		alert := model.Alert{
+			RuleID:      rule.ID,
+			Content:     line,
+			Fingerprint: fp,
+		}
+		if w := matchMaintenance(windows, line, now); w != nil {
+			if w.Action == model.MaintenanceSkip {
+				continue
 			}
+			alert.Suppressed = true
+			alert.MaintenanceID = w.ID
 			db.Create(&alert)
-			newAlertsCount++
+			continue
 		}
+
+		// 后续Query确认：窗口结束后再Execute，结果附加到Alert，可丢弃Alert或提升级别
+		var outcome *followUpOutcome
+		if resolved != nil {
+			outcome = resolved[i]
+		} else if len(rule.FollowUps) > 0 && followUps > 0 {
+			followUps--
+			db.Create(&model.PendingAlert{RuleID: rule.ID, Content: line, Fingerprint: fp, DueAt: followUpDue(rule, line, now)})
+			continue
+		}
+		if outcome != nil {
+			alert.FollowUp, _ = json.Marshal(outcome.Results)
+			if outcome.Drop {
+				alert.Suppressed = true
+				db.Create(&alert)
+				continue
+			}
+			if outcome.Severity != "" && HigherSeverity(rule.Severity, outcome.Severity) != rule.Severity {
+				alert.Severity = outcome.Severity
+				if HigherSeverity(escalated, outcome.Severity) != escalated {
+					escalated, escalatedBy = outcome.Severity, "escalated by follow-up query"
+				}
+			}
+		}
+
+		// 资产清单富化：涉及重要资产时按 inventory.severity_boost 提升级别
+		if asset := inventory.Enrich(db, &alert); asset != nil {
+			current := HigherSeverity(rule.Severity, alert.Severity)
+			if boost := inventory.SeverityBoost(asset.Criticality); boost != "" && HigherSeverity(current, boost) != current {
+				alert.Severity = boost
+				if HigherSeverity(escalated, boost) != escalated {
+					escalated, escalatedBy = boost, fmt.Sprintf("escalated by %s asset %s %s", asset.Criticality, asset.Kind, asset.Name)
+				}
+			}
+		}
+
+		// 只累计风险的Rule不单独产生 Incident
+		if rule.Risk.Only {
+			if db.Create(&alert).Error == nil {
+				addRisk(rule, alert)
+				riskOnly++
+			}
+			continue
+		}
+
+		loadIncident()
+		alert.IncidentID = incident.ID
+		if db.Create(&alert).Error == nil {
+			addRisk(rule, alert)
+			observable.Record(db, alert)
+		}
+		newAlertsCount++
 	}
 
 	// 仅当产生NewAlertEvidence时，才Update Incident 计数并触发 SOAR Playbook
 	if newAlertsCount > 0 {
-		db.Model(&incident).Updates(map[string]interface{}{
+		updates := map[string]interface{}{
 			"alert_count": incident.AlertCount + newAlertsCount,
 			"last_seen":   now,
-		})
+		}
+		if escalated != "" && HigherSeverity(incident.Severity, escalated) != incident.Severity {
+			database.LogIncidentActivity(db, model.IncidentActivity{
+				IncidentID: incident.ID,
+				Type:       model.ActivitySeverity,
+				From:       incident.Severity,
+				To:         escalated,
+				Detail:     escalatedBy,
+			})
+			incident.Severity = escalated
+			updates["severity"] = escalated
+		}
+		db.Model(&incident).Updates(updates)
+		_, bumped := updates["severity"]
+		if bumped {
+			database.RefreshSLA(db, &incident)
+		}
+		switch {
+		case created:
+			go notify.Incident(model.NotifyIncidentCreated, incident, fmt.Sprintf("rule %q matched", rule.Name))
+		case bumped:
+			go notify.Incident(model.NotifyIncidentEscalated, incident, escalatedBy)
+		}
 		go automation.DispatchByIncident(incident)
 	}
+	return newAlertsCount + riskOnly
 }
 
-// ExecuteRuleWithQuery 使用指定QueryExecuteRule（用于回溯）
-func ExecuteRuleWithQuery(rule model.Rule, query string) {
-	vLogsAddr := viper.GetString("victorialogs.url")
-	if vLogsAddr == "" {
-		vLogsAddr = "http://127.0.0.1:9428"
-	}
+// InheritAttack NewIncident 继承Rule的 MITRE ATT&CK 映射
+func InheritAttack(incident *model.Incident) {
+	tags := database.LoadRuleTags(database.GetDB(), incident.RuleID)[incident.RuleID]
+	mapping := attack.Default().Resolve(tags)
+	incident.Tactics = strings.Join(mapping.Tactics, ",")
+	incident.Techniques = strings.Join(mapping.Techniques, ",")
+}
 
+// ExecuteRuleWithQuery 使用指定QueryExecuteRule（用于回溯），Return本次New增的Alert数
+func ExecuteRuleWithQuery(rule model.Rule, query string) (int, error) {
 	finalQuery := strings.TrimSpace(query)
 	log.Printf("[Rule:%d][Backtrace] Executing: %s", rule.ID, finalQuery)
 
-	// Send给 VictoriaLogs
-	resp, err := http.PostForm(vLogsAddr+"/select/logsql/query", url.Values{
-		"query": {finalQuery},
-		"limit": {"1000"},
-	})
+	exec := model.RuleExecution{RuleID: rule.ID, Kind: ExecBacktrace, StartedAt: time.Now()}
+	body, err := queryVictoriaLogs(finalQuery)
 	if err != nil {
-		log.Printf("[Rule:%d][Backtrace] Request failed: %v", rule.ID, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if len(body) == 0 {
-		return
+		log.Printf("[Rule:%d][Backtrace] %v | Query: %s", rule.ID, err, finalQuery)
+		exec.Error = err.Error()
+	} else if body != "" {
+		exec.Rows = countRows(body)
+		exec.NewAlerts = saveAlert(rule, body)
 	}
+	exec.DurationMs = time.Since(exec.StartedAt).Milliseconds()
 
-	if resp.StatusCode >= 400 {
-		log.Printf("[Rule:%d][Backtrace] Query Syntax Error: %s | Query: %s", rule.ID, string(body), finalQuery)
-		return
+	recordExecution(rule, exec)
+	if err != nil {
+		return 0, err
 	}
-
-	saveAlert(rule, string(body))
-}
\ No newline at end of file
+	return exec.NewAlerts, nil
+}
@@ -0,0 +1,312 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+)
+
+var placeholder = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// 替换进 LogSQL 引号内的值需要转义
+var logsqlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+var severityRank = map[string]int{"info": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// HigherSeverity 比较两个严重级别，Return较高者
+func HigherSeverity(a, b string) string {
+	if severityRank[strings.ToLower(b)] > severityRank[strings.ToLower(a)] {
+		return b
+	}
+	return a
+}
+
+// followUpOutcome 一条Alert的全部后续Query结果
+type followUpOutcome struct {
+	Results  []model.FollowUpResult
+	Drop     bool   // require 未满足
+	Severity string // escalate 命Medium后的级别
+}
+
+var followUpClient = &http.Client{Timeout: 15 * time.Second}
+
+// ValidateFollowUps 补全DefaultValue并校验后续Query定义
+func ValidateFollowUps(items []model.FollowUpQuery) error {
+	for i := range items {
+		f := &items[i]
+		if strings.TrimSpace(f.Query) == "" {
+			return fmt.Errorf("follow_ups[%d]: query is required", i)
+		}
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("follow-up-%d", i+1)
+		}
+		if f.Action == "" {
+			f.Action = model.FollowUpEnrich
+		}
+		switch f.Action {
+		case model.FollowUpEnrich, model.FollowUpRequire:
+		case model.FollowUpEscalate:
+			if _, ok := severityRank[strings.ToLower(f.Severity)]; !ok {
+				return fmt.Errorf("follow_ups[%d]: escalate requires a severity (info/low/medium/high/critical)", i)
+			}
+		default:
+			return fmt.Errorf("follow_ups[%d]: unsupported action %s", i, f.Action)
+		}
+		for _, d := range []string{f.Before, f.After} {
+			if d == "" {
+				continue
+			}
+			if v, err := time.ParseDuration(d); err != nil || v < 0 {
+				return fmt.Errorf("follow_ups[%d]: invalid duration %q", i, d)
+			}
+		}
+		if f.MinRows <= 0 {
+			f.MinRows = 1
+		}
+	}
+	return nil
+}
+
+// FlattenEvent 将嵌套Event展开为 a.b.c => Value，用于 ${var} 替换
+func FlattenEvent(data map[string]interface{}, prefix string, result map[string]string) {
+	for k, v := range data {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch child := v.(type) {
+		case map[string]interface{}:
+			FlattenEvent(child, key, result)
+		case string:
+			result[key] = child
+		case float64, int, bool:
+			result[key] = fmt.Sprintf("%v", child)
+		}
+	}
+}
+
+// runFollowUps 对一条NewAlert依次Execute Rule的后续Query
+func runFollowUps(rule model.Rule, line string, now time.Time) *followUpOutcome {
+	outcome := &followUpOutcome{}
+	var event map[string]interface{}
+	json.Unmarshal([]byte(line), &event)
+	vars := make(map[string]string)
+	FlattenEvent(event, "", vars)
+
+	at := eventTime(vars, now)
+	for _, f := range rule.FollowUps {
+		res := executeFollowUp(f, vars, at)
+		outcome.Results = append(outcome.Results, res)
+		if res.Error != "" {
+			// Query出错时不改变Alert (fail open)，只记录Error
+			continue
+		}
+		switch f.Action {
+		case model.FollowUpRequire:
+			if !res.Matched {
+				outcome.Drop = true
+			}
+		case model.FollowUpEscalate:
+			if res.Matched {
+				outcome.Severity = HigherSeverity(outcome.Severity, f.Severity)
+			}
+		}
+	}
+	return outcome
+}
+
+func executeFollowUp(f model.FollowUpQuery, vars map[string]string, at time.Time) model.FollowUpResult {
+	before, _ := time.ParseDuration(f.Before)
+	after := followUpAfter(f)
+	minRows := f.MinRows
+	if minRows <= 0 {
+		minRows = 1
+	}
+	res := model.FollowUpResult{Name: f.Name, Action: f.Action, Start: at.Add(-before).UTC(), End: at.Add(after).UTC()}
+
+	var missing []string
+	res.Query = placeholder.ReplaceAllStringFunc(f.Query, func(m string) string {
+		key := m[2 : len(m)-1]
+		switch key {
+		case "start_time":
+			return res.Start.Format(time.RFC3339)
+		case "end_time":
+			return res.End.Format(time.RFC3339)
+		}
+		if v, ok := vars[key]; ok {
+			return logsqlEscaper.Replace(v)
+		}
+		missing = append(missing, key)
+		return m
+	})
+	if len(missing) > 0 {
+		res.Error = "alert has no field " + strings.Join(missing, ", ")
+		return res
+	}
+
+	resp, err := followUpClient.PostForm(victoriaLogsURL()+"/select/logsql/query", url.Values{
+		"query": {res.Query},
+		"start": {res.Start.Format(time.RFC3339Nano)},
+		"end":   {res.End.Format(time.RFC3339Nano)},
+		"limit": {fmt.Sprintf("%d", followUpLimit())},
+	})
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		res.Error = fmt.Sprintf("query error (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return res
+	}
+
+	for _, l := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		res.Rows++
+		if len(res.Sample) < 5 {
+			res.Sample = append(res.Sample, json.RawMessage(l))
+		}
+	}
+	res.Matched = res.Rows >= minRows
+	return res
+}
+
+// eventTime Alert的 _time，缺失时使用检测Time
+func eventTime(vars map[string]string, now time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, vars["_time"]); err == nil {
+		return t
+	}
+	return now
+}
+
+func followUpAfter(f model.FollowUpQuery) time.Duration {
+	if f.After == "" {
+		return 10 * time.Minute
+	}
+	after, _ := time.ParseDuration(f.After)
+	return after
+}
+
+// followUpDue 全部后续Query窗口结束的Time，再留出 scheduler.follow_up.delay 给Log写入
+func followUpDue(rule model.Rule, line string, now time.Time) time.Time {
+	var event map[string]interface{}
+	json.Unmarshal([]byte(line), &event)
+	vars := make(map[string]string)
+	FlattenEvent(event, "", vars)
+
+	at := eventTime(vars, now)
+	due := at
+	for _, f := range rule.FollowUps {
+		if end := at.Add(followUpAfter(f)); end.After(due) {
+			due = end
+		}
+	}
+	return due.Add(followUpDelay()).UTC()
+}
+
+// startFollowUpWorker 定期处理已到期的待确认Alert，后续Query不在检测或实时写入协程上Execute
+func startFollowUpWorker() {
+	go func() {
+		ticker := time.NewTicker(followUpInterval())
+		defer ticker.Stop()
+		for now := range ticker.C {
+			resolvePendingAlerts(now.UTC())
+		}
+	}()
+}
+
+// resolvePendingAlerts 为到期的待确认AlertExecute后续Query，按Rule批量写入 Incident/Alert
+func resolvePendingAlerts(now time.Time) {
+	db := database.GetDB()
+	var due []model.PendingAlert
+	db.Where("due_at <= ?", now).Order("due_at asc, id asc").Limit(followUpBatch()).Find(&due)
+	if len(due) == 0 {
+		return
+	}
+
+	byRule := make(map[uint][]model.PendingAlert)
+	var order []uint
+	for _, p := range due {
+		if _, ok := byRule[p.RuleID]; !ok {
+			order = append(order, p.RuleID)
+		}
+		byRule[p.RuleID] = append(byRule[p.RuleID], p)
+	}
+	for _, ruleID := range order {
+		items := byRule[ruleID]
+		ids := make([]uint, 0, len(items))
+		for _, p := range items {
+			ids = append(ids, p.ID)
+		}
+
+		// Rule已Delete时直接丢弃；停用的Rule仍处理已检测到的Event
+		var rule model.Rule
+		if err := db.First(&rule, ruleID).Error; err != nil {
+			db.Delete(&model.PendingAlert{}, ids)
+			continue
+		}
+		lines := make([]string, 0, len(items))
+		outcomes := make([]*followUpOutcome, 0, len(items))
+		for _, p := range items {
+			lines = append(lines, p.Content)
+			outcomes = append(outcomes, runFollowUps(rule, p.Content, p.CreatedAt))
+		}
+		created := storeAlerts(rule, lines, outcomes)
+		db.Delete(&model.PendingAlert{}, ids)
+		log.Printf("[Rule:%d][FollowUp] Resolved %d pending alerts, %d reported", rule.ID, len(items), created)
+	}
+}
+
+// followUpLimit 单次后续Query最多读取的行数
+func followUpLimit() int {
+	if n := viper.GetInt("scheduler.follow_up.limit"); n > 0 {
+		return n
+	}
+	return 100
+}
+
+// followUpDelay 后续Query窗口结束后再等待的Time，给Log写入留出余量
+func followUpDelay() time.Duration {
+	if d := viper.GetDuration("scheduler.follow_up.delay"); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// followUpInterval 检查到期待确认Alert的周期
+func followUpInterval() time.Duration {
+	if d := viper.GetDuration("scheduler.follow_up.interval"); d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// followUpBatch 每个周期最多处理的待确认Alert数
+func followUpBatch() int {
+	if n := viper.GetInt("scheduler.follow_up.batch"); n > 0 {
+		return n
+	}
+	return 200
+}
+
+// followUpBudget 单次Rule Execute最多为多少条NewAlert运行后续Query，超出的Alert直接上报
+func followUpBudget() int {
+	if n := viper.GetInt("scheduler.follow_up.max_alerts_per_run"); n > 0 {
+		return n
+	}
+	return 50
+}
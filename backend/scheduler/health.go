@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/laenix/vsentry/automation"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Execute记录Type
+const (
+	ExecScheduled = "scheduled"
+	ExecBacktrace = "backtrace"
+	ExecStream    = "stream" // 实时Rule每分钟汇总一条
+)
+
+// healthKind 计入健康度的Execute记录Type
+func healthKind(rule model.Rule) string {
+	if rule.Type == RuleTypeStream {
+		return ExecStream
+	}
+	return ExecScheduled
+}
+
+// IncidentSourcePlatform 平台自身产生的Incident
+const IncidentSourcePlatform = "platform"
+
+// healthPolicy Rule健康度策略 (config.yaml scheduler.rule_health)
+type healthPolicy struct {
+	IncidentAfter    int // 连续Failed N 次后产生平台Incident
+	AutoDisableAfter int // 连续Failed N 次后自动停用Rule，0 表示不停用
+	RetentionDays    int // Execute记录保留days数
+	Window           int // 健康度统计窗口 (最近 N 次Execute)
+}
+
+func loadHealthPolicy() healthPolicy {
+	p := healthPolicy{
+		IncidentAfter:    viper.GetInt("scheduler.rule_health.incident_after"),
+		AutoDisableAfter: viper.GetInt("scheduler.rule_health.auto_disable_after"),
+		RetentionDays:    viper.GetInt("scheduler.rule_health.retention_days"),
+		Window:           viper.GetInt("scheduler.rule_health.window"),
+	}
+	if p.IncidentAfter <= 0 {
+		p.IncidentAfter = 3
+	}
+	if p.RetentionDays <= 0 {
+		p.RetentionDays = 7
+	}
+	if p.Window <= 0 {
+		p.Window = 100
+	}
+	return p
+}
+
+// recordExecution 持久化Execute记录；定时Execute连续Failed时产生平台Incident，并按策略自动停用Rule
+func recordExecution(rule model.Rule, exec model.RuleExecution) {
+	db := database.GetDB()
+	policy := loadHealthPolicy()
+
+	if err := db.Create(&exec).Error; err != nil {
+		log.Printf("[Rule:%d] Failed to record execution: %v", rule.ID, err)
+		return
+	}
+	db.Where("rule_id = ? AND started_at < ?", rule.ID, time.Now().AddDate(0, 0, -policy.RetentionDays)).
+		Delete(&model.RuleExecution{})
+
+	// 回溯Failed由回溯Task自身记录，不计入连续Failed
+	if exec.Kind != healthKind(rule) || exec.Error == "" {
+		return
+	}
+
+	failures := consecutiveFailures(db, rule.ID, exec.Kind)
+	if failures < policy.IncidentAfter {
+		return
+	}
+
+	disabled := false
+	if policy.AutoDisableAfter > 0 && failures >= policy.AutoDisableAfter {
+		db.Model(&model.Rule{}).Where("id = ?", rule.ID).Updates(map[string]interface{}{"enabled": false, "auto_disabled": true})
+		disabled = true
+		log.Printf("[Rule:%d] Auto-disabled after %d consecutive failures", rule.ID, failures)
+		// 在 cron Task内部调用，异步重载避免阻塞当ago调度
+		go GlobalEngine.ReloadRules()
+	}
+
+	raiseRuleFailureIncident(rule, exec, failures, disabled)
+}
+
+// consecutiveFailures 最近一次成功之后的定时ExecuteFailed次数
+func consecutiveFailures(db *gorm.DB, ruleID uint, kind string) int {
+	var lastOK model.RuleExecution
+	db.Select("id").Where("rule_id = ? AND kind = ? AND error = ?", ruleID, kind, "").
+		Order("id desc").Limit(1).Find(&lastOK)
+
+	var count int64
+	db.Model(&model.RuleExecution{}).
+		Where("rule_id = ? AND kind = ? AND id > ?", ruleID, kind, lastOK.ID).
+		Count(&count)
+	return int(count)
+}
+
+// raiseRuleFailureIncident 为持续Failed的Rule产生平台Incident，同一Rule复用未Resolve的Incident
+func raiseRuleFailureIncident(rule model.Rule, exec model.RuleExecution, failures int, disabled bool) {
+	db := database.GetDB()
+	now := time.Now().UTC()
+	name := fmt.Sprintf("Rule #%d execution failing", rule.ID)
+
+	var incident model.Incident
+	created := false
+	err := db.Where("source = ? AND name = ? AND status != ?", IncidentSourcePlatform, name, "resolved").
+		Order("last_seen desc").First(&incident).Error
+	if err != nil {
+		incident = model.Incident{
+			Name:      name,
+			Severity:  "medium",
+			Status:    "new",
+			Source:    IncidentSourcePlatform,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		database.ApplySLA(db, &incident)
+		if err := db.Create(&incident).Error; err != nil {
+			log.Printf("[Rule:%d] Failed to create platform incident: %v", rule.ID, err)
+			return
+		}
+		created = true
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incident.ID,
+			Type:       model.ActivityCreated,
+			To:         incident.Status,
+			Detail:     fmt.Sprintf("rule %q failed %d times in a row", rule.Name, failures),
+		})
+	}
+
+	msg := fmt.Sprintf("Rule %q failed %d times in a row: %s", rule.Name, failures, exec.Error)
+	if disabled {
+		msg += " (rule auto-disabled)"
+	}
+	content, _ := json.Marshal(map[string]interface{}{
+		"_time":                exec.StartedAt.UTC().Format(time.RFC3339),
+		"_msg":                 msg,
+		"rule_id":              rule.ID,
+		"rule_name":            rule.Name,
+		"query":                rule.Query,
+		"error":                exec.Error,
+		"consecutive_failures": failures,
+		"auto_disabled":        disabled,
+	})
+
+	alert := model.Alert{
+		IncidentID:  incident.ID,
+		RuleID:      rule.ID,
+		Content:     string(content),
+		Fingerprint: fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("rule-exec-%d", exec.ID)))),
+	}
+	if err := db.Create(&alert).Error; err != nil {
+		return
+	}
+	db.Model(&incident).Updates(map[string]interface{}{
+		"alert_count": incident.AlertCount + 1,
+		"last_seen":   now,
+	})
+
+	// 只在Incident首次产生时触发 Playbook，避免every次Failed都重复通知
+	if created {
+		go notify.Incident(model.NotifyIncidentCreated, incident, msg)
+		go automation.DispatchByIncident(incident)
+	}
+}
+
+// RuleHealthSummary 基于最近 N 次定时Execute (实时Rule为every分钟汇总) 汇总Rule健康度
+func RuleHealthSummary(rule model.Rule) model.RuleHealth {
+	db := database.GetDB()
+	policy := loadHealthPolicy()
+
+	health := model.RuleHealth{RuleID: rule.ID, RuleName: rule.Name, Enabled: rule.Enabled, Status: "never_run"}
+
+	kind := healthKind(rule)
+	var execs []model.RuleExecution
+	db.Where("rule_id = ? AND kind = ?", rule.ID, kind).
+		Order("id desc").Limit(policy.Window).Find(&execs)
+	if len(execs) == 0 {
+		return health
+	}
+
+	health.LastRun = &execs[0].StartedAt
+	health.LastError = execs[0].Error
+	health.Executions = len(execs)
+
+	durations := make([]int64, 0, len(execs))
+	totalRows := 0
+	for i := range execs {
+		e := &execs[i]
+		durations = append(durations, e.DurationMs)
+		totalRows += e.Rows
+		if e.Error != "" {
+			health.Failures++
+		} else if health.LastSuccess == nil {
+			health.LastSuccess = &e.StartedAt
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	health.P95DurationMs = durations[(len(durations)*95+99)/100-1]
+	health.AvgRows = float64(totalRows) / float64(len(execs))
+
+	// 窗口内找不到成功记录时，连续Failed次数可能超过窗口，需单独统计
+	if health.LastSuccess == nil {
+		var lastOK model.RuleExecution
+		if db.Where("rule_id = ? AND kind = ? AND error = ?", rule.ID, kind, "").
+			Order("id desc").Limit(1).Find(&lastOK).RowsAffected > 0 {
+			health.LastSuccess = &lastOK.StartedAt
+		}
+	}
+	health.ConsecutiveFailures = consecutiveFailures(db, rule.ID, kind)
+
+	health.Status = "healthy"
+	if health.ConsecutiveFailures > 0 {
+		health.Status = "failing"
+	}
+	return health
+}
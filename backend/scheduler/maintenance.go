@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/robfig/cron/v3"
+)
+
+// 维护窗口的周期使用标准 5 段 cron (分 时 日 月 周)
+var windowParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var (
+	conditionMu    sync.Mutex
+	conditionCache = make(map[string]*vm.Program)
+)
+
+// ValidateMaintenanceWindow 校验并补全维护窗口定义
+func ValidateMaintenanceWindow(w *model.MaintenanceWindow) error {
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if w.Action == "" {
+		w.Action = model.MaintenanceSuppress
+	}
+	if w.Action != model.MaintenanceSkip && w.Action != model.MaintenanceSuppress {
+		return fmt.Errorf("action must be skip or suppress")
+	}
+	if _, err := windowLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time_zone %q: %v", w.TimeZone, err)
+	}
+
+	switch {
+	case w.Schedule != "":
+		if _, err := windowParser.Parse(w.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule %q: %v", w.Schedule, err)
+		}
+		if w.DurationMinutes <= 0 {
+			return fmt.Errorf("duration_minutes is required for recurring windows")
+		}
+	case w.StartsAt != nil && w.EndsAt != nil:
+		if !w.EndsAt.After(*w.StartsAt) {
+			return fmt.Errorf("ends_at must be after starts_at")
+		}
+	default:
+		return fmt.Errorf("either schedule + duration_minutes or starts_at + ends_at is required")
+	}
+
+	for _, id := range splitList(w.RuleIDs) {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return fmt.Errorf("invalid rule id %q", id)
+		}
+	}
+	if w.Condition != "" {
+		if _, err := compileCondition(w.Condition); err != nil {
+			return fmt.Errorf("invalid condition: %v", err)
+		}
+	}
+	return nil
+}
+
+func windowLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// WindowActive 判断维护窗口在 at 时刻是否生效，生效时Return本次窗口的起止Time
+func WindowActive(w model.MaintenanceWindow, at time.Time) (time.Time, time.Time, bool) {
+	if !w.Enabled {
+		return time.Time{}, time.Time{}, false
+	}
+	if w.Schedule == "" {
+		if w.StartsAt == nil || w.EndsAt == nil || at.Before(*w.StartsAt) || !at.Before(*w.EndsAt) {
+			return time.Time{}, time.Time{}, false
+		}
+		return *w.StartsAt, *w.EndsAt, true
+	}
+
+	loc, err := windowLocation(w.TimeZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	schedule, err := windowParser.Parse(w.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	// 在窗口时区内计算：(at-duration, at] 之间存在一次开始Time即为生效
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	start := schedule.Next(at.In(loc).Add(-duration))
+	if start.After(at) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(duration), true
+}
+
+// ruleInScope 按 RuleIDs / Tags 判断Rule是否在维护窗口范围内
+func ruleInScope(w model.MaintenanceWindow, rule model.Rule, tags []string) bool {
+	if ids := splitList(w.RuleIDs); len(ids) > 0 {
+		found := false
+		for _, id := range ids {
+			if id == strconv.FormatUint(uint64(rule.ID), 10) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if want := splitList(w.Tags); len(want) > 0 {
+		for _, t := range want {
+			for _, have := range tags {
+				if strings.EqualFold(t, have) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// maintenanceFor 作用于该Rule的全部已Enable维护窗口 (不判断Time)
+func maintenanceFor(rule model.Rule) []model.MaintenanceWindow {
+	db := database.GetDB()
+	var windows []model.MaintenanceWindow
+	if err := db.Where("enabled = ?", true).Find(&windows).Error; err != nil || len(windows) == 0 {
+		return nil
+	}
+	tags := rule.Tags
+	if tags == nil {
+		tags = database.LoadRuleTags(db, rule.ID)[rule.ID]
+	}
+	scoped := windows[:0]
+	for _, w := range windows {
+		if ruleInScope(w, rule, tags) {
+			scoped = append(scoped, w)
+		}
+	}
+	return scoped
+}
+
+// skipWindow Rule当ago是否处于 skip 维护窗口 (无事件条件)，是则整次Execute跳过
+func skipWindow(rule model.Rule, now time.Time) *model.MaintenanceWindow {
+	for _, w := range maintenanceFor(rule) {
+		if w.Action != model.MaintenanceSkip || w.Condition != "" {
+			continue
+		}
+		if _, _, ok := WindowActive(w, now); ok {
+			return &w
+		}
+	}
+	return nil
+}
+
+// matchMaintenance 返回命中该条Event的维护窗口；Event带 _time 时按Event发生Time判断
+func matchMaintenance(windows []model.MaintenanceWindow, line string, now time.Time) *model.MaintenanceWindow {
+	if len(windows) == 0 {
+		return nil
+	}
+	var event map[string]interface{}
+	json.Unmarshal([]byte(line), &event)
+	at := now
+	if ts, ok := event["_time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			at = t
+		}
+	}
+
+	for i := range windows {
+		w := &windows[i]
+		if _, _, ok := WindowActive(*w, at); !ok {
+			continue
+		}
+		if w.Condition == "" {
+			return w
+		}
+		program, err := compileCondition(w.Condition)
+		if err != nil || event == nil {
+			continue
+		}
+		if out, err := expr.Run(program, event); err == nil {
+			if matched, _ := out.(bool); matched {
+				return w
+			}
+		}
+	}
+	return nil
+}
+
+func compileCondition(condition string) (*vm.Program, error) {
+	conditionMu.Lock()
+	defer conditionMu.Unlock()
+	if program, ok := conditionCache[condition]; ok {
+		return program, nil
+	}
+	program, err := CompileStreamQuery(condition)
+	if err != nil {
+		return nil, err
+	}
+	conditionCache[condition] = program
+	return program, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MutedEntry 当ago生效的维护窗口及受影响的Rule
+type MutedEntry struct {
+	Window   model.MaintenanceWindow `json:"window"`
+	Since    time.Time               `json:"since"`
+	Until    time.Time               `json:"until"`
+	RuleIDs  []uint                  `json:"rule_ids"`
+	AllRules bool                    `json:"all_rules"`
+	// 带事件条件的窗口只静默匹配条件的Event，Rule本身仍会Execute
+	Conditional bool `json:"conditional"`
+}
+
+// ActiveMaintenance 列出当ago生效的维护窗口
+func ActiveMaintenance(now time.Time) []MutedEntry {
+	db := database.GetDB()
+	result := []MutedEntry{}
+	var windows []model.MaintenanceWindow
+	db.Where("enabled = ?", true).Order("id asc").Find(&windows)
+	if len(windows) == 0 {
+		return result
+	}
+
+	var rules []model.Rule
+	db.Select("id", "name").Find(&rules)
+	database.FillRuleTags(db, rules)
+
+	for _, w := range windows {
+		since, until, ok := WindowActive(w, now)
+		if !ok {
+			continue
+		}
+		entry := MutedEntry{
+			Window:      w,
+			Since:       since,
+			Until:       until,
+			RuleIDs:     []uint{},
+			AllRules:    w.RuleIDs == "" && w.Tags == "",
+			Conditional: w.Condition != "",
+		}
+		if !entry.AllRules {
+			for _, r := range rules {
+				if ruleInScope(w, r, r.Tags) {
+					entry.RuleIDs = append(entry.RuleIDs, r.ID)
+				}
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,277 @@
+package scheduler
+
+// ==============================================================================
+// 基于风险的告警 (RBA)
+// Rule为Alert中提取出的实体 (用户 / 主机 / IP) 累加风险分，风险分按半衰期衰减；
+// 实体在策略窗口内累计的风险分越过阈值时产生 Incident (source=risk)。
+// ==============================================================================
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/automation"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/spf13/viper"
+)
+
+// IncidentSourceRisk 风险阈值产生的Incident
+const IncidentSourceRisk = "risk"
+
+// defaultRiskFields 未配置 Risk.Entities 时按顺序尝试的 OCSF 字段，every种Type取第一个有Value的
+var defaultRiskFields = map[string][]string{
+	model.RiskEntityUser: {"actor.user.name", "user.name", "target_user.name"},
+	model.RiskEntityHost: {"device.hostname", "observer.hostname", "host.name", "hostname"},
+	model.RiskEntityIP:   {"src_endpoint.ip", "device.ip", "src_ip"},
+}
+
+// riskMu 串行化实体风险分的读改写
+var riskMu sync.Mutex
+
+// ValidateRiskSettings 校验Rule的风险配置
+func ValidateRiskSettings(s model.RiskSettings) error {
+	if s.Score < 0 {
+		return fmt.Errorf("risk score must not be negative")
+	}
+	if s.Only && s.Score == 0 {
+		return fmt.Errorf("risk.only requires a risk score")
+	}
+	for _, item := range splitList(s.Entities) {
+		typ, field, ok := strings.Cut(item, ":")
+		if !ok || field == "" {
+			return fmt.Errorf("invalid risk entity %q, expected type:field", item)
+		}
+		if _, known := defaultRiskFields[typ]; !known {
+			return fmt.Errorf("unsupported risk entity type %q (user/host/ip)", typ)
+		}
+	}
+	return nil
+}
+
+// riskHalfLife 风险分半衰期
+func riskHalfLife() time.Duration {
+	if h := viper.GetFloat64("risk.half_life_hours"); h > 0 {
+		return time.Duration(h * float64(time.Hour))
+	}
+	return 24 * time.Hour
+}
+
+// riskRetentionDays 风险贡献明细保留days数，需大于最长的策略窗口
+func riskRetentionDays() int {
+	if d := viper.GetInt("risk.retention_days"); d > 0 {
+		return d
+	}
+	return 30
+}
+
+// DecayedScore 实体在 at 时刻的风险分
+func DecayedScore(e model.RiskEntity, at time.Time) float64 {
+	if e.ScoredAt.IsZero() || !at.After(e.ScoredAt) {
+		return e.Score
+	}
+	return e.Score * math.Pow(0.5, float64(at.Sub(e.ScoredAt))/float64(riskHalfLife()))
+}
+
+// riskEntities 从Alert内容Medium提取实体 (type => value)
+func riskEntities(rule model.Rule, content string) map[string]string {
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &event); err != nil {
+		return nil
+	}
+	vars := make(map[string]string)
+	FlattenEvent(event, "", vars)
+
+	result := make(map[string]string)
+	if custom := splitList(rule.Risk.Entities); len(custom) > 0 {
+		for _, item := range custom {
+			typ, field, _ := strings.Cut(item, ":")
+			if v := strings.TrimSpace(vars[field]); v != "" && result[typ] == "" {
+				result[typ] = v
+			}
+		}
+		return result
+	}
+	for typ, fields := range defaultRiskFields {
+		for _, f := range fields {
+			if v := strings.TrimSpace(vars[f]); v != "" {
+				result[typ] = v
+				break
+			}
+		}
+	}
+	return result
+}
+
+// addRisk 为Alert提取的实体累加风险分，并检查风险阈值策略
+func addRisk(rule model.Rule, alert model.Alert) {
+	if rule.Risk.Score <= 0 {
+		return
+	}
+	entities := riskEntities(rule, alert.Content)
+	if len(entities) == 0 {
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now().UTC()
+
+	riskMu.Lock()
+	defer riskMu.Unlock()
+	db.Where("created_at < ?", now.AddDate(0, 0, -riskRetentionDays())).Delete(&model.RiskEvent{})
+	for typ, value := range entities {
+		ev := model.RiskEvent{
+			EntityType: typ,
+			Entity:     value,
+			RuleID:     rule.ID,
+			RuleName:   rule.Name,
+			AlertID:    alert.ID,
+			Score:      rule.Risk.Score,
+			CreatedAt:  now,
+		}
+		if err := db.Create(&ev).Error; err != nil {
+			log.Printf("[Risk] Failed to record risk event: %v", err)
+			continue
+		}
+
+		var entity model.RiskEntity
+		db.Where("type = ? AND value = ?", typ, value).FirstOrInit(&entity, model.RiskEntity{Type: typ, Value: value})
+		entity.Score = DecayedScore(entity, now) + rule.Risk.Score
+		entity.ScoredAt = now
+		entity.LastSeen = now
+		entity.Contributions++
+		if err := db.Save(&entity).Error; err != nil {
+			log.Printf("[Risk] Failed to update %s %s: %v", typ, value, err)
+			continue
+		}
+		checkRiskPolicies(&entity, ev, now)
+	}
+}
+
+// checkRiskPolicies 本次贡献使窗口内累计分从阈值以下越过阈值时产生 Incident
+func checkRiskPolicies(entity *model.RiskEntity, ev model.RiskEvent, now time.Time) {
+	db := database.GetDB()
+	var policies []model.RiskPolicy
+	db.Where("enabled = ? AND (entity_type = ? OR entity_type = ?)", true, "", entity.Type).Find(&policies)
+
+	for _, p := range policies {
+		if p.Threshold <= 0 || p.WindowHours <= 0 {
+			continue
+		}
+		since := now.Add(-time.Duration(p.WindowHours) * time.Hour)
+		var total float64
+		db.Model(&model.RiskEvent{}).
+			Where("entity_type = ? AND entity = ? AND created_at >= ?", entity.Type, entity.Value, since).
+			Select("COALESCE(SUM(score), 0)").Scan(&total)
+		if total < p.Threshold || total-ev.Score >= p.Threshold {
+			continue
+		}
+		raiseRiskIncident(entity, p, ev, total, since, now)
+	}
+}
+
+// raiseRiskIncident 同一实体复用未Resolve的风险Incident，Alert内容为窗口内的贡献明细
+func raiseRiskIncident(entity *model.RiskEntity, p model.RiskPolicy, ev model.RiskEvent, total float64, since, now time.Time) {
+	db := database.GetDB()
+	name := fmt.Sprintf("Risk threshold exceeded: %s %s", entity.Type, entity.Value)
+
+	var contributions []model.RiskEvent
+	db.Where("entity_type = ? AND entity = ? AND created_at >= ?", entity.Type, entity.Value, since).
+		Order("id asc").Limit(200).Find(&contributions)
+	rules := make(map[string]float64)
+	for _, c := range contributions {
+		rules[c.RuleName] += c.Score
+	}
+
+	var incident model.Incident
+	created := false
+	err := db.Where("source = ? AND name = ? AND status != ?", IncidentSourceRisk, name, "resolved").
+		Order("last_seen desc").First(&incident).Error
+	if err != nil {
+		incident = model.Incident{
+			Name:      name,
+			Severity:  p.Severity,
+			Status:    "new",
+			Source:    IncidentSourceRisk,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		database.ApplySLA(db, &incident)
+		if err := db.Create(&incident).Error; err != nil {
+			log.Printf("[Risk] Failed to create incident: %v", err)
+			return
+		}
+		created = true
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incident.ID,
+			Type:       model.ActivityCreated,
+			To:         incident.Status,
+			Detail:     fmt.Sprintf("risk policy %q: %.1f points in %dh", p.Name, total, p.WindowHours),
+		})
+	}
+
+	content, _ := json.Marshal(map[string]interface{}{
+		"_time":         now.Format(time.RFC3339),
+		"_msg":          fmt.Sprintf("%s %s accumulated %.1f risk points in %dh (threshold %.1f)", entity.Type, entity.Value, total, p.WindowHours, p.Threshold),
+		"entity_type":   entity.Type,
+		"entity":        entity.Value,
+		"risk_total":    total,
+		"risk_score":    entity.Score,
+		"policy":        p.Name,
+		"threshold":     p.Threshold,
+		"window_hours":  p.WindowHours,
+		"rules":         rules,
+		"contributions": contributions,
+	})
+	alert := model.Alert{
+		IncidentID:  incident.ID,
+		Content:     string(content),
+		Fingerprint: fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("risk-%d-%d", p.ID, ev.ID)))),
+	}
+	if err := db.Create(&alert).Error; err != nil {
+		return
+	}
+	db.Model(&incident).Updates(map[string]interface{}{
+		"alert_count": incident.AlertCount + 1,
+		"last_seen":   now,
+	})
+	entity.IncidentID = incident.ID
+	entity.ThresholdAt = &now
+	db.Model(entity).Updates(map[string]interface{}{"incident_id": incident.ID, "threshold_at": now})
+
+	log.Printf("[Risk] %s", name)
+	if created {
+		go notify.Incident(model.NotifyIncidentCreated, incident, fmt.Sprintf("risk policy %q: %.1f points in %dh", p.Name, total, p.WindowHours))
+		go automation.DispatchByIncident(incident)
+	}
+}
+
+// RiskyEntities 按当ago衰减后的风险分排序的实体
+func RiskyEntities(entityType string, limit int) []model.RiskEntity {
+	// 超过 10 个半衰期的实体风险分已不足千分之一，不再列出
+	db := database.GetDB().Where("score > 0 AND last_seen >= ?", time.Now().Add(-10*riskHalfLife()))
+	if entityType != "" {
+		db = db.Where("type = ?", entityType)
+	}
+	var entities []model.RiskEntity
+	db.Find(&entities)
+
+	now := time.Now().UTC()
+	for i := range entities {
+		entities[i].Score = math.Round(DecayedScore(entities[i], now)*100) / 100
+		entities[i].ScoredAt = now
+	}
+	sort.Slice(entities, func(i, j int) bool { return entities[i].Score > entities[j].Score })
+	if limit > 0 && len(entities) > limit {
+		entities = entities[:limit]
+	}
+	return entities
+}
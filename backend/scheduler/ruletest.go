@@ -0,0 +1,251 @@
+package scheduler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+)
+
+// 测试样例写入的隔离 stream 字段，every个用例一个 stream：<run_id>-<case>
+const ruleTestStreamField = "vsentry_test"
+
+// ValidateRuleTests 补全DefaultValue并校验测试用例
+func ValidateRuleTests(tests []model.RuleTest) error {
+	for i := range tests {
+		t := &tests[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		if t.Expect == "" {
+			t.Expect = model.RuleTestMatch
+		}
+		if t.Expect != model.RuleTestMatch && t.Expect != model.RuleTestNoMatch {
+			return fmt.Errorf("tests[%d]: expect must be match or no_match", i)
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(t.Event, &event); err != nil || event == nil {
+			return fmt.Errorf("tests[%d]: event must be a JSON object", i)
+		}
+	}
+	return nil
+}
+
+// SupportsRuleTests RuleType是否支持测试用例 (异常Rule依赖历史基线，无法用样例验证)
+func SupportsRuleTests(ruleType string) bool {
+	return ruleType == "" || ruleType == "alert" || ruleType == RuleTypeStream
+}
+
+// RunRuleTests 运行Rule的全部测试用例
+// LogSQL Rule：样例以当ago Time写入 VictoriaLogs 的隔离租户 (every个用例一个 stream)，再在该租户内用 extra_stream_filters
+// 把Rule Query限定在用例的 stream 上执行；
+// 实时Rule：直接对样例求值 expr Expression。
+func RunRuleTests(rule model.Rule) *model.RuleTestReport {
+	started := time.Now()
+	report := &model.RuleTestReport{
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		RunID:    newRunID(),
+		Total:    len(rule.Tests),
+		Cases:    []model.RuleTestCaseResult{},
+	}
+	defer func() {
+		report.DurationMs = time.Since(started).Milliseconds()
+		for _, c := range report.Cases {
+			if !c.Passed {
+				report.Failed++
+			}
+		}
+		report.Passed = report.Error == "" && report.Failed == 0 && report.Total > 0
+	}()
+
+	switch {
+	case len(rule.Tests) == 0:
+		report.Error = "rule has no test cases"
+	case !SupportsRuleTests(rule.Type):
+		report.Error = fmt.Sprintf("%s rules do not support test cases", rule.Type)
+	case rule.Type == RuleTypeStream:
+		runStreamTests(rule, report)
+	default:
+		runLogSQLTests(rule, report)
+	}
+	return report
+}
+
+func newRunID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "rt" + hex.EncodeToString(b)
+}
+
+func runStreamTests(rule model.Rule, report *model.RuleTestReport) {
+	program, err := CompileStreamQuery(rule.Query)
+	if err != nil {
+		report.Error = "invalid expression: " + err.Error()
+		return
+	}
+	for _, t := range rule.Tests {
+		res := model.RuleTestCaseResult{Name: t.Name, Expect: t.Expect}
+		var event map[string]interface{}
+		json.Unmarshal(t.Event, &event)
+		out, err := expr.Run(program, event)
+		if err != nil {
+			res.Error = err.Error()
+		} else if matched, _ := out.(bool); matched {
+			res.Rows = 1
+		}
+		res.Passed = res.Error == "" && (res.Rows > 0) == (t.Expect == model.RuleTestMatch)
+		report.Cases = append(report.Cases, res)
+	}
+}
+
+var ruleTestClient = &http.Client{Timeout: 15 * time.Second}
+
+func runLogSQLTests(rule model.Rule, report *model.RuleTestReport) {
+	now := time.Now().UTC()
+	if _, _, err := ruleTestTenant(); err != nil {
+		report.Error = err.Error()
+		return
+	}
+
+	// 1. 样例写入隔离租户，Time统一改为当ago，使 _time:5m 之类的Rule可以命Medium
+	var body bytes.Buffer
+	for i, t := range rule.Tests {
+		var event map[string]interface{}
+		json.Unmarshal(t.Event, &event)
+		if _, ok := event["raw_data"]; !ok {
+			event["raw_data"] = string(t.Event)
+		}
+		event["time"] = now.Format(time.RFC3339Nano)
+		event[ruleTestStreamField] = fmt.Sprintf("%s-%d", report.RunID, i)
+		line, _ := json.Marshal(event)
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	params := url.Values{
+		"_stream_fields": {ruleTestStreamField},
+		"_msg_field":     {"raw_data"},
+		"_time_field":    {"time"},
+	}
+	resp, err := ruleTestRequest(victoriaLogsURL()+"/insert/jsonline?"+params.Encode(), "application/stream+json", &body)
+	if err != nil {
+		report.Error = "ingest samples: " + err.Error()
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		report.Error = fmt.Sprintf("ingest samples: status %d", resp.StatusCode)
+		return
+	}
+
+	// 2. 等待样例可查询
+	start, end := now.Add(-time.Hour), now.Add(time.Minute)
+	if err := waitForSamples(report.RunID, len(rule.Tests), start, end); err != nil {
+		report.Error = err.Error()
+		return
+	}
+
+	// 3. every个用例单独在自己的 stream 上ExecuteRule Query
+	for i, t := range rule.Tests {
+		res := model.RuleTestCaseResult{Name: t.Name, Expect: t.Expect}
+		stream := fmt.Sprintf(`{%s="%s-%d"}`, ruleTestStreamField, report.RunID, i)
+		out, err := queryScoped(rule.Query, stream, start, end)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Rows = countRows(out)
+		}
+		res.Passed = res.Error == "" && (res.Rows > 0) == (t.Expect == model.RuleTestMatch)
+		report.Cases = append(report.Cases, res)
+	}
+}
+
+// waitForSamples 触发 VictoriaLogs 刷盘并轮询，直到本次写入的样例全部可见
+func waitForSamples(runID string, count int, start, end time.Time) error {
+	if resp, err := ruleTestClient.Get(victoriaLogsURL() + "/internal/force_flush"); err == nil {
+		resp.Body.Close()
+	}
+
+	wait := viper.GetDuration("scheduler.rule_tests.wait")
+	if wait <= 0 {
+		wait = 10 * time.Second
+	}
+	stream := fmt.Sprintf(`{%s=~"%s-.*"}`, ruleTestStreamField, runID)
+	deadline := time.Now().Add(wait)
+	for {
+		out, err := queryScoped("*", stream, start, end)
+		if err != nil {
+			return err
+		}
+		if countRows(out) >= count {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("samples not searchable after %s", wait)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func queryScoped(query, streamFilter string, start, end time.Time) (string, error) {
+	form := url.Values{
+		"query":                {query},
+		"extra_stream_filters": {streamFilter},
+		"start":                {start.Format(time.RFC3339)},
+		"end":                  {end.Format(time.RFC3339)},
+		"limit":                {"1000"},
+	}
+	resp, err := ruleTestRequest(victoriaLogsURL()+"/select/logsql/query", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("query error (%d): %s", resp.StatusCode, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ruleTestTenant 测试样例所在的 VictoriaLogs 租户 (scheduler.rule_tests.account_id / project_id，Default 0:1)；
+// 检测、后续Query、资产清单等生产Query都使用Default租户 0:0，因此看不到样例
+func ruleTestTenant() (string, string, error) {
+	account, project := "0", "1"
+	if viper.IsSet("scheduler.rule_tests.account_id") {
+		account = viper.GetString("scheduler.rule_tests.account_id")
+	}
+	if viper.IsSet("scheduler.rule_tests.project_id") {
+		project = viper.GetString("scheduler.rule_tests.project_id")
+	}
+	if account == "0" && project == "0" {
+		return "", "", fmt.Errorf("rule test tenant must not be the default tenant 0:0")
+	}
+	return account, project, nil
+}
+
+// ruleTestRequest 向测试租户发送 POST 请求
+func ruleTestRequest(target, contentType string, body io.Reader) (*http.Response, error) {
+	account, project, err := ruleTestTenant()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("AccountID", account)
+	req.Header.Set("ProjectID", project)
+	return ruleTestClient.Do(req)
+}
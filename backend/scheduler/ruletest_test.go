@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/vlfake"
+	"github.com/spf13/viper"
+)
+
+func TestRunRuleTests(t *testing.T) {
+	fake := vlfake.New()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	viper.Set("victorialogs.url", srv.URL)
+	defer viper.Set("victorialogs.url", "")
+
+	cases := []model.RuleTest{
+		{Name: "brute force", Expect: model.RuleTestMatch, Event: json.RawMessage(`{"class_uid":3002,"status":"Failure","user":{"name":"admin"}}`)},
+		{Name: "success", Expect: model.RuleTestNoMatch, Event: json.RawMessage(`{"class_uid":3002,"status":"Success","user":{"name":"admin"}}`)},
+		{Name: "other class", Expect: model.RuleTestNoMatch, Event: json.RawMessage(`{"class_uid":4001,"status":"Failure"}`)},
+	}
+
+	t.Run("logsql", func(t *testing.T) {
+		rule := model.Rule{Name: "failed logon", Type: "alert", Query: `_time:5m class_uid:=3002 status:="Failure"`, Tests: cases}
+		report := RunRuleTests(rule)
+		if !report.Passed || report.Failed != 0 || len(report.Cases) != len(cases) {
+			t.Fatalf("report = %+v", report)
+		}
+		if report.Cases[0].Rows != 1 || report.Cases[1].Rows != 0 {
+			t.Errorf("cases = %+v", report.Cases)
+		}
+	})
+
+	t.Run("logsql failing expectation", func(t *testing.T) {
+		rule := model.Rule{Name: "too broad", Type: "alert", Query: `class_uid:=3002`, Tests: cases}
+		report := RunRuleTests(rule)
+		if report.Passed || report.Failed != 1 || report.Cases[1].Passed {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		rule := model.Rule{Name: "failed logon", Type: RuleTypeStream, Query: `class_uid == 3002 && status == "Failure"`, Tests: cases}
+		report := RunRuleTests(rule)
+		if !report.Passed || report.Failed != 0 {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+
+	t.Run("samples stay out of production queries", func(t *testing.T) {
+		if fake.Len() == 0 {
+			t.Fatal("no samples were written")
+		}
+		// 定时Rule通过 queryVictoriaLogs 在Default租户上查询，不应看到测试租户的样例
+		body, err := queryVictoriaLogs(`class_uid:=3002`)
+		if err != nil || countRows(body) != 0 {
+			t.Fatalf("scheduled query saw samples: rows=%d err=%v", countRows(body), err)
+		}
+	})
+
+	t.Run("default tenant rejected", func(t *testing.T) {
+		viper.Set("scheduler.rule_tests.project_id", "0")
+		defer viper.Set("scheduler.rule_tests.project_id", "1")
+		report := RunRuleTests(model.Rule{Type: "alert", Query: "*", Tests: cases})
+		if report.Passed || report.Error == "" {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		report := RunRuleTests(model.Rule{Type: RuleTypeAnomaly, Query: "*", Tests: cases})
+		if report.Passed || report.Error == "" {
+			t.Fatalf("report = %+v", report)
+		}
+	})
+}
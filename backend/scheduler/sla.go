@@ -0,0 +1,326 @@
+package scheduler
+
+// ==============================================================================
+// Incident SLA
+// 后台定时检查未Resolve的 Incident：认领/Resolve超时后按策略升级 (改派、提升级别、
+// 运行Playbook、通知 Webhook)，每项只升级一次；长期无活动的低级别 Incident 自动关闭。
+// ==============================================================================
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/laenix/vsentry/automation"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+	"github.com/spf13/viper"
+)
+
+// SLA 阶段
+const (
+	SLAAcknowledge = "acknowledge"
+	SLAResolve     = "resolve"
+)
+
+var slaWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// slaCheckInterval SLA 检查周期
+func slaCheckInterval() time.Duration {
+	if d := viper.GetDuration("sla.check_interval"); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// startSLAChecker Start SLA 后台检查
+func startSLAChecker() {
+	go func() {
+		ticker := time.NewTicker(slaCheckInterval())
+		defer ticker.Stop()
+		for now := range ticker.C {
+			CheckSLA(now.UTC())
+		}
+	}()
+}
+
+// CheckSLA 检查全部未Resolve的 Incident，处理违约升级和自动关闭
+func CheckSLA(now time.Time) {
+	db := database.GetDB()
+	var list []model.SLAPolicy
+	db.Where("enabled = ?", true).Find(&list)
+	policies := make(map[string]model.SLAPolicy, len(list))
+	for _, p := range list {
+		policies[p.Severity] = p
+	}
+
+	var open []model.Incident
+	db.Where("status != ?", "resolved").Find(&open)
+	for _, inc := range open {
+		p, ok := policies[inc.Severity]
+		if !ok {
+			continue
+		}
+		if p.AutoCloseHours > 0 && now.Sub(lastActivity(inc)) >= time.Duration(p.AutoCloseHours)*time.Hour {
+			autoCloseIncident(inc, p, now)
+			continue
+		}
+		switch {
+		case inc.Status == "new" && !inc.AckBreached && inc.AckDueAt != nil && now.After(*inc.AckDueAt):
+			breachSLA(inc, p, SLAAcknowledge)
+		case !inc.ResolveBreached && inc.ResolveDueAt != nil && now.After(*inc.ResolveDueAt):
+			breachSLA(inc, p, SLAResolve)
+		}
+	}
+}
+
+// lastActivity 最近一次New Alert或处置活动的Time；SLA 检查自身写入的违约和升级记录不算活动
+func lastActivity(inc model.Incident) time.Time {
+	last := inc.LastSeen
+	if last.IsZero() {
+		last = inc.CreatedAt
+	}
+	var acts []model.IncidentActivity
+	database.GetDB().Where("incident_id = ?", inc.ID).
+		Not("actor_id = ? AND (type = ? OR detail = ?)", 0, model.ActivitySLA, slaEscalationDetail).
+		Order("id desc").Limit(1).Find(&acts)
+	if len(acts) > 0 && acts[0].CreatedAt.After(last) {
+		last = acts[0].CreatedAt
+	}
+	return last
+}
+
+// slaEscalationDetail SLA 违约升级写入的指派/级别变更记录
+const slaEscalationDetail = "SLA escalation"
+
+// breachSLA 标记违约并按策略升级
+func breachSLA(inc model.Incident, p model.SLAPolicy, stage string) {
+	db := database.GetDB()
+	flag := "ack_breached"
+	due := inc.AckDueAt
+	limit := p.AckMinutes
+	if stage == SLAResolve {
+		flag, due, limit = "resolve_breached", inc.ResolveDueAt, p.ResolveMinutes
+	}
+	if err := db.Model(&inc).Update(flag, true).Error; err != nil {
+		return
+	}
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: inc.ID,
+		Type:       model.ActivitySLA,
+		To:         stage,
+		Detail:     fmt.Sprintf("%s SLA of %dm breached (due %s)", stage, limit, due.UTC().Format(time.RFC3339)),
+	})
+	log.Printf("[SLA] Incident %d breached %s SLA", inc.ID, stage)
+
+	if p.EscalateAssignee != 0 && p.EscalateAssignee != inc.Assignee {
+		var users []model.User
+		db.Select("id", "user_name").Where("id IN ?", []uint{inc.Assignee, p.EscalateAssignee}).Find(&users)
+		names := make(map[uint]string)
+		for _, u := range users {
+			names[u.ID] = u.UserName
+		}
+		from := inc.Assignee
+		if names[p.EscalateAssignee] != "" && db.Model(&inc).Update("assignee", p.EscalateAssignee).Error == nil {
+			database.LogIncidentActivity(db, model.IncidentActivity{
+				IncidentID: inc.ID,
+				Type:       model.ActivityAssignee,
+				From:       names[from],
+				To:         names[p.EscalateAssignee],
+				Detail:     slaEscalationDetail,
+			})
+		}
+	}
+	if p.EscalateSeverity != "" && HigherSeverity(inc.Severity, p.EscalateSeverity) != inc.Severity {
+		from := inc.Severity
+		if db.Model(&inc).Update("severity", p.EscalateSeverity).Error == nil {
+			database.LogIncidentActivity(db, model.IncidentActivity{
+				IncidentID: inc.ID,
+				Type:       model.ActivitySeverity,
+				From:       from,
+				To:         p.EscalateSeverity,
+				Detail:     slaEscalationDetail,
+			})
+			// 截止Time按New级别的 SLA 重新计算
+			inc.Severity = p.EscalateSeverity
+			database.RefreshSLA(db, &inc)
+		}
+	}
+
+	payload := map[string]interface{}{
+		"event":    model.ActivitySLA,
+		"stage":    stage,
+		"due":      due,
+		"incident": inc,
+	}
+	if p.EscalatePlaybookID != 0 {
+		go automation.NewEngine().Run(p.EscalatePlaybookID, map[string]interface{}{"incident": inc, "sla": payload})
+	}
+	if p.EscalateWebhook != "" {
+		go notifySLAWebhook(p.EscalateWebhook, payload)
+	}
+	go notify.Incident(model.NotifySLABreached, inc, fmt.Sprintf("%s SLA of %dm breached (due %s)", stage, limit, due.UTC().Format(time.RFC3339)))
+}
+
+func notifySLAWebhook(url string, payload map[string]interface{}) {
+	body, _ := json.Marshal(payload)
+	resp, err := slaWebhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[SLA] Webhook %s failed: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		log.Printf("[SLA] Webhook %s returned %d", url, resp.StatusCode)
+	}
+}
+
+// autoCloseIncident 关闭长期无活动的 Incident
+func autoCloseIncident(inc model.Incident, p model.SLAPolicy, now time.Time) {
+	db := database.GetDB()
+	comment := fmt.Sprintf("auto-closed after %dh without activity", p.AutoCloseHours)
+	updates := database.IncidentStatusUpdates(inc, "resolved", now)
+	updates["closing_classification"] = "Undetermined"
+	updates["closing_comment"] = comment
+	if err := db.Model(&model.Incident{}).Where("id = ?", inc.ID).Updates(updates).Error; err != nil {
+		return
+	}
+	CloseIncidentAlerts(inc.ID, "")
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: inc.ID,
+		Type:       model.ActivityStatus,
+		From:       inc.Status,
+		To:         "resolved",
+		Detail:     comment,
+	})
+	log.Printf("[SLA] Incident %d %s", inc.ID, comment)
+	inc.Status = "resolved"
+	go notify.Incident(model.NotifyIncidentResolved, inc, comment)
+}
+
+// SLAStat 一组 Incident 的响应统计，MTTA/MTTR 单位为分钟
+type SLAStat struct {
+	Key             string  `json:"key"`
+	Bucket          string  `json:"bucket,omitempty"`
+	Incidents       int     `json:"incidents"`
+	Acknowledged    int     `json:"acknowledged"`
+	Resolved        int     `json:"resolved"`
+	MTTAMinutes     float64 `json:"mtta_minutes"`
+	MTTRMinutes     float64 `json:"mttr_minutes"`
+	AckBreached     int     `json:"ack_breached"`
+	ResolveBreached int     `json:"resolve_breached"`
+
+	ackTotal, resolveTotal time.Duration
+}
+
+func (s *SLAStat) add(inc model.Incident) {
+	s.Incidents++
+	if inc.AcknowledgedAt != nil {
+		s.Acknowledged++
+		s.ackTotal += inc.AcknowledgedAt.Sub(inc.CreatedAt)
+	}
+	if inc.ResolvedAt != nil {
+		s.Resolved++
+		s.resolveTotal += inc.ResolvedAt.Sub(inc.CreatedAt)
+	}
+	if inc.AckBreached {
+		s.AckBreached++
+	}
+	if inc.ResolveBreached {
+		s.ResolveBreached++
+	}
+}
+
+func (s *SLAStat) finish() {
+	if s.Acknowledged > 0 {
+		s.MTTAMinutes = roundMinutes(s.ackTotal / time.Duration(s.Acknowledged))
+	}
+	if s.Resolved > 0 {
+		s.MTTRMinutes = roundMinutes(s.resolveTotal / time.Duration(s.Resolved))
+	}
+}
+
+func roundMinutes(d time.Duration) float64 {
+	return float64(d.Round(time.Second)) / float64(time.Minute)
+}
+
+// SLAStats 统计 [from, to) 内创建的 Incident 的 MTTA/MTTR，按 severity 或 assignee 分组，
+// interval 为 day / week / month 时额外Return按Time分桶的序列
+func SLAStats(from, to time.Time, groupBy, interval string) (summary []SLAStat, series []SLAStat, err error) {
+	if groupBy != "severity" && groupBy != "assignee" {
+		return nil, nil, fmt.Errorf("group_by must be severity or assignee")
+	}
+	bucketOf := map[string]func(time.Time) string{
+		"":      nil,
+		"day":   func(t time.Time) string { return t.Format("2006-01-02") },
+		"week":  func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-W%02d", y, w) },
+		"month": func(t time.Time) string { return t.Format("2006-01") },
+	}
+	bucket, ok := bucketOf[interval]
+	if !ok {
+		return nil, nil, fmt.Errorf("interval must be day, week or month")
+	}
+
+	db := database.GetDB()
+	var incidents []model.Incident
+	db.Where("created_at >= ? AND created_at < ?", from, to).Find(&incidents)
+
+	names := map[uint]string{0: "unassigned"}
+	if groupBy == "assignee" {
+		var users []model.User
+		db.Select("id", "user_name").Find(&users)
+		for _, u := range users {
+			names[u.ID] = u.UserName
+		}
+	}
+	keyOf := func(inc model.Incident) string {
+		if groupBy == "severity" {
+			return inc.Severity
+		}
+		if name, ok := names[inc.Assignee]; ok {
+			return name
+		}
+		return fmt.Sprintf("user#%d", inc.Assignee)
+	}
+
+	groups := make(map[string]*SLAStat)
+	buckets := make(map[[2]string]*SLAStat)
+	for _, inc := range incidents {
+		key := keyOf(inc)
+		if groups[key] == nil {
+			groups[key] = &SLAStat{Key: key}
+		}
+		groups[key].add(inc)
+		if bucket != nil {
+			b := [2]string{key, bucket(inc.CreatedAt.UTC())}
+			if buckets[b] == nil {
+				buckets[b] = &SLAStat{Key: key, Bucket: b[1]}
+			}
+			buckets[b].add(inc)
+		}
+	}
+
+	summary = make([]SLAStat, 0, len(groups))
+	for _, s := range groups {
+		s.finish()
+		summary = append(summary, *s)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Key < summary[j].Key })
+	series = make([]SLAStat, 0, len(buckets))
+	for _, s := range buckets {
+		s.finish()
+		series = append(series, *s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Bucket != series[j].Bucket {
+			return series[i].Bucket < series[j].Bucket
+		}
+		return series[i].Key < series[j].Key
+	})
+	return summary, series, nil
+}
@@ -0,0 +1,312 @@
+package scheduler
+
+// ==============================================================================
+// 实时 (stream) Rule引擎
+// Rule.Query 为 expr 布尔Expression，在 ingest 分发时对every条 OCSF Event求值，
+// 命Medium后数秒内产生Alert，不依赖 VictoriaLogs 轮询。
+// 编译结果按Query缓存，RuleVariable更时随 ReloadRules 热加载；
+// every条Rule单独统计求值次数和 CPU 耗时，按分钟写入Execute记录 (kind=stream)。
+// ==============================================================================
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/spf13/viper"
+)
+
+// RuleTypeStream 实时Rule
+const RuleTypeStream = "stream"
+
+// streamStats 单条Rule的运行计数，Rule热加载时沿用
+type streamStats struct {
+	events    atomic.Int64
+	matches   atomic.Int64
+	errors    atomic.Int64
+	alerts    atomic.Int64
+	cpuNanos  atomic.Int64
+	throttled atomic.Bool
+	lastError atomic.Value // string
+}
+
+type streamRule struct {
+	rule    model.Rule
+	program *vm.Program
+	err     error // 编译Error
+	stats   *streamStats
+}
+
+type streamMatch struct {
+	rule  model.Rule
+	event string
+}
+
+// StreamRuleStats 实时Rule当ago统计窗口内的运行情况
+type StreamRuleStats struct {
+	RuleID       uint    `json:"rule_id"`
+	RuleName     string  `json:"rule_name"`
+	Events       int64   `json:"events"`
+	Matches      int64   `json:"matches"`
+	Errors       int64   `json:"errors"`
+	Alerts       int64   `json:"alerts"`
+	CPUTimeMs    float64 `json:"cpu_time_ms"`
+	AvgEvalUs    float64 `json:"avg_eval_us"`
+	Throttled    bool    `json:"throttled"`
+	CompileError string  `json:"compile_error,omitempty"`
+	LastError    string  `json:"last_error,omitempty"`
+}
+
+var (
+	streamRules   atomic.Pointer[[]*streamRule]
+	streamMu      sync.Mutex // 串行化 reload，保护 programCache
+	programCache  = make(map[string]*vm.Program)
+	streamMatches = make(chan streamMatch, 1000)
+	windowStart   = time.Now()
+)
+
+// nilSafe 把所有字段访问改为可选链 (a.b.c 等价于 a?.b?.c)，Event缺少Medium间字段时结果为 nil 而不是Error
+type nilSafe struct{}
+
+func (nilSafe) Visit(node *ast.Node) {
+	if m, ok := (*node).(*ast.MemberNode); ok && !m.Optional {
+		m.Optional = true
+		ast.Patch(node, &ast.ChainNode{Node: m})
+	}
+}
+
+// CompileStreamQuery 编译实时Rule的 expr Expression，Event字段直接作为Variable (如 process.name == "powershell.exe")
+func CompileStreamQuery(query string) (*vm.Program, error) {
+	return expr.Compile(strings.TrimSpace(query), expr.AsBool(), expr.AllowUndefinedVariables(), expr.Patch(nilSafe{}))
+}
+
+// startStreamEngine Start匹配写入和统计落盘协程
+func startStreamEngine() {
+	go runStreamWriter()
+	go runStreamFlusher()
+}
+
+// reloadStreamRules 重新加载实时Rule，Query未变的Rule复用已编译的程序和统计计数
+func reloadStreamRules() {
+	var rules []model.Rule
+	if err := database.GetDB().Where("enabled = ? AND type = ?", true, RuleTypeStream).Find(&rules).Error; err != nil {
+		log.Printf("[Stream] load error: %v", err)
+		return
+	}
+
+	streamMu.Lock()
+	defer streamMu.Unlock()
+
+	previous := make(map[uint]*streamStats)
+	if old := streamRules.Load(); old != nil {
+		for _, r := range *old {
+			previous[r.rule.ID] = r.stats
+		}
+	}
+
+	cache := make(map[string]*vm.Program, len(rules))
+	set := make([]*streamRule, 0, len(rules))
+	for _, rule := range rules {
+		sr := &streamRule{rule: rule, stats: previous[rule.ID]}
+		if sr.stats == nil {
+			sr.stats = &streamStats{}
+		}
+		key := strings.TrimSpace(rule.Query)
+		if program, ok := programCache[key]; ok {
+			sr.program = program
+		} else if sr.program, sr.err = CompileStreamQuery(key); sr.err != nil {
+			log.Printf("[Stream][Rule:%d] compile error: %v", rule.ID, sr.err)
+		}
+		if sr.program != nil {
+			cache[key] = sr.program
+		}
+		set = append(set, sr)
+	}
+	programCache = cache
+	streamRules.Store(&set)
+	log.Printf("Stream engine: loaded %d rules", len(set))
+}
+
+// EvaluateStream 在 ingest 分发路径上对一条Event求值全部实时Rule，命Medium结果异步写入Alert
+func EvaluateStream(event interface{}) {
+	set := streamRules.Load()
+	if set == nil || len(*set) == 0 {
+		return
+	}
+	env, ok := event.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	var content string
+	for _, sr := range *set {
+		if sr.program == nil || sr.stats.throttled.Load() {
+			continue
+		}
+		start := time.Now()
+		out, err := expr.Run(sr.program, env)
+		sr.stats.cpuNanos.Add(int64(time.Since(start)))
+		sr.stats.events.Add(1)
+		if err != nil {
+			// 字段类型不符 (如 nil >= 3) 按未命Medium处理，只计数
+			sr.stats.errors.Add(1)
+			sr.stats.lastError.Store(err.Error())
+			continue
+		}
+		if matched, _ := out.(bool); !matched {
+			continue
+		}
+		sr.stats.matches.Add(1)
+		if content == "" {
+			raw, _ := json.Marshal(env)
+			content = string(raw)
+		}
+		select {
+		case streamMatches <- streamMatch{rule: sr.rule, event: content}:
+		default:
+			log.Printf("[Stream][Rule:%d] match queue full, dropping event", sr.rule.ID)
+		}
+	}
+}
+
+// runStreamWriter 按Rule聚合短Time内的命Medium，批量写入 Incident/Alert
+func runStreamWriter() {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	pending := make(map[uint][]string)
+	rules := make(map[uint]model.Rule)
+	save := func(id uint) {
+		created := saveAlert(rules[id], strings.Join(pending[id], "\n"))
+		if stats := streamStatsFor(id); stats != nil {
+			stats.alerts.Add(int64(created))
+		}
+		delete(pending, id)
+		delete(rules, id)
+	}
+
+	for {
+		select {
+		case m := <-streamMatches:
+			pending[m.rule.ID] = append(pending[m.rule.ID], m.event)
+			rules[m.rule.ID] = m.rule
+			if len(pending[m.rule.ID]) >= 100 {
+				save(m.rule.ID)
+			}
+		case <-ticker.C:
+			for id := range pending {
+				save(id)
+			}
+		}
+	}
+}
+
+func streamStatsFor(ruleID uint) *streamStats {
+	if set := streamRules.Load(); set != nil {
+		for _, sr := range *set {
+			if sr.rule.ID == ruleID {
+				return sr.stats
+			}
+		}
+	}
+	return nil
+}
+
+// streamCPUBudget 单条Rule每分钟可用的 CPU 毫秒数，超出后本分钟内暂停求值，0 表示不限制
+func streamCPUBudget() time.Duration {
+	return time.Duration(viper.GetInt("scheduler.stream.cpu_budget_ms")) * time.Millisecond
+}
+
+// runStreamFlusher 检查 CPU 预算，并every分钟将统计写入Execute记录供健康度使用
+func runStreamFlusher() {
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+	for now := range check.C {
+		budget := streamCPUBudget()
+		set := streamRules.Load()
+		if set == nil {
+			continue
+		}
+		if budget > 0 {
+			for _, sr := range *set {
+				if !sr.stats.throttled.Load() && time.Duration(sr.stats.cpuNanos.Load()) > budget {
+					sr.stats.throttled.Store(true)
+					log.Printf("[Stream][Rule:%d] CPU budget %s exceeded, paused until next window", sr.rule.ID, budget)
+				}
+			}
+		}
+		if now.Sub(windowStart) < time.Minute {
+			continue
+		}
+		flushStreamStats(*set, budget, now)
+	}
+}
+
+func flushStreamStats(set []*streamRule, budget time.Duration, now time.Time) {
+	start := windowStart
+	windowStart = now
+	for _, sr := range set {
+		s := sr.stats
+		exec := model.RuleExecution{
+			RuleID:     sr.rule.ID,
+			Kind:       ExecStream,
+			StartedAt:  start,
+			Rows:       int(s.events.Swap(0)),
+			NewAlerts:  int(s.alerts.Swap(0)),
+			DurationMs: time.Duration(s.cpuNanos.Swap(0)).Milliseconds(),
+		}
+		s.matches.Store(0)
+		s.errors.Store(0)
+		switch {
+		case sr.err != nil:
+			exec.Error = "compile error: " + sr.err.Error()
+		case s.throttled.Swap(false):
+			exec.Error = fmt.Sprintf("cpu budget exceeded (%s per minute)", budget)
+		case exec.Rows == 0:
+			continue // 没有Event流入时不产生记录
+		}
+		recordExecution(sr.rule, exec)
+	}
+}
+
+// StreamStats 当ago窗口内全部实时Rule的运行统计
+func StreamStats() []StreamRuleStats {
+	set := streamRules.Load()
+	if set == nil {
+		return []StreamRuleStats{}
+	}
+	result := make([]StreamRuleStats, 0, len(*set))
+	for _, sr := range *set {
+		s := sr.stats
+		item := StreamRuleStats{
+			RuleID:    sr.rule.ID,
+			RuleName:  sr.rule.Name,
+			Events:    s.events.Load(),
+			Matches:   s.matches.Load(),
+			Errors:    s.errors.Load(),
+			Alerts:    s.alerts.Load(),
+			CPUTimeMs: float64(s.cpuNanos.Load()) / float64(time.Millisecond),
+			Throttled: s.throttled.Load(),
+		}
+		if item.Events > 0 {
+			item.AvgEvalUs = float64(s.cpuNanos.Load()) / float64(item.Events) / float64(time.Microsecond)
+		}
+		if sr.err != nil {
+			item.CompileError = sr.err.Error()
+		}
+		if e, ok := s.lastError.Load().(string); ok {
+			item.LastError = e
+		}
+		result = append(result, item)
+	}
+	return result
+}
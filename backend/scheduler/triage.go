@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/notify"
+)
+
+// alertTransitions Alert研判Status允许的迁移，closed 只能重New打开为 triaged
+var alertTransitions = map[string][]string{
+	model.AlertNew:           {model.AlertTriaged, model.AlertFalsePositive, model.AlertTruePositive, model.AlertClosed},
+	model.AlertTriaged:       {model.AlertNew, model.AlertFalsePositive, model.AlertTruePositive, model.AlertClosed},
+	model.AlertFalsePositive: {model.AlertTriaged, model.AlertTruePositive, model.AlertClosed},
+	model.AlertTruePositive:  {model.AlertTriaged, model.AlertFalsePositive, model.AlertClosed},
+	model.AlertClosed:        {model.AlertTriaged},
+}
+
+// AlertTriage 一次研判操作，空字段表示不修改
+type AlertTriage struct {
+	Status   string `json:"status"`
+	Assignee *uint  `json:"assignee"`
+	Verdict  string `json:"verdict"` // closed 时可附带结论
+	Comment  string `json:"comment"`
+	ActorID  uint   `json:"-"`
+}
+
+// TriageResult 批量研判结果，Rejected 为未更新的Alert及原因
+type TriageResult struct {
+	Updated   []uint          `json:"updated"`
+	Rejected  map[uint]string `json:"rejected"`
+	Incidents []uint          `json:"incidents"`
+}
+
+// CanTransitionAlert 研判Status能否从 from 迁移到 to，Status不变视为允许
+func CanTransitionAlert(from, to string) bool {
+	if from == "" {
+		from = model.AlertNew
+	}
+	if from == to {
+		return true
+	}
+	for _, next := range alertTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func terminalAlert(status string) bool {
+	return status == model.AlertFalsePositive || status == model.AlertTruePositive || status == model.AlertClosed
+}
+
+// ValidateAlertTriage 校验研判操作本身 (与具体Alert的当agoStatus无关)
+func ValidateAlertTriage(t AlertTriage) error {
+	if _, ok := alertTransitions[t.Status]; t.Status != "" && !ok {
+		return fmt.Errorf("unknown alert status %q", t.Status)
+	}
+	switch t.Verdict {
+	case "", model.AlertFalsePositive, model.AlertTruePositive:
+	default:
+		return fmt.Errorf("verdict must be false_positive or true_positive")
+	}
+	if t.Verdict != "" && t.Status != model.AlertClosed {
+		return fmt.Errorf("verdict can only be given when closing alerts")
+	}
+	if t.Status == "" && t.Assignee == nil && t.Comment == "" {
+		return fmt.Errorf("nothing to update")
+	}
+	return nil
+}
+
+// TriageAlerts 对一批Alert应用研判操作，不允许的Status迁移逐条拒绝，并汇总到所属 Incident
+func TriageAlerts(ids []uint, t AlertTriage) (*TriageResult, error) {
+	if err := ValidateAlertTriage(t); err != nil {
+		return nil, err
+	}
+	db := database.GetDB()
+	now := time.Now().UTC()
+	result := &TriageResult{Updated: []uint{}, Rejected: map[uint]string{}, Incidents: []uint{}}
+
+	var alerts []model.Alert
+	if len(ids) > 0 {
+		db.Where("id IN ?", ids).Find(&alerts)
+	}
+	found := make(map[uint]bool, len(alerts))
+	changed := make(map[uint]int) // incident => 本次更新的Alert数
+	for _, a := range alerts {
+		found[a.ID] = true
+		from := a.Status
+		if from == "" {
+			from = model.AlertNew
+		}
+		to := t.Status
+		// 指派New Alert时自动进入 triaged
+		if to == "" && t.Assignee != nil && from == model.AlertNew {
+			to = model.AlertTriaged
+		}
+		if to == "" {
+			to = from
+		}
+		if !CanTransitionAlert(from, to) {
+			result.Rejected[a.ID] = fmt.Sprintf("cannot move alert from %s to %s", from, to)
+			continue
+		}
+
+		updates := map[string]interface{}{"status": to}
+		switch to {
+		case model.AlertNew:
+			updates["verdict"], updates["triaged_at"], updates["closed_at"] = "", nil, nil
+		case model.AlertTriaged:
+			updates["closed_at"] = nil
+		case model.AlertFalsePositive, model.AlertTruePositive:
+			updates["verdict"], updates["closed_at"] = to, nil
+		case model.AlertClosed:
+			if t.Verdict != "" {
+				updates["verdict"] = t.Verdict
+			}
+			if a.ClosedAt == nil {
+				updates["closed_at"] = now
+			}
+		}
+		if to != model.AlertNew && a.TriagedAt == nil {
+			updates["triaged_at"] = now
+		}
+		if t.Assignee != nil {
+			updates["assignee"] = *t.Assignee
+		}
+		if t.Comment != "" {
+			updates["closing_comment"] = t.Comment
+		}
+		if err := db.Model(&model.Alert{}).Where("id = ?", a.ID).Updates(updates).Error; err != nil {
+			result.Rejected[a.ID] = err.Error()
+			continue
+		}
+		result.Updated = append(result.Updated, a.ID)
+		if to == model.AlertFalsePositive || (to == model.AlertClosed && t.Verdict == model.AlertFalsePositive) {
+			requestTuning(a.RuleID)
+		}
+		if a.IncidentID != 0 {
+			changed[a.IncidentID]++
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			result.Rejected[id] = "alert not found"
+		}
+	}
+
+	for incidentID, n := range changed {
+		result.Incidents = append(result.Incidents, incidentID)
+		detail := fmt.Sprintf("%d alert(s) updated", n)
+		if t.Status != "" {
+			detail = fmt.Sprintf("%d alert(s) set to %s", n, t.Status)
+		}
+		database.LogIncidentActivity(db, model.IncidentActivity{
+			IncidentID: incidentID,
+			ActorID:    t.ActorID,
+			Type:       model.ActivityAlerts,
+			To:         t.Status,
+			Detail:     detail,
+		})
+		RollupIncident(incidentID, t.ActorID)
+	}
+	return result, nil
+}
+
+// RollupIncident 根据Alert研判Status推导 Incident Status：
+// 全部Alert已有结论或关闭 => resolved；Resolve后有Alert被重New打开 => acknowledged；
+// 有Alert开始研判 => New Incident 进入 acknowledged。不会把 Incident 退回 new。
+func RollupIncident(incidentID uint, actorID uint) {
+	db := database.GetDB()
+	var incident model.Incident
+	if err := db.First(&incident, incidentID).Error; err != nil {
+		return
+	}
+	var alerts []model.Alert
+	db.Select("status", "verdict").Where("incident_id = ? AND suppressed = ?", incidentID, false).Find(&alerts)
+	if len(alerts) == 0 {
+		return
+	}
+
+	allDone, anyStarted, anyTrue, allFalse := true, false, false, true
+	for _, a := range alerts {
+		if !terminalAlert(a.Status) {
+			allDone = false
+		}
+		if a.Status != "" && a.Status != model.AlertNew {
+			anyStarted = true
+		}
+		if a.Verdict == model.AlertTruePositive {
+			anyTrue = true
+		}
+		if a.Verdict != model.AlertFalsePositive {
+			allFalse = false
+		}
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{}
+	switch {
+	case allDone:
+		updates = database.IncidentStatusUpdates(incident, "resolved", now)
+		if incident.ClosingClassification == "" && anyTrue {
+			updates["closing_classification"] = model.AlertTruePositive
+		} else if incident.ClosingClassification == "" && allFalse {
+			updates["closing_classification"] = model.AlertFalsePositive
+		}
+	case incident.Status == "resolved" || (incident.Status == "new" && anyStarted):
+		updates = database.IncidentStatusUpdates(incident, "acknowledged", now)
+	}
+	status, _ := updates["status"].(string)
+	if status == "" || status == incident.Status {
+		return
+	}
+	from := incident.Status
+	if err := db.Model(&incident).Updates(updates).Error; err != nil {
+		return
+	}
+	database.LogIncidentActivity(db, model.IncidentActivity{
+		IncidentID: incident.ID,
+		ActorID:    actorID,
+		Type:       model.ActivityStatus,
+		From:       from,
+		To:         status,
+		Detail:     "derived from alert triage",
+	})
+	if status == "resolved" {
+		go notify.Incident(model.NotifyIncidentResolved, incident, "derived from alert triage")
+	}
+}
+
+// VerdictFromClassification 关闭分类 (如 TruePositive_Malicious / FalsePositive_IncorrectLogic) 对应的Alert结论，无法对应时Return空
+func VerdictFromClassification(classification string) string {
+	c := strings.ToLower(strings.ReplaceAll(classification, "_", ""))
+	switch {
+	case strings.HasPrefix(c, "truepositive"):
+		return model.AlertTruePositive
+	case strings.HasPrefix(c, "falsepositive"):
+		return model.AlertFalsePositive
+	}
+	return ""
+}
+
+// CloseIncidentAlerts Incident Resolve时关闭其仍在研判Medium的Alert，分类可对应结论时一并记录
+func CloseIncidentAlerts(incidentID uint, classification string) int64 {
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":    model.AlertClosed,
+		"closed_at": now,
+	}
+	if v := VerdictFromClassification(classification); v != "" {
+		updates["verdict"] = v
+	}
+	db := database.GetDB()
+	res := db.Model(&model.Alert{}).
+		Where("incident_id = ? AND suppressed = ? AND status IN ?", incidentID, false, []string{"", model.AlertNew, model.AlertTriaged}).
+		Updates(updates)
+	if updates["verdict"] == model.AlertFalsePositive && res.RowsAffected > 0 {
+		var ruleIDs []uint
+		db.Model(&model.Alert{}).Where("incident_id = ?", incidentID).Distinct().Pluck("rule_id", &ruleIDs)
+		requestTuning(ruleIDs...)
+	}
+	return res.RowsAffected
+}
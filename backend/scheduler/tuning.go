@@ -0,0 +1,313 @@
+package scheduler
+
+// ==============================================================================
+// 误报反馈调优
+// 分析Rule已关闭的误报Alert，找出在误报Medium占绝大多数的字段值，生成抑制建议：
+// 条件型 suppress 维护窗口，或直接修改Rule Query排除该值。
+// every条建议附带影响评估：历史上会被一并隐藏的真阳性/未定性Alert数量。
+// ==============================================================================
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/database"
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/logsql"
+	"github.com/spf13/viper"
+)
+
+// 不参与分析的字段：Time、原始消息和流标识every条都不同或没有区分度
+var tuningSkipFields = map[string]bool{
+	"_time": true, "_msg": true, "_stream": true, "_stream_id": true,
+	"message": true, "raw_data": true, "timestamp": true, "@timestamp": true,
+}
+
+var (
+	tuningMu    sync.Mutex
+	tuningDirty = make(map[uint]bool)
+)
+
+func tuningInt(key string, def int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return def
+}
+
+func tuningMinShare() float64 {
+	if f := viper.GetFloat64("tuning.min_share"); f > 0 && f <= 1 {
+		return f
+	}
+	return 0.6
+}
+
+func tuningCheckInterval() time.Duration {
+	if d := viper.GetDuration("tuning.check_interval"); d > 0 {
+		return d
+	}
+	return 10 * time.Minute
+}
+
+// startTuning Start后台分析：Alert被标记为误报后，该Rule在下个周期重New分析
+func startTuning() {
+	go func() {
+		ticker := time.NewTicker(tuningCheckInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			tuningMu.Lock()
+			ids := make([]uint, 0, len(tuningDirty))
+			for id := range tuningDirty {
+				ids = append(ids, id)
+			}
+			tuningDirty = make(map[uint]bool)
+			tuningMu.Unlock()
+			for _, id := range ids {
+				if _, err := AnalyzeFalsePositives(id); err != nil {
+					log.Printf("[Tuning] Analysis for rule %d failed: %v", id, err)
+				}
+			}
+		}
+	}()
+}
+
+// requestTuning 标记Rule需要重New分析误报
+func requestTuning(ruleIDs ...uint) {
+	tuningMu.Lock()
+	defer tuningMu.Unlock()
+	for _, id := range ruleIDs {
+		if id != 0 {
+			tuningDirty[id] = true
+		}
+	}
+}
+
+// flattenContent 把Alert内容展开为 字段 => 值；VictoriaLogs 结果本身是扁平的点号字段，
+// 实时Rule的Event为嵌套对象，嵌套字段记为 a.b.c。只保留标量值
+func flattenContent(content string) map[string]interface{} {
+	var doc map[string]interface{}
+	if json.Unmarshal([]byte(content), &doc) != nil {
+		return nil
+	}
+	out := make(map[string]interface{})
+	var walk func(prefix []string, m map[string]interface{})
+	walk = func(prefix []string, m map[string]interface{}) {
+		for k, v := range m {
+			path := append(append([]string{}, prefix...), k)
+			switch val := v.(type) {
+			case map[string]interface{}:
+				walk(path, val)
+			case string, float64, bool:
+				if len(prefix) == 0 && tuningSkipFields[k] {
+					continue
+				}
+				if s, ok := val.(string); ok && (s == "" || len(s) > 256) {
+					continue
+				}
+				out[encodePath(path)] = val
+			}
+		}
+	}
+	walk(nil, doc)
+	return out
+}
+
+// encodePath 顶层字段 (可能含点号) 直接作为键，嵌套字段以 \x00 分隔，生成条件时需区分两者
+func encodePath(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// displayField 建议Medium展示的字段名
+func displayField(key string) string {
+	return strings.ReplaceAll(key, "\x00", ".")
+}
+
+// fieldCondition 生成匹配 字段 == 值 的 expr 条件；字段名可能含点号或与关键字冲突，统一用 $env["..."] 访问
+func fieldCondition(key string, literal string) string {
+	var b strings.Builder
+	b.WriteString("$env")
+	for _, s := range strings.Split(key, "\x00") {
+		b.WriteString("[" + strconv.Quote(s) + "]")
+	}
+	return b.String() + " == " + literal
+}
+
+// valueString 字段值的文本形式，数字不使用科学计数法
+func valueString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+type tuningCandidate struct {
+	key     string
+	value   interface{}
+	fp      int
+	tp      int
+	other   int
+	literal string
+}
+
+// AnalyzeFalsePositives 重New分析一条Rule的误报并更新建议，ruleID 为 0 时分析全部有误报的Rule；
+// Return仍处于 proposed Status的建议
+func AnalyzeFalsePositives(ruleID uint) ([]model.SuppressionProposal, error) {
+	db := database.GetDB()
+	if ruleID == 0 {
+		var ids []uint
+		db.Model(&model.Alert{}).Where("verdict = ?", model.AlertFalsePositive).Distinct().Pluck("rule_id", &ids)
+		result := []model.SuppressionProposal{}
+		for _, id := range ids {
+			proposals, err := AnalyzeFalsePositives(id)
+			if err != nil {
+				continue
+			}
+			result = append(result, proposals...)
+		}
+		return result, nil
+	}
+
+	var rule model.Rule
+	if err := db.First(&rule, ruleID).Error; err != nil {
+		return nil, fmt.Errorf("rule not found")
+	}
+	maxAlerts := tuningInt("tuning.max_alerts", 5000)
+	var alerts []model.Alert
+	db.Select("id", "content", "verdict").
+		Where("rule_id = ? AND suppressed = ?", ruleID, false).
+		Order("id desc").Limit(maxAlerts).Find(&alerts)
+
+	// 统计every个 字段=值 在误报Alert中出现的次数
+	candidates := make(map[string]*tuningCandidate)
+	fpTotal, tpTotal := 0, 0
+	var others []map[string]interface{}
+	var otherVerdicts []string
+	for _, a := range alerts {
+		fields := flattenContent(a.Content)
+		if a.Verdict != model.AlertFalsePositive {
+			others = append(others, fields)
+			otherVerdicts = append(otherVerdicts, a.Verdict)
+			if a.Verdict == model.AlertTruePositive {
+				tpTotal++
+			}
+			continue
+		}
+		fpTotal++
+		for k, v := range fields {
+			literal, _ := json.Marshal(v)
+			id := k + "\x01" + string(literal)
+			c, ok := candidates[id]
+			if !ok {
+				c = &tuningCandidate{key: k, value: v, literal: string(literal)}
+				candidates[id] = c
+			}
+			c.fp++
+		}
+	}
+
+	now := time.Now().UTC()
+	var found []*tuningCandidate
+	if fpTotal >= tuningInt("tuning.min_alerts", 5) {
+		minShare := tuningMinShare()
+		for _, c := range candidates {
+			// Rule Query本身按该字段筛选的值抑制后等于停用Rule，不作为建议
+			if float64(c.fp)/float64(fpTotal) < minShare || filtersValue(rule, c) {
+				continue
+			}
+			// 影响评估：非误报Alert中同样带有该值的数量
+			for i, fields := range others {
+				if v, ok := fields[c.key]; ok && v == c.value {
+					if otherVerdicts[i] == model.AlertTruePositive {
+						c.tp++
+					} else {
+						c.other++
+					}
+				}
+			}
+			// 在真阳性中同样常见的值没有区分度 (如所有Event都带的字段)；未定性Alert很可能也是误报，只计入影响
+			if c.tp >= c.fp || (tpTotal > 0 && float64(c.tp)/float64(tpTotal) >= minShare) {
+				continue
+			}
+			found = append(found, c)
+		}
+	}
+	// 优先命Medium误报多、误伤真阳性少的值
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].fp != found[j].fp {
+			return found[i].fp > found[j].fp
+		}
+		if found[i].tp != found[j].tp {
+			return found[i].tp < found[j].tp
+		}
+		return found[i].key < found[j].key
+	})
+	if max := tuningInt("tuning.max_proposals", 5); len(found) > max {
+		found = found[:max]
+	}
+
+	keep := make([]uint, 0, len(found))
+	for _, c := range found {
+		field, value := displayField(c.key), valueString(c.value)
+		var p model.SuppressionProposal
+		db.Where(model.SuppressionProposal{RuleID: ruleID, Field: field, Value: value}).
+			Attrs(model.SuppressionProposal{Status: model.ProposalOpen}).
+			FirstOrInit(&p)
+		if p.ID != 0 && p.Status != model.ProposalOpen {
+			// 已接受或忽略的建议不再重New提出
+			continue
+		}
+		p.Condition = fieldCondition(c.key, c.literal)
+		p.RefinedQuery = refineQuery(rule, c, p.Condition)
+		p.FalsePositives, p.FalseTotal = c.fp, fpTotal
+		p.Share = float64(c.fp) / float64(fpTotal)
+		p.TruePositives, p.Undetermined = c.tp, c.other
+		p.AnalyzedAt = &now
+		if err := db.Save(&p).Error; err != nil {
+			return nil, err
+		}
+		keep = append(keep, p.ID)
+	}
+
+	// 不再成立的旧建议删除
+	stale := db.Where("rule_id = ? AND status = ?", ruleID, model.ProposalOpen)
+	if len(keep) > 0 {
+		stale = stale.Where("id NOT IN ?", keep)
+	}
+	stale.Delete(&model.SuppressionProposal{})
+
+	proposals := []model.SuppressionProposal{}
+	db.Where("rule_id = ? AND status = ?", ruleID, model.ProposalOpen).
+		Order("false_positives desc, true_positives asc").Find(&proposals)
+	return proposals, nil
+}
+
+// filtersValue Rule Query是否直接按该字段筛选该值：LogSQL 用Parse出的过滤条件判断，实时Rule匹配 field == 值
+func filtersValue(rule model.Rule, c *tuningCandidate) bool {
+	if rule.Type == RuleTypeStream {
+		pattern := `(^|[^\w.])` + regexp.QuoteMeta(c.key) + `\s*==\s*` + regexp.QuoteMeta(c.literal)
+		return regexp.MustCompile(pattern).MatchString(rule.Query)
+	}
+	return logsql.FiltersValue(rule.Query, c.key, valueString(c.value))
+}
+
+// refineQuery 生成排除该值的Rule Query；LogSQL Rule在过滤段追加取反条件，实时Rule追加 && !(条件)
+func refineQuery(rule model.Rule, c *tuningCandidate, condition string) string {
+	switch {
+	case rule.Type == RuleTypeStream:
+		return fmt.Sprintf("(%s) && !(%s)", strings.TrimSpace(rule.Query), condition)
+	case ScheduledType(rule.Type) && !strings.Contains(c.key, "\x00"):
+		refined, err := logsql.ExcludeValue(rule.Query, c.key, valueString(c.value))
+		if err != nil {
+			return ""
+		}
+		return refined
+	}
+	return ""
+}
@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/laenix/vsentry/model"
+	"github.com/laenix/vsentry/pkg/logsql"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+)
+
+// 校验问题Code，UI 按 code 展示提示
+const (
+	IssueRequired           = "required"
+	IssueInvalidInterval    = "invalid_interval"
+	IssueSyntax             = "syntax_error"
+	IssueUnavailable        = "validation_unavailable"
+	IssueUnboundedTime      = "unbounded_time_range"
+	IssueLeadingWildcard    = "leading_wildcard"
+	IssueUnknownField       = "unknown_field"
+	IssueWindowOverInterval = "window_exceeds_interval"
+)
+
+// RuleIssue 一条校验Error或 lint Warning
+type RuleIssue struct {
+	Field   string `json:"field"` // query / interval
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Pos     *int   `json:"pos,omitempty"`
+}
+
+// RuleValidation 校验结果，Errors 非空时Rule不Allow保存
+type RuleValidation struct {
+	Errors   []RuleIssue `json:"errors"`
+	Warnings []RuleIssue `json:"warnings"`
+}
+
+// Valid 是否可以保存
+func (v *RuleValidation) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+func (v *RuleValidation) errorf(field, code, format string, args ...interface{}) {
+	v.Errors = append(v.Errors, RuleIssue{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *RuleValidation) warnf(field, code, format string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, RuleIssue{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// lintPolicy lint 配置 (config.yaml scheduler.rule_lint)
+type lintPolicy struct {
+	Remote           bool          // 是否请求 VictoriaLogs 校验语法和字段
+	FrequentInterval time.Duration // 执行间隔低于该值且没有 _time 过滤时Warning
+	FieldLookback    time.Duration // 统计已有字段的Time范围
+}
+
+func loadLintPolicy() lintPolicy {
+	p := lintPolicy{
+		Remote:           !viper.IsSet("scheduler.rule_lint.remote") || viper.GetBool("scheduler.rule_lint.remote"),
+		FrequentInterval: viper.GetDuration("scheduler.rule_lint.frequent_interval"),
+		FieldLookback:    viper.GetDuration("scheduler.rule_lint.field_lookback"),
+	}
+	if p.FrequentInterval <= 0 {
+		p.FrequentInterval = time.Hour
+	}
+	if p.FieldLookback <= 0 {
+		p.FieldLookback = 24 * time.Hour
+	}
+	return p
+}
+
+var intervalParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseInterval 按调度器相同的格式 (六段 cron 或 @every) 解析Rule间隔，Return大致的Execute周期
+func ParseInterval(interval string) (time.Duration, error) {
+	schedule, err := intervalParser.Parse(strings.TrimSpace(interval))
+	if err != nil {
+		return 0, err
+	}
+	first := schedule.Next(time.Now())
+	return schedule.Next(first).Sub(first), nil
+}
+
+// ScheduledType 是否为按 Interval 定时Execute的RuleType
+func ScheduledType(ruleType string) bool {
+	return ruleType == "" || ruleType == "alert" || ruleType == RuleTypeAnomaly
+}
+
+// ValidateRule 保存ago校验Rule：Interval 格式、LogSQL 语法 (静态检查 + VictoriaLogs 零范围Query) 以及 lint
+func ValidateRule(rule model.Rule) *RuleValidation {
+	v := &RuleValidation{Errors: []RuleIssue{}, Warnings: []RuleIssue{}}
+	scheduled := ScheduledType(rule.Type)
+	policy := loadLintPolicy()
+
+	// 实时Rule的 Query 是 expr Expression，不涉及 LogSQL 和 Interval
+	if rule.Type == RuleTypeStream {
+		if strings.TrimSpace(rule.Query) == "" {
+			v.errorf("query", IssueRequired, "query is required")
+		} else if _, err := CompileStreamQuery(rule.Query); err != nil {
+			v.errorf("query", IssueSyntax, "invalid expression: %v", err)
+		}
+		return v
+	}
+
+	var period time.Duration
+	if scheduled || rule.Interval != "" {
+		if strings.TrimSpace(rule.Interval) == "" {
+			v.errorf("interval", IssueRequired, "interval is required for %s rules", rule.Type)
+		} else if d, err := ParseInterval(rule.Interval); err != nil {
+			v.errorf("interval", IssueInvalidInterval, "invalid interval %q: %v (use 6-field cron with seconds or @every 5m)", rule.Interval, err)
+		} else {
+			period = d
+		}
+	}
+
+	query := strings.TrimSpace(rule.Query)
+	if query == "" {
+		v.errorf("query", IssueRequired, "query is required")
+		return v
+	}
+	analysis, err := logsql.Analyze(query)
+	if err != nil {
+		issue := RuleIssue{Field: "query", Code: IssueSyntax, Message: err.Error()}
+		if se, ok := err.(*logsql.SyntaxError); ok {
+			issue.Pos = &se.Pos
+		}
+		v.Errors = append(v.Errors, issue)
+		return v
+	}
+
+	if scheduled && period > 0 && period < policy.FrequentInterval {
+		if !analysis.TimeFilter {
+			v.warnf("query", IssueUnboundedTime, "query has no _time filter but runs every %s; each run scans the whole retention period", period)
+		} else if analysis.TimeRange > 0 && analysis.TimeRange > 24*period && analysis.TimeRange > policy.FrequentInterval {
+			v.warnf("query", IssueWindowOverInterval, "_time window %s is much larger than the %s interval; most of each run re-reads already processed logs", analysis.TimeRange, period)
+		}
+	}
+	for _, w := range analysis.LeadingWildcards {
+		v.warnf("query", IssueLeadingWildcard, "leading wildcard in %q cannot use the word index and forces a full scan", w)
+	}
+
+	// 调查/取证Rule包含占位符或作为管道拼接Execute，只做静态检查
+	if !scheduled || !policy.Remote {
+		return v
+	}
+	if err := checkRemoteSyntax(query); err != nil {
+		if remote, ok := err.(*remoteError); ok && remote.status == http.StatusBadRequest {
+			v.errorf("query", IssueSyntax, "%s", remote.msg)
+			return v
+		}
+		v.warnf("query", IssueUnavailable, "could not validate query against VictoriaLogs: %v", err)
+		return v
+	}
+	if len(analysis.Fields) > 0 {
+		known, err := knownFields(policy.FieldLookback)
+		if err != nil {
+			v.warnf("query", IssueUnavailable, "could not load field names from VictoriaLogs: %v", err)
+			return v
+		}
+		if len(known) > 0 {
+			for _, f := range analysis.Fields {
+				if !known[f] {
+					v.warnf("query", IssueUnknownField, "field %q has not been seen in any stream in the last %s", f, policy.FieldLookback)
+				}
+			}
+		}
+	}
+	return v
+}
+
+type remoteError struct {
+	status int
+	msg    string
+}
+
+func (e *remoteError) Error() string {
+	return fmt.Sprintf("victorialogs returned %d: %s", e.status, e.msg)
+}
+
+func victoriaLogsURL() string {
+	addr := viper.GetString("victorialogs.url")
+	if addr == "" {
+		addr = "http://127.0.0.1:9428"
+	}
+	return addr
+}
+
+var lintClient = &http.Client{Timeout: 5 * time.Second}
+
+// checkRemoteSyntax 用零长度Time范围Query让 VictoriaLogs 解析语句，不扫描Data
+func checkRemoteSyntax(query string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	resp, err := lintClient.PostForm(victoriaLogsURL()+"/select/logsql/query", url.Values{
+		"query": {query},
+		"start": {now},
+		"end":   {now},
+		"limit": {"1"},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 400 {
+		return &remoteError{status: resp.StatusCode, msg: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// 字段名缓存，避免每次保存都扫描 field_names
+var fieldCache struct {
+	sync.Mutex
+	fields   map[string]bool
+	lookback time.Duration
+	loadedAt time.Time
+}
+
+// knownFields 最近一段Time内出现过的全部字段名
+func knownFields(lookback time.Duration) (map[string]bool, error) {
+	fieldCache.Lock()
+	defer fieldCache.Unlock()
+	if fieldCache.fields != nil && fieldCache.lookback == lookback && time.Since(fieldCache.loadedAt) < time.Minute {
+		return fieldCache.fields, nil
+	}
+
+	now := time.Now().UTC()
+	resp, err := lintClient.PostForm(victoriaLogsURL()+"/select/logsql/field_names", url.Values{
+		"query": {"*"},
+		"start": {now.Add(-lookback).Format(time.RFC3339)},
+		"end":   {now.Format(time.RFC3339)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, &remoteError{status: resp.StatusCode, msg: strings.TrimSpace(string(body))}
+	}
+
+	var result struct {
+		Values []struct {
+			Value string `json:"value"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]bool, len(result.Values))
+	for _, v := range result.Values {
+		fields[v.Value] = true
+	}
+	fieldCache.fields, fieldCache.lookback, fieldCache.loadedAt = fields, lookback, time.Now()
+	return fields, nil
+}
@@ -27,7 +27,7 @@ export function TestRunDialog({ open, onOpenChange, onRun, triggerConfig }: Test
     // 只有在Event触发模式下才去拉取List
     if (open && triggerType === 'incident_created') {
       setLoading(true);
-      incidentService.list('new')
+      incidentService.list({ status: 'new' })
         .then(res => setIncidents(res.data || []))
         .finally(() => setLoading(false));
     }
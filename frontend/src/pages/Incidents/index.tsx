@@ -1,4 +1,4 @@
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import { incidentService } from "@/services/incidents";
 import type { Incident } from "@/services/incidents";
 import { Button } from "@/components/ui/button";
@@ -15,9 +15,16 @@ import { IncidentResolveDialog } from "./IncidentResolveDialog";
 import { IncidentAssignDialog } from "./IncidentAssignDialog";
 import { useTabStore } from "@/stores/tab-store";
 
+// 每页条数，后端上限 1000
+const PAGE_SIZE = 100;
+const MAX_PAGE_SIZE = 1000;
+
 export default function IncidentsPage() {
   const [incidents, setIncidents] = useState<Incident[]>([]);
   const [loading, setLoading] = useState(true);
+  const [nextCursor, setNextCursor] = useState("");
+  // 定时刷新时保留已加载的条数
+  const loadedRef = useRef(0);
   const [filter, setFilter] = useState("all"); 
   
   // 弹窗StatusManage
@@ -41,20 +48,21 @@ export default function IncidentsPage() {
   // Get真实 ID (兼容后端 GORM Default的大写 ID)
   const getIncidentID = (i: Incident) => i.ID || (i as any).id || 0;
 
-  // 1. 加载EventList
-  const fetchIncidents = async () => {
+  // 1. 加载EventList：不带 cursor 时重新加载第一页 (刷新时保留已加载的条数)，带 cursor 时追加下一页
+  const fetchIncidents = async (cursor?: string) => {
     setLoading(true);
     try {
-      const res = await incidentService.list();
+      const limit = cursor ? PAGE_SIZE : Math.min(Math.max(loadedRef.current, PAGE_SIZE), MAX_PAGE_SIZE);
+      const res = await incidentService.list({ limit, cursor });
       if (res.code === 200) {
-        let list = res.data || [];
-        // 按照最后活跃Time (last_seen) 倒序排列
-        list.sort((a, b) => {
-           const tA = new Date(a.last_seen || a.CreatedAt).getTime();
-           const tB = new Date(b.last_seen || b.CreatedAt).getTime();
-           return tB - tA;
+        // 后端已按最后活跃Time (last_seen) 倒序排列
+        const page = res.data || [];
+        setIncidents(prev => {
+          const list = cursor ? [...prev, ...page] : page;
+          loadedRef.current = list.length;
+          return list;
         });
-        setIncidents(list);
+        setNextCursor(res.next_cursor || "");
       }
     } catch (err) {
       console.error(err);
@@ -112,7 +120,7 @@ export default function IncidentsPage() {
             <p className="text-muted-foreground text-sm">Investigate and manage aggregated security incidents.</p>
            </div>
         </div>
-        <Button variant="outline" size="sm" onClick={fetchIncidents} disabled={loading}>
+        <Button variant="outline" size="sm" onClick={() => fetchIncidents()} disabled={loading}>
           <RotateCw className={`w-4 h-4 mr-2 ${loading ? "animate-spin" : ""}`} />
           Refresh
         </Button>
@@ -243,6 +251,13 @@ export default function IncidentsPage() {
           </TableBody>
         </Table>
       </div>
+      {nextCursor && (
+        <div className="flex justify-center">
+          <Button variant="outline" size="sm" onClick={() => fetchIncidents(nextCursor)} disabled={loading}>
+            Load more
+          </Button>
+        </div>
+      )}
 
       {/* 弹窗Group件Mount */}
       <IncidentDetailDialog 
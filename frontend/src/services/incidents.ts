@@ -22,10 +22,21 @@ export interface Incident {
   alerts?: any[];          // 关联的原始Evidence数Group
 }
 
+// 分页List：next_cursor 为空表示没有下一页
+export type IncidentPage = APIResponse<Incident[]> & { next_cursor?: string };
+
+export interface IncidentListParams {
+  status?: string;  // 转换为过滤Expression status:<status>
+  limit?: number;   // 每页条数，后端Default 100、最多 1000
+  cursor?: string;  // 上一页Return的 next_cursor
+}
+
 export const incidentService = {
-  // 1. GetEventList
-  list: (status?: string) => 
-    apiClient.get<any, APIResponse<Incident[]>>(status ? `/incidents/list?status=${status}` : "/incidents/list"),
+  // 1. GetEventList (按 last_seen 倒序分页)
+  list: ({ status, limit, cursor }: IncidentListParams = {}) =>
+    apiClient.get<any, IncidentPage>("/incidents/list", {
+      params: { q: status ? `status:${status}` : undefined, limit, cursor },
+    }),
 
   // 2. GetEventDetail (包含Evidence数Group)
   detail: (id: number) => 